@@ -1,8 +1,10 @@
 package alarm
 
 import (
+	"GoldenDB/connect"
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 type DDLError struct {
@@ -67,9 +69,17 @@ func GetClusterName(mds *sql.DB, id string) string {
 	}
 	return ClusterName
 }
-func GetDNinfo(mds *sql.DB, clusterid string, name string, password string) []string {
-	sqlstr := "select db_ip, db_port from mds.db_info where cluster_id=? and db_role=0"
-	DSNList := []string{}
+
+// MasterDN 一个主DN的连接信息及其所属DN组
+type MasterDN struct {
+	GroupID string
+	connect.Node
+}
+
+// GetDNinfo 返回集群各主DN的连接信息，按DN组排序，使用给定的用户名、密码和连接设置
+func GetDNinfo(mds *sql.DB, clusterid string, name string, password string, conn connect.DBConn) []MasterDN {
+	sqlstr := "select group_id, db_ip, db_port from mds.db_info where cluster_id=? and db_role=0 order by group_id"
+	DSNList := []MasterDN{}
 	rows, err := mds.Query(sqlstr, clusterid)
 	if err != nil {
 		fmt.Println("GetDNinfo Query Error:", err)
@@ -77,16 +87,23 @@ func GetDNinfo(mds *sql.DB, clusterid string, name string, password string) []st
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var dbip string
+		var groupid, dbip string
 		var dbport int
-		err := rows.Scan(&dbip, &dbport)
+		err := rows.Scan(&groupid, &dbip, &dbport)
 		if err != nil {
 			fmt.Println("GetDNinfo Scan Error:", err)
 		}
-		dsn := name + ":" + password + "@tcp(" +
-			dbip + ":" + fmt.Sprintf("%d", dbport) + ")/" +
-			"" + "?loadbalance=false&blacklist=-1"
-		DSNList = append(DSNList, dsn)
+		DSNList = append(DSNList, MasterDN{
+			GroupID: groupid,
+			Node: connect.Node{
+				Name:     fmt.Sprintf("DN %s:%d", dbip, dbport),
+				Host:     dbip,
+				Port:     dbport,
+				User:     name,
+				Password: password,
+				Conn:     conn,
+			},
+		})
 	}
 	return DSNList
 
@@ -111,3 +128,53 @@ func GetSchema(mds *sql.DB, id string) []string {
 	}
 	return SchemaList
 }
+
+// TableDDLSQL 从MDS字典读取一张表的记录，参数依次为集群ID、库名、表名。
+// 各版本字典中存放建表语句的列名不同，取记录中以 CREATE TABLE 开头的列
+const TableDDLSQL = "select * from mds.dictionary_info where cluster_id=? and database_name=? and table_name=?"
+
+// GetTableDDL 从MDS字典读取表的建表语句，查询参数依次为集群ID、库名、表名。
+// query为空时查询 mds.dictionary_info，取以 CREATE TABLE 开头的列；否则使用配置的查询，取第一列
+func GetTableDDL(mds *sql.DB, query string, id string, schema string, table string) (string, error) {
+	custom := query != ""
+	if !custom {
+		query = TableDDLSQL
+	}
+	rows, err := mds.Query(query, id, schema, table)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		for i, v := range values {
+			if !v.Valid {
+				continue
+			}
+			if custom && i == 0 || !custom && isCreateTable(v.String) {
+				return v.String, nil
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("MDS字典中没有%s.%s的建表语句", schema, table)
+}
+
+// isCreateTable s是否为建表语句，忽略开头的空白和大小写
+func isCreateTable(s string) bool {
+	s = strings.TrimSpace(s)
+	return len(s) >= len("CREATE TABLE") && strings.EqualFold(s[:len("CREATE TABLE")], "CREATE TABLE")
+}
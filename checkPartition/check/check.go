@@ -9,12 +9,22 @@ import (
 )
 
 type ErrorInfo struct {
-	Cluster string
-	Schema  string
-	Table   string
-	Error   string
+	Cluster      string
+	Schema       string
+	Table        string
+	Check        string `json:",omitempty"` // 发现问题的检查: partition | schema
+	Error        string
+	Diffs        []Diff          `json:",omitempty"` // 表结构检查中各DN与多数DN不同的属性
+	ColumnOrders []OrderDiff     `json:",omitempty"` // 表结构检查中列顺序与多数DN不同的DN
+	Partitions   []PartitionDiff `json:",omitempty"` // 分区检查中有差异的DN
 }
 
+// 检查项
+const (
+	CheckPartition = "partition"
+	CheckSchema    = "schema"
+)
+
 type TableInfo struct {
 	Schema string
 	Name   string
@@ -46,64 +56,6 @@ func GetTables(dn *sql.DB, schema []string) []TableInfo {
 	return tables
 }
 
-func CheckTablePartitionsInfo(dnlist []*sql.DB, table string, schema string) bool {
-	fmt.Println("正在检查分区表：", table)
-	if len(dnlist) == 0 {
-		return false
-	}
-	var base []string
-	for i, dn := range dnlist {
-		parts := GetTablePartitionsInfo(dn, table, schema)
-		if len(parts) == 0 {
-			return false
-		}
-		if i == 0 {
-			base = parts
-			continue
-		}
-		if !equalOrder(base, parts) {
-			return false
-		}
-	}
-	return true
-}
-
-func equalOrder(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
-	}
-	return true
-}
-
-func GetTablePartitionsInfo(dn *sql.DB, table string, schema string) []string {
-	sql := "SELECT DISTINCT(PARTITION_NAME) FROM INFORMATION_SCHEMA.PARTITIONS WHERE  TABLE_NAME = ? AND TABLE_SCHEMA = ? AND PARTITION_NAME IS NOT NULL ORDER BY PARTITION_NAME "
-
-	rows, err := dn.Query(sql, table, schema)
-	if err != nil {
-		fmt.Println("查询分区信息失败：", err)
-		return []string{}
-	}
-	defer rows.Close()
-
-	var partitions []string
-
-	for rows.Next() {
-		var par string
-		err := rows.Scan(&par)
-		if err != nil {
-			fmt.Println("解析分区信息失败：", err)
-			return []string{}
-		}
-		partitions = append(partitions, par)
-	}
-	return partitions
-}
-
 func GenJson(errorInfo []ErrorInfo) {
 	name := time.Now().Format("20060102") + "-ddlerror.json"
 	b, err := json.MarshalIndent(errorInfo, "", "  ")
@@ -0,0 +1,226 @@
+package check
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// 分区方式在表级比较，每个分区比较边界值，子分区只比较是否存在。
+// 分区的位置不逐个比较，缺少一个分区会使其后所有分区的位置都不同，顺序由 ExpectedOrder/ActualOrder 单独报告
+var (
+	partitioningColumns = []string{"PARTITION_METHOD", "PARTITION_EXPRESSION", "SUBPARTITION_METHOD", "SUBPARTITION_EXPRESSION"}
+	partitionColumns    = []string{"PARTITION_DESCRIPTION"}
+)
+
+// PartitionDiff 一个DN上的分区与参照DN（多数DN）的差异
+type PartitionDiff struct {
+	DN              string
+	Group           string   `json:",omitempty"`
+	Missing         []string `json:",omitempty"` // 参照DN有而该DN没有的分区
+	Extra           []string `json:",omitempty"` // 该DN多出的分区
+	NoMajority      []string `json:",omitempty"` // Missing、Extra中没有严格多数的分区，是否存在取自排在前面的DN
+	ExpectedOrder   []string `json:",omitempty"` // 顺序不同时，双方共有的分区在参照DN上的顺序
+	ActualOrder     []string `json:",omitempty"` // 顺序不同时，双方共有的分区在该DN上的顺序
+	OrderNoMajority bool     `json:",omitempty"` // 分区顺序没有严格多数，ExpectedOrder取自排在前面的DN
+	Diffs           []Diff   `json:",omitempty"` // 分区是否存在以外的其它不同属性
+}
+
+// TablePartitions 一张分区表在各DN上的分区定义
+type TablePartitions struct {
+	Schema string
+	Table  string
+	dns    []DN
+	attrs  []tableAttrs
+	orders [][]string // 各DN上按位置排列的分区名
+	ref    *reference // MDS字典中的分区定义，为nil时以多数DN为准
+}
+
+// LoadTablePartitions 读取分区表在各DN上的分区定义：分区方式和表达式、各分区的边界值和顺序、子分区布局。
+// 属性路径如 partitioning.PARTITION_METHOD、partition.p1.PARTITION_DESCRIPTION、partition.p1.subpartition.p1sp0
+func LoadTablePartitions(dns []DN, table string, schema string) (*TablePartitions, error) {
+	t := &TablePartitions{
+		Schema: schema,
+		Table:  table,
+		dns:    dns,
+		attrs:  make([]tableAttrs, len(dns)),
+		orders: make([][]string, len(dns)),
+	}
+	for i, dn := range dns {
+		a, order, err := loadPartitionAttrs(dn.DB, table, schema)
+		if err != nil {
+			return nil, fmt.Errorf("读取DN %s 的%s.%s分区信息失败: %w", dn.Addr, schema, table, err)
+		}
+		t.attrs[i], t.orders[i] = a, order
+	}
+	return t, nil
+}
+
+// Diffs 以多数DN或 SetReference 设置的参照为准返回有差异的DN，按DN顺序排列
+func (t *TablePartitions) Diffs() []PartitionDiff {
+	result := make([]PartitionDiff, len(t.dns))
+	index := map[string]int{}
+	for i, dn := range t.dns {
+		result[i] = PartitionDiff{DN: dn.Addr, Group: dn.Group}
+		index[dn.Addr] = i
+	}
+	for _, d := range diffAttrs(t.dns, t.attrs, partitionParent, t.ref) {
+		r := &result[index[d.DN]]
+		if !isPartitionKey(d.Attribute) {
+			r.Diffs = append(r.Diffs, d)
+			continue
+		}
+		name := strings.TrimPrefix(d.Attribute, "partition.")
+		if d.Actual == valueMissing {
+			r.Missing = append(r.Missing, name)
+		} else {
+			r.Extra = append(r.Extra, name)
+		}
+		if d.NoMajority {
+			r.NoMajority = append(r.NoMajority, name)
+		}
+	}
+
+	// 以参照的分区顺序为准，只比较双方共有的分区，缺少或多出的分区已单独列出
+	expected, strict := t.expectedOrder()
+	for i := range t.dns {
+		want, got := common(expected, t.orders[i]), common(t.orders[i], expected)
+		if strings.Join(want, ",") != strings.Join(got, ",") {
+			result[i].ExpectedOrder, result[i].ActualOrder = want, got
+			result[i].OrderNoMajority = !strict
+		}
+	}
+
+	var diffs []PartitionDiff
+	for _, r := range result {
+		if len(r.Missing)+len(r.Extra)+len(r.ActualOrder)+len(r.Diffs) > 0 {
+			diffs = append(diffs, r)
+		}
+	}
+	return diffs
+}
+
+// expectedOrder 参照的分区顺序，strict含义同majority
+func (t *TablePartitions) expectedOrder() (order []string, strict bool) {
+	if t.ref != nil {
+		return t.ref.order, true
+	}
+	holders := make([]int, len(t.dns))
+	joined := make([]string, len(t.dns))
+	for i := range t.dns {
+		holders[i] = i
+		joined[i] = strings.Join(t.orders[i], ",")
+	}
+	s, strict := majority(holders, joined)
+	if s != "" {
+		order = strings.Split(s, ",")
+	}
+	return order, strict
+}
+
+// expected 属性的参照值。以多数DN为准时只统计有该属性所属对象的DN，strict含义同majority
+func (t *TablePartitions) expected(key string) (value string, strict bool) {
+	if v, ok := t.ref.value(key); ok {
+		return v, true
+	}
+	owner := partitionParent(key)
+	var holders []int
+	values := make([]string, len(t.dns))
+	for i, a := range t.attrs {
+		if _, ok := a[owner]; owner != "" && !ok {
+			continue
+		}
+		v, ok := a[key]
+		if !ok {
+			v = valueMissing
+		}
+		holders = append(holders, i)
+		values[i] = v
+	}
+	return majority(holders, values)
+}
+
+// isPartitionKey 是否为表示分区是否存在的属性 partition.<分区名>
+func isPartitionKey(key string) bool {
+	return strings.HasPrefix(key, "partition.") && partitionParent(key) == "table"
+}
+
+// common 返回a中同时在b中的元素，保持a的顺序
+func common(a, b []string) []string {
+	in := map[string]bool{}
+	for _, s := range b {
+		in[s] = true
+	}
+	var c []string
+	for _, s := range a {
+		if in[s] {
+			c = append(c, s)
+		}
+	}
+	return c
+}
+
+// loadPartitionAttrs 读取一张表的分区定义和按位置排列的分区名。表不存在时返回空，未分区时只有 table
+func loadPartitionAttrs(dn *sql.DB, table string, schema string) (tableAttrs, []string, error) {
+	columns := []string{"PARTITION_NAME", "SUBPARTITION_NAME"}
+	columns = append(columns, partitioningColumns...)
+	columns = append(columns, partitionColumns...)
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM INFORMATION_SCHEMA.PARTITIONS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?" +
+		" ORDER BY PARTITION_ORDINAL_POSITION, SUBPARTITION_ORDINAL_POSITION"
+
+	a := tableAttrs{}
+	var order []string
+	err := queryRows(dn, query, func(v []string) {
+		a["table"] = valueExists
+		if v[0] == valueNull {
+			// 未分区的表只有一行且分区名为NULL
+			return
+		}
+		// 按查询的列顺序依次取值
+		next := 2
+		take := func(prefix string, names []string) {
+			for _, c := range names {
+				a[prefix+c] = v[next]
+				next++
+			}
+		}
+		take("partitioning.", partitioningColumns)
+		partition := "partition." + v[0]
+		if _, ok := a[partition]; !ok {
+			a[partition] = valueExists
+			order = append(order, v[0])
+		}
+		take(partition+".", partitionColumns)
+		if v[1] != valueNull {
+			a[partition+".subpartition."+v[1]] = valueExists
+		}
+	}, schema, table)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, order, nil
+}
+
+// partitionParent 分区属性所属对象的存在性键，对象本身返回空
+func partitionParent(key string) string {
+	const sub = ".subpartition."
+	switch {
+	case key == "table":
+		return ""
+	case strings.HasPrefix(key, "partitioning."):
+		return "table"
+	case strings.HasPrefix(key, "partition."):
+		if i := strings.LastIndex(key, sub); i >= 0 {
+			if j := strings.LastIndex(key[i+len(sub):], "."); j >= 0 {
+				return key[:i+len(sub)+j]
+			}
+			return key[:i]
+		}
+		name := strings.TrimPrefix(key, "partition.")
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			return "partition." + name[:i]
+		}
+		return "table"
+	}
+	return ""
+}
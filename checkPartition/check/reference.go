@@ -0,0 +1,376 @@
+package check
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 分区比较和修复的参照
+const (
+	ReferenceMajority = "majority" // 多数DN的分区定义
+	ReferenceMDS      = "mds"      // MDS字典中记录的建表语句
+)
+
+// reference MDS字典中的分区定义，属性路径与 loadPartitionAttrs 相同
+type reference struct {
+	attrs tableAttrs
+	order []string // 按定义顺序排列的分区名
+}
+
+// value 属性的参照值。分区表达式在建表语句和 INFORMATION_SCHEMA 中的写法不同，
+// 不作为参照，此时ok为false，仍以多数DN为准；r为nil时同样返回false
+func (r *reference) value(key string) (v string, ok bool) {
+	if r == nil || strings.HasSuffix(key, "_EXPRESSION") {
+		return "", false
+	}
+	if v, ok := r.attrs[key]; ok {
+		return v, true
+	}
+	return valueMissing, true
+}
+
+// SetReference 以MDS字典中的建表语句为参照，之后 Diffs 和 Repairs 不再以多数DN为准
+func (t *TablePartitions) SetReference(ddl string) error {
+	ref, err := parsePartitionDDL(ddl)
+	if err != nil {
+		return fmt.Errorf("解析MDS字典中%s.%s的建表语句失败: %w", t.Schema, t.Table, err)
+	}
+	t.ref = ref
+	return nil
+}
+
+// parsePartitionDDL 从建表语句中解析分区方式、分区名、边界值和子分区名。
+// 边界值去掉括号和引号外的空白后按文本比较，与 INFORMATION_SCHEMA.PARTITIONS 中的 PARTITION_DESCRIPTION 一致
+func parsePartitionDDL(ddl string) (*reference, error) {
+	p := &ddlParser{tokens: tokenize(ddl)}
+	ref := &reference{attrs: tableAttrs{"table": valueExists}}
+	if !p.find("PARTITION", "BY") {
+		// 未分区的表
+		return ref, nil
+	}
+	method, err := p.method()
+	if err != nil {
+		return nil, err
+	}
+	ref.attrs["partitioning.PARTITION_METHOD"] = method
+	ref.attrs["partitioning.SUBPARTITION_METHOD"] = valueNull
+	p.group()
+
+	partitions, subpartitions := 0, 0
+	if p.accept("PARTITIONS") {
+		if partitions, err = p.count(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("SUBPARTITION", "BY") {
+		sub, err := p.method()
+		if err != nil {
+			return nil, err
+		}
+		ref.attrs["partitioning.SUBPARTITION_METHOD"] = sub
+		p.group()
+		if p.accept("SUBPARTITIONS") {
+			if subpartitions, err = p.count(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	add := func(name string) string {
+		partition := "partition." + name
+		ref.attrs[partition] = valueExists
+		// HASH、KEY 分区没有边界值，INFORMATION_SCHEMA 中为NULL；有 VALUES 时再覆盖
+		ref.attrs[partition+".PARTITION_DESCRIPTION"] = valueNull
+		ref.order = append(ref.order, name)
+		// 只给出子分区数量时，子分区名为 <分区名>sp<序号>
+		for i := 0; i < subpartitions; i++ {
+			ref.attrs[fmt.Sprintf("%s.subpartition.%ssp%d", partition, name, i)] = valueExists
+		}
+		return partition
+	}
+	if !p.accept("(") {
+		// 只给出分区数量时，分区名为 p<序号>
+		for i := 0; i < partitions; i++ {
+			add(fmt.Sprintf("p%d", i))
+		}
+		return ref, nil
+	}
+	for {
+		if !p.accept("PARTITION") {
+			return nil, fmt.Errorf("分区定义应以 PARTITION 开始，实际为 %q", p.peek())
+		}
+		name := p.next()
+		partition := add(name.text)
+		if p.accept("VALUES") {
+			desc, err := p.values()
+			if err != nil {
+				return nil, fmt.Errorf("分区 %s: %w", name.text, err)
+			}
+			ref.attrs[partition+".PARTITION_DESCRIPTION"] = desc
+		}
+		p.options()
+		if p.accept("(") {
+			// 明确列出的子分区替代按数量生成的子分区名
+			for key := range ref.attrs {
+				if strings.HasPrefix(key, partition+".subpartition.") {
+					delete(ref.attrs, key)
+				}
+			}
+			for {
+				if !p.accept("SUBPARTITION") {
+					return nil, fmt.Errorf("子分区定义应以 SUBPARTITION 开始，实际为 %q", p.peek())
+				}
+				ref.attrs[partition+".subpartition."+p.next().text] = valueExists
+				p.options()
+				if !p.accept(",") {
+					break
+				}
+			}
+			if !p.accept(")") {
+				return nil, fmt.Errorf("分区 %s 的子分区定义没有结束", name.text)
+			}
+			p.options()
+		}
+		if p.accept(")") {
+			return ref, nil
+		}
+		if !p.accept(",") {
+			return nil, fmt.Errorf("分区 %s 之后应为逗号或右括号，实际为 %q", name.text, p.peek())
+		}
+	}
+}
+
+// token 建表语句中的一个词。quoted为true表示反引号引用的标识符，text中已去掉反引号
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize 把SQL拆分为词，跳过空白和注释；版本注释 /*!50100 ... */ 中的内容照常保留
+func tokenize(s string) []token {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "/*!"):
+			i += 3
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			if end := strings.Index(s[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(s)
+			}
+		case strings.HasPrefix(s[i:], "*/"):
+			i += 2
+		case strings.HasPrefix(s[i:], "-- ") || c == '#':
+			if end := strings.IndexByte(s[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(s)
+			}
+		case c == '`':
+			var b strings.Builder
+			i++
+			for i < len(s) {
+				if s[i] == '`' {
+					if i+1 < len(s) && s[i+1] == '`' {
+						b.WriteByte('`')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+		case c == '\'' || c == '"':
+			// 字符串保留引号和转义，按原文比较
+			start := i
+			i++
+			for i < len(s) {
+				if s[i] == '\\' {
+					i += 2
+					continue
+				}
+				if s[i] == c {
+					if i+1 < len(s) && s[i+1] == c {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, token{text: s[start:min(i, len(s))]})
+		case isWordByte(c):
+			start := i
+			for i < len(s) && isWordByte(s[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: s[start:i]})
+		default:
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// ddlParser 按顺序读取建表语句的词
+type ddlParser struct {
+	tokens []token
+	pos    int
+}
+
+// peek 当前的词，已读完时为空
+func (p *ddlParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *ddlParser) next() token {
+	if p.pos < len(p.tokens) {
+		p.pos++
+		return p.tokens[p.pos-1]
+	}
+	return token{}
+}
+
+// is 第i个之后的词是否为未引用的关键字或符号words，不区分大小写
+func (p *ddlParser) is(i int, words ...string) bool {
+	for j, w := range words {
+		k := i + j
+		if k >= len(p.tokens) || p.tokens[k].quoted || !strings.EqualFold(p.tokens[k].text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// accept 当前的词为words时读过并返回true
+func (p *ddlParser) accept(words ...string) bool {
+	if p.is(p.pos, words...) {
+		p.pos += len(words)
+		return true
+	}
+	return false
+}
+
+// find 跳到words之后，找不到时返回false
+func (p *ddlParser) find(words ...string) bool {
+	for i := p.pos; i < len(p.tokens); i++ {
+		if p.is(i, words...) {
+			p.pos = i + len(words)
+			return true
+		}
+	}
+	return false
+}
+
+// method 分区方式，与 INFORMATION_SCHEMA 中的写法相同，如 RANGE COLUMNS、LINEAR HASH
+func (p *ddlParser) method() (string, error) {
+	var words []string
+	if p.accept("LINEAR") {
+		words = append(words, "LINEAR")
+	}
+	switch m := strings.ToUpper(p.peek()); m {
+	case "RANGE", "LIST":
+		p.next()
+		words = append(words, m)
+		if p.accept("COLUMNS") {
+			words = append(words, "COLUMNS")
+		}
+	case "HASH", "KEY":
+		p.next()
+		words = append(words, m)
+		if m == "KEY" && p.accept("ALGORITHM", "=") {
+			p.next()
+		}
+	default:
+		return "", fmt.Errorf("不支持的分区方式 %q", p.peek())
+	}
+	return strings.Join(words, " "), nil
+}
+
+// group 跳过一对括号及其中的内容，当前不是左括号时不处理
+func (p *ddlParser) group() []token {
+	if !p.is(p.pos, "(") {
+		return nil
+	}
+	start, depth := p.pos+1, 0
+	for p.pos < len(p.tokens) {
+		t := p.next()
+		if t.quoted {
+			continue
+		}
+		switch t.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return p.tokens[start : p.pos-1]
+			}
+		}
+	}
+	return p.tokens[start:]
+}
+
+// count PARTITIONS、SUBPARTITIONS 之后的数量
+func (p *ddlParser) count() (int, error) {
+	n, err := strconv.Atoi(p.next().text)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("无效的分区数量")
+	}
+	return n, nil
+}
+
+// values VALUES 之后的边界值：LESS THAN (...)、LESS THAN MAXVALUE 或 IN (...)
+func (p *ddlParser) values() (string, error) {
+	switch {
+	case p.accept("LESS", "THAN"):
+		if p.accept("MAXVALUE") {
+			return "MAXVALUE", nil
+		}
+	case p.accept("IN"):
+	default:
+		return "", fmt.Errorf("无法识别的边界值 %q", p.peek())
+	}
+	if !p.is(p.pos, "(") {
+		return "", fmt.Errorf("边界值应在括号中")
+	}
+	var b strings.Builder
+	for _, t := range p.group() {
+		switch {
+		case t.quoted:
+			b.WriteString(quote(t.text))
+		case strings.EqualFold(t.text, "MAXVALUE"):
+			b.WriteString("MAXVALUE")
+		default:
+			b.WriteString(t.text)
+		}
+	}
+	return b.String(), nil
+}
+
+// options 跳过分区的选项，如 ENGINE = InnoDB、COMMENT = '...'，停在逗号、括号处
+func (p *ddlParser) options() {
+	for p.pos < len(p.tokens) && !p.is(p.pos, ",") && !p.is(p.pos, "(") && !p.is(p.pos, ")") {
+		p.next()
+	}
+}
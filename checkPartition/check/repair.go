@@ -0,0 +1,198 @@
+package check
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Repair 一个DN上一张表的分区修复语句，以多数DN或MDS字典中的分区定义为准
+type Repair struct {
+	DN         DN
+	Schema     string
+	Table      string
+	Statements []Statement // 按顺序执行
+	Notes      []string    // 无法自动修复、需人工处理的差异
+}
+
+// Statement 一条修复DDL
+type Statement struct {
+	SQL     string
+	Warning string // 执行前需注意的事项，如会删除数据
+}
+
+// Repairs 为与参照不同的DN生成修复语句，只处理RANGE、LIST分区缺少或多出的分区：
+//   - 缺少的分区在最后时 ADD PARTITION，在中间时 REORGANIZE 其后的分区拆出
+//   - RANGE多出的分区 REORGANIZE 并入其后的分区，数据保留；没有其后的分区时 DROP
+//   - LIST多出的分区 DROP
+//
+// DROP PARTITION 会删除分区中的数据，语句带有 Warning。分区方式、边界值、顺序、子分区不同时只记录在 Notes 中。
+// 以多数DN为准而所需的参照值没有严格多数时（如两个DN各不相同），无法判断哪一方正确，不生成语句
+func (t *TablePartitions) Repairs() []Repair {
+	var repairs []Repair
+	for _, d := range t.Diffs() {
+		for i, dn := range t.dns {
+			if dn.Addr == d.DN {
+				r := t.repair(i, d)
+				if len(r.Statements)+len(r.Notes) > 0 {
+					repairs = append(repairs, r)
+				}
+				break
+			}
+		}
+	}
+	return repairs
+}
+
+func (t *TablePartitions) repair(i int, d PartitionDiff) Repair {
+	r := Repair{DN: t.dns[i], Schema: t.Schema, Table: t.Table}
+	if t.noMajority(d) {
+		r.Notes = append(r.Notes, "无多数，需指定参照")
+		return r
+	}
+	a := t.attrs[i]
+	method, _ := t.expected("partitioning.PARTITION_METHOD")
+	switch {
+	case len(a) == 0:
+		r.Notes = append(r.Notes, "表不存在")
+		return r
+	case a["partitioning.PARTITION_METHOD"] != method:
+		r.Notes = append(r.Notes, fmt.Sprintf("分区方式为 %s，参照值为 %s", orMissing(a["partitioning.PARTITION_METHOD"]), method))
+		return r
+	case len(d.ActualOrder) > 0:
+		r.Notes = append(r.Notes, fmt.Sprintf("分区顺序为 %s，参照顺序为 %s",
+			strings.Join(d.ActualOrder, ", "), strings.Join(d.ExpectedOrder, ", ")))
+		return r
+	}
+	for _, diff := range d.Diffs {
+		r.Notes = append(r.Notes, fmt.Sprintf("%s 为 %s，参照值为 %s", diff.Attribute, diff.Actual, diff.Expected))
+	}
+	if len(d.Missing)+len(d.Extra) == 0 {
+		return r
+	}
+
+	var values string
+	switch method {
+	case "RANGE", "RANGE COLUMNS":
+		values = "VALUES LESS THAN"
+	case "LIST", "LIST COLUMNS":
+		values = "VALUES IN"
+	default:
+		r.Notes = append(r.Notes, fmt.Sprintf("%s 分区的缺少或多出分区需人工处理", method))
+		return r
+	}
+	if sub, _ := t.expected("partitioning.SUBPARTITION_METHOD"); sub != valueNull {
+		r.Notes = append(r.Notes, "含子分区的表需人工处理缺少或多出的分区")
+		return r
+	}
+
+	name := quote(t.Schema) + "." + quote(t.Table)
+	define := func(p, desc string) string {
+		return fmt.Sprintf("PARTITION %s %s (%s)", quote(p), values, desc)
+	}
+	own := func(p string) string {
+		return define(p, a["partition."+p+".PARTITION_DESCRIPTION"])
+	}
+	add := func(format string, args ...interface{}) {
+		r.Statements = append(r.Statements, Statement{SQL: fmt.Sprintf(format, args...)})
+	}
+
+	// 先处理多出的分区
+	extra := toSet(d.Extra)
+	var current, pending []string
+	for _, p := range t.orders[i] {
+		if extra[p] {
+			pending = append(pending, p)
+			continue
+		}
+		if len(pending) > 0 && values == "VALUES LESS THAN" {
+			// 多出的分区与其后的分区合并，范围不变，数据保留
+			add("ALTER TABLE %s REORGANIZE PARTITION %s INTO (%s)", name, quoteList(append(pending, p)), own(p))
+			pending = nil
+		}
+		current = append(current, p)
+	}
+	if len(pending) > 0 {
+		r.Statements = append(r.Statements, Statement{
+			SQL:     fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", name, quoteList(pending)),
+			Warning: fmt.Sprintf("DROP PARTITION 会删除分区 %s 中的数据，请先确认已备份或数据可丢弃", strings.Join(pending, ", ")),
+		})
+	}
+
+	// 再按参照的顺序补上缺少的分区
+	pending = nil
+	exists := toSet(current)
+	var defs []string
+	order, _ := t.expectedOrder()
+	for _, p := range order {
+		if !exists[p] {
+			desc, _ := t.expected("partition." + p + ".PARTITION_DESCRIPTION")
+			if desc == valueMissing || desc == valueNull {
+				r.Notes = append(r.Notes, fmt.Sprintf("分区 %s 在参照中没有边界值，需人工处理", p))
+				continue
+			}
+			pending = append(pending, p)
+			defs = append(defs, define(p, desc))
+			continue
+		}
+		if len(pending) > 0 {
+			if values == "VALUES LESS THAN" {
+				// 缺少的分区在中间，从其后的分区拆出
+				add("ALTER TABLE %s REORGANIZE PARTITION %s INTO (%s, %s)", name, quote(p), strings.Join(defs, ", "), own(p))
+			} else {
+				add("ALTER TABLE %s ADD PARTITION (%s)", name, strings.Join(defs, ", "))
+			}
+			pending, defs = nil, nil
+		}
+	}
+	if len(pending) > 0 {
+		add("ALTER TABLE %s ADD PARTITION (%s)", name, strings.Join(defs, ", "))
+	}
+	return r
+}
+
+// noMajority 修复该DN所依据的参照值中是否有不是严格多数的
+func (t *TablePartitions) noMajority(d PartitionDiff) bool {
+	if len(d.NoMajority) > 0 || d.OrderNoMajority {
+		return true
+	}
+	for _, diff := range d.Diffs {
+		if diff.NoMajority {
+			return true
+		}
+	}
+	// 缺少的分区按参照的边界值补上
+	for _, p := range d.Missing {
+		if _, strict := t.expected("partition." + p + ".PARTITION_DESCRIPTION"); !strict {
+			return true
+		}
+	}
+	return false
+}
+
+func orMissing(s string) string {
+	if s == "" {
+		return valueMissing
+	}
+	return s
+}
+
+// quote 用反引号引用标识符
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = quote(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func toSet(list []string) map[string]bool {
+	set := map[string]bool{}
+	for _, s := range list {
+		set[s] = true
+	}
+	return set
+}
@@ -0,0 +1,268 @@
+package check
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// partitioned 按分区名、边界值依次给出的分区，生成与 loadPartitionAttrs 相同的分区定义
+func partitioned(method string, parts ...string) (tableAttrs, []string) {
+	a := tableAttrs{
+		"table":                                valueExists,
+		"partitioning.PARTITION_METHOD":        method,
+		"partitioning.PARTITION_EXPRESSION":    "`id`",
+		"partitioning.SUBPARTITION_METHOD":     valueNull,
+		"partitioning.SUBPARTITION_EXPRESSION": valueNull,
+	}
+	var order []string
+	for i := 0; i+1 < len(parts); i += 2 {
+		a["partition."+parts[i]] = valueExists
+		a["partition."+parts[i]+".PARTITION_DESCRIPTION"] = parts[i+1]
+		order = append(order, parts[i])
+	}
+	return a, order
+}
+
+// newTable 各DN的分区定义，DN地址依次为 dn1、dn2 ...
+func newTable(defs ...func() (tableAttrs, []string)) *TablePartitions {
+	t := &TablePartitions{Schema: "db", Table: "t"}
+	for i, def := range defs {
+		a, order := def()
+		t.dns = append(t.dns, DN{Addr: "dn" + string(rune('1'+i)), Group: "g" + string(rune('1'+i))})
+		t.attrs = append(t.attrs, a)
+		t.orders = append(t.orders, order)
+	}
+	return t
+}
+
+func table(method string, parts ...string) func() (tableAttrs, []string) {
+	return func() (tableAttrs, []string) { return partitioned(method, parts...) }
+}
+
+// statements 按DN汇总修复语句
+func statements(repairs []Repair) map[string][]string {
+	m := map[string][]string{}
+	for _, r := range repairs {
+		for _, s := range r.Statements {
+			m[r.DN.Addr] = append(m[r.DN.Addr], s.SQL)
+		}
+	}
+	return m
+}
+
+func TestRepairRangeMissingMiddle(t *testing.T) {
+	full := table("RANGE", "p0", "10", "p1", "20", "p2", "30", "p3", "MAXVALUE")
+	tp := newTable(full, full, table("RANGE", "p0", "10", "p2", "30", "p3", "MAXVALUE"))
+	want := map[string][]string{
+		"dn3": {"ALTER TABLE `db`.`t` REORGANIZE PARTITION `p2` INTO (PARTITION `p1` VALUES LESS THAN (20), PARTITION `p2` VALUES LESS THAN (30))"},
+	}
+	if got := statements(tp.Repairs()); !reflect.DeepEqual(got, want) {
+		t.Errorf("修复语句 = %q, 期望 %q", got, want)
+	}
+}
+
+func TestRepairRangeMissingLast(t *testing.T) {
+	full := table("RANGE", "p0", "10", "p1", "20", "p2", "MAXVALUE")
+	tp := newTable(full, table("RANGE", "p0", "10", "p1", "20"), full)
+	want := map[string][]string{
+		"dn2": {"ALTER TABLE `db`.`t` ADD PARTITION (PARTITION `p2` VALUES LESS THAN (MAXVALUE))"},
+	}
+	if got := statements(tp.Repairs()); !reflect.DeepEqual(got, want) {
+		t.Errorf("修复语句 = %q, 期望 %q", got, want)
+	}
+}
+
+func TestRepairListExtra(t *testing.T) {
+	full := table("LIST", "p0", "1,2", "p1", "3,4")
+	tp := newTable(full, full, table("LIST", "p0", "1,2", "px", "9", "p1", "3,4"))
+	repairs := tp.Repairs()
+	want := map[string][]string{
+		"dn3": {"ALTER TABLE `db`.`t` DROP PARTITION `px`"},
+	}
+	if got := statements(repairs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("修复语句 = %q, 期望 %q", got, want)
+	}
+	if w := repairs[0].Statements[0].Warning; !strings.Contains(w, "px") {
+		t.Errorf("DROP PARTITION 应提示删除数据，实际为 %q", w)
+	}
+}
+
+// 两个DN各不相同时没有严格多数，无论哪一方缺少分区都不能生成语句，否则可能删除正常DN上的分区
+func TestRepairTie(t *testing.T) {
+	full := table("RANGE", "p0", "10", "p1", "20", "p2", "MAXVALUE")
+	short := table("RANGE", "p0", "10", "p1", "20")
+	for name, tp := range map[string]*TablePartitions{
+		"排在前面的DN缺少分区": newTable(short, full),
+		"排在后面的DN缺少分区": newTable(full, short),
+	} {
+		t.Run(name, func(t *testing.T) {
+			repairs := tp.Repairs()
+			if len(repairs) != 1 {
+				t.Fatalf("应有1个DN需要处理，实际为 %d", len(repairs))
+			}
+			r := repairs[0]
+			if len(r.Statements) != 0 {
+				t.Errorf("没有严格多数时不应生成语句，实际为 %q", statements(repairs))
+			}
+			if !reflect.DeepEqual(r.Notes, []string{"无多数，需指定参照"}) {
+				t.Errorf("Notes = %q", r.Notes)
+			}
+		})
+	}
+}
+
+// 以MDS字典为参照时，两个DN的差异可以确定哪一方需要修复
+func TestRepairTieWithReference(t *testing.T) {
+	tp := newTable(
+		table("RANGE", "p0", "10", "p1", "20"),
+		table("RANGE", "p0", "10", "p1", "20", "p2", "MAXVALUE"),
+	)
+	err := tp.SetReference("CREATE TABLE `t` (`id` int NOT NULL) ENGINE=InnoDB\n" +
+		"/*!50100 PARTITION BY RANGE (`id`)\n" +
+		"(PARTITION p0 VALUES LESS THAN (10) ENGINE = InnoDB,\n" +
+		" PARTITION p1 VALUES LESS THAN (20) ENGINE = InnoDB,\n" +
+		" PARTITION p2 VALUES LESS THAN MAXVALUE ENGINE = InnoDB) */")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"dn1": {"ALTER TABLE `db`.`t` ADD PARTITION (PARTITION `p2` VALUES LESS THAN (MAXVALUE))"},
+	}
+	repairs := tp.Repairs()
+	if got := statements(repairs); !reflect.DeepEqual(got, want) {
+		t.Errorf("修复语句 = %q, 期望 %q", got, want)
+	}
+	for _, r := range repairs {
+		if len(r.Notes) > 0 {
+			t.Errorf("DN %s 不应有需人工处理的差异: %q", r.DN.Addr, r.Notes)
+		}
+	}
+}
+
+// HASH、KEY 分区没有边界值，各DN的 PARTITION_DESCRIPTION 均为NULL，与MDS字典一致时不应报差异
+func TestRepairHashWithReference(t *testing.T) {
+	tests := []struct {
+		name string
+		def  func() (tableAttrs, []string)
+		ddl  string
+	}{
+		{
+			name: "HASH只给出分区数量",
+			def:  table("HASH", "p0", valueNull, "p1", valueNull),
+			ddl:  "CREATE TABLE `t` (`id` int NOT NULL) ENGINE=InnoDB\n/*!50100 PARTITION BY HASH (`id`)\nPARTITIONS 2 */",
+		},
+		{
+			name: "KEY列出分区名",
+			def:  table("KEY", "a", valueNull, "b", valueNull),
+			ddl:  "CREATE TABLE `t` (`id` int NOT NULL) ENGINE=InnoDB\n/*!50100 PARTITION BY KEY (`id`)\n(PARTITION a ENGINE = InnoDB,\n PARTITION b ENGINE = InnoDB) */",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := newTable(tt.def, tt.def)
+			if err := tp.SetReference(tt.ddl); err != nil {
+				t.Fatal(err)
+			}
+			if diffs := tp.Diffs(); len(diffs) != 0 {
+				t.Errorf("不应有差异，实际为 %+v", diffs)
+			}
+			if repairs := tp.Repairs(); len(repairs) != 0 {
+				t.Errorf("不应有修复，实际为 %+v", repairs)
+			}
+		})
+	}
+}
+
+func TestParsePartitionDDL(t *testing.T) {
+	tests := []struct {
+		name  string
+		ddl   string
+		attrs tableAttrs
+		order []string
+	}{
+		{
+			name:  "未分区",
+			ddl:   "CREATE TABLE `t` (`id` int, `c` varchar(10) COMMENT 'partition by x')",
+			attrs: tableAttrs{"table": valueExists},
+		},
+		{
+			name: "RANGE COLUMNS",
+			ddl: "CREATE TABLE t (dt date)\n/*!50500 PARTITION BY RANGE  COLUMNS(dt)\n" +
+				"(PARTITION `p2024` VALUES LESS THAN ('2025-01-01') ENGINE = InnoDB,\n" +
+				" PARTITION pmax VALUES LESS THAN (MAXVALUE) ENGINE = InnoDB) */",
+			attrs: tableAttrs{
+				"table":                                 valueExists,
+				"partitioning.PARTITION_METHOD":         "RANGE COLUMNS",
+				"partitioning.SUBPARTITION_METHOD":      valueNull,
+				"partition.p2024":                       valueExists,
+				"partition.p2024.PARTITION_DESCRIPTION": "'2025-01-01'",
+				"partition.pmax":                        valueExists,
+				"partition.pmax.PARTITION_DESCRIPTION":  "MAXVALUE",
+			},
+			order: []string{"p2024", "pmax"},
+		},
+		{
+			name: "LIST",
+			ddl:  "create table t (id int) partition by list (id) (partition p0 values in (1, 2, 3) comment = 'a, b', partition p1 values in (-1))",
+			attrs: tableAttrs{
+				"table":                              valueExists,
+				"partitioning.PARTITION_METHOD":      "LIST",
+				"partitioning.SUBPARTITION_METHOD":   valueNull,
+				"partition.p0":                       valueExists,
+				"partition.p0.PARTITION_DESCRIPTION": "1,2,3",
+				"partition.p1":                       valueExists,
+				"partition.p1.PARTITION_DESCRIPTION": "-1",
+			},
+			order: []string{"p0", "p1"},
+		},
+		{
+			name: "HASH只给出分区数量",
+			ddl:  "CREATE TABLE t (id int) PARTITION BY LINEAR HASH (id) PARTITIONS 2",
+			attrs: tableAttrs{
+				"table":                              valueExists,
+				"partitioning.PARTITION_METHOD":      "LINEAR HASH",
+				"partitioning.SUBPARTITION_METHOD":   valueNull,
+				"partition.p0":                       valueExists,
+				"partition.p0.PARTITION_DESCRIPTION": valueNull,
+				"partition.p1":                       valueExists,
+				"partition.p1.PARTITION_DESCRIPTION": valueNull,
+			},
+			order: []string{"p0", "p1"},
+		},
+		{
+			name: "子分区",
+			ddl: "CREATE TABLE t (id int, dt date) PARTITION BY RANGE (YEAR(dt)) SUBPARTITION BY KEY ALGORITHM = 2 (id) SUBPARTITIONS 2 (" +
+				"PARTITION p0 VALUES LESS THAN (2024), " +
+				"PARTITION p1 VALUES LESS THAN MAXVALUE (SUBPARTITION s0 ENGINE = InnoDB, SUBPARTITION s1))",
+			attrs: tableAttrs{
+				"table":                              valueExists,
+				"partitioning.PARTITION_METHOD":      "RANGE",
+				"partitioning.SUBPARTITION_METHOD":   "KEY",
+				"partition.p0":                       valueExists,
+				"partition.p0.PARTITION_DESCRIPTION": "2024",
+				"partition.p0.subpartition.p0sp0":    valueExists,
+				"partition.p0.subpartition.p0sp1":    valueExists,
+				"partition.p1":                       valueExists,
+				"partition.p1.PARTITION_DESCRIPTION": "MAXVALUE",
+				"partition.p1.subpartition.s0":       valueExists,
+				"partition.p1.subpartition.s1":       valueExists,
+			},
+			order: []string{"p0", "p1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := parsePartitionDDL(tt.ddl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ref.attrs, tt.attrs) {
+				t.Errorf("attrs = %v, 期望 %v", ref.attrs, tt.attrs)
+			}
+			if !reflect.DeepEqual(ref.order, tt.order) {
+				t.Errorf("order = %v, 期望 %v", ref.order, tt.order)
+			}
+		})
+	}
+}
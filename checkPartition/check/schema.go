@@ -0,0 +1,320 @@
+package check
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DN 一个主DN的连接
+type DN struct {
+	Addr  string // host:port
+	Group string // DN组ID
+	DB    *sql.DB
+}
+
+// Diff 一个DN上与参照值不同的属性
+type Diff struct {
+	DN         string
+	Attribute  string // 属性路径，如 table.ENGINE、column.c1.COLUMN_TYPE、partition.p1.PARTITION_DESCRIPTION
+	Expected   string // 参照值，即多数DN的值
+	Actual     string
+	NoMajority bool `json:",omitempty"` // 没有严格多数，Expected取自数量最多的值中排在前面的DN
+}
+
+// TableDiff 一张表在各DN上的差异
+type TableDiff struct {
+	Table        string
+	Diffs        []Diff
+	ColumnOrders []OrderDiff `json:",omitempty"` // 列顺序与参照不同的DN
+}
+
+// OrderDiff 一个DN上的列顺序与参照不同，只列出双方共有的列，缺少或多出的列已在Diffs中报告
+type OrderDiff struct {
+	DN         string
+	Expected   []string // 参照DN上的顺序
+	Actual     []string // 该DN上的顺序
+	NoMajority bool     `json:",omitempty"` // 列顺序没有严格多数，Expected取自排在前面的DN
+}
+
+// 属性值中表示不存在和NULL
+const (
+	valueMissing = "<不存在>"
+	valueNull    = "NULL"
+	valueExists  = "存在"
+)
+
+// tableAttrs 一张表的结构属性，键为属性路径。
+// table、column.<列名>、index.<索引名> 表示对象是否存在，其余为对象的属性
+type tableAttrs map[string]string
+
+// 比较的表、列属性。TABLE_ROWS、AUTO_INCREMENT 等随数据变化的属性不比较。
+// 列的位置不逐个比较，缺少一列会使其后所有列的位置都不同，顺序由 TableDiff.ColumnOrders 单独报告
+var (
+	tableColumns  = []string{"TABLE_TYPE", "ENGINE", "ROW_FORMAT", "TABLE_COLLATION", "CREATE_OPTIONS", "TABLE_COMMENT"}
+	columnColumns = []string{"COLUMN_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "CHARACTER_SET_NAME", "COLLATION_NAME", "EXTRA", "COLUMN_COMMENT"}
+)
+
+// CheckSchemaConsistency 比较schema下所有表在各DN上的表、列和索引定义，以多数DN为准列出每个DN的差异。
+// 没有严格多数时以数量最多的值中排在前面的DN为准，并在差异中注明
+func CheckSchemaConsistency(dns []DN, schema string) ([]TableDiff, error) {
+	metas := make([]map[string]tableAttrs, len(dns))
+	columnOrders := make([]map[string][]string, len(dns))
+	tables := map[string]bool{}
+	for i, dn := range dns {
+		meta, orders, err := loadSchemaMeta(dn.DB, schema)
+		if err != nil {
+			return nil, fmt.Errorf("读取DN %s 的%s库表结构失败: %w", dn.Addr, schema, err)
+		}
+		metas[i], columnOrders[i] = meta, orders
+		for table := range meta {
+			tables[table] = true
+		}
+	}
+
+	var result []TableDiff
+	for _, table := range sortedKeys(tables) {
+		attrs := make([]tableAttrs, len(dns))
+		orders := make([][]string, len(dns))
+		for i := range dns {
+			attrs[i] = metas[i][table]
+			orders[i] = columnOrders[i][table]
+		}
+		d := TableDiff{Table: table, Diffs: diffAttrs(dns, attrs, parentKey, nil), ColumnOrders: diffOrders(dns, attrs, orders)}
+		if len(d.Diffs)+len(d.ColumnOrders) > 0 {
+			result = append(result, d)
+		}
+	}
+	return result, nil
+}
+
+// diffAttrs 逐个属性找出与参照值不同的DN。parent返回属性所属对象的存在性键，
+// 对象在某DN上不存在时只报告一次，不再比较其属性。ref为nil或没有该属性的参照值时以多数DN为准
+func diffAttrs(dns []DN, attrs []tableAttrs, parent func(string) string, ref *reference) []Diff {
+	keys := map[string]bool{}
+	for _, a := range attrs {
+		for k := range a {
+			keys[k] = true
+		}
+	}
+	if ref != nil {
+		for k := range ref.attrs {
+			keys[k] = true
+		}
+	}
+	var diffs []Diff
+	for _, key := range sortedKeys(keys) {
+		owner := parent(key)
+		expected, fromRef := ref.value(key)
+		if fromRef && owner != "" {
+			if v, _ := ref.value(owner); v == valueMissing {
+				// 所属对象在参照中不存在，由对象本身的差异报告
+				continue
+			}
+		}
+		var holders []int
+		values := make([]string, len(dns))
+		for i, a := range attrs {
+			if owner != "" {
+				if _, ok := a[owner]; !ok {
+					continue
+				}
+			}
+			v, ok := a[key]
+			if !ok {
+				v = valueMissing
+			}
+			holders = append(holders, i)
+			values[i] = v
+		}
+		strict := true
+		if !fromRef {
+			expected, strict = majority(holders, values)
+		}
+		for _, i := range holders {
+			if values[i] != expected {
+				diffs = append(diffs, Diff{DN: dns[i].Addr, Attribute: key, Expected: expected, Actual: values[i], NoMajority: !strict})
+			}
+		}
+	}
+	return diffs
+}
+
+// diffOrders 比较存在该表的各DN上的列顺序，以多数DN为准，只比较双方共有的列
+func diffOrders(dns []DN, attrs []tableAttrs, orders [][]string) []OrderDiff {
+	var holders []int
+	joined := make([]string, len(dns))
+	for i, a := range attrs {
+		if _, ok := a["table"]; ok {
+			holders = append(holders, i)
+			// 列名中可能有逗号，以NUL连接
+			joined[i] = strings.Join(orders[i], "\x00")
+		}
+	}
+	s, strict := majority(holders, joined)
+	var expected []string
+	if s != "" {
+		expected = strings.Split(s, "\x00")
+	}
+	var diffs []OrderDiff
+	for _, i := range holders {
+		want, got := common(expected, orders[i]), common(orders[i], expected)
+		if strings.Join(want, "\x00") != strings.Join(got, "\x00") {
+			diffs = append(diffs, OrderDiff{DN: dns[i].Addr, Expected: want, Actual: got, NoMajority: !strict})
+		}
+	}
+	return diffs
+}
+
+// parentKey 属性所属对象的存在性键，对象本身返回空
+func parentKey(key string) string {
+	switch {
+	case key == "table":
+		return ""
+	case strings.HasPrefix(key, "table."):
+		return "table"
+	case strings.HasPrefix(key, "column."):
+		name := strings.TrimPrefix(key, "column.")
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			return "column." + name[:i]
+		}
+		return "table"
+	case strings.HasPrefix(key, "index."):
+		return "table"
+	}
+	return ""
+}
+
+// majority 多数DN的值，数量相同时取排在前面的DN的值。
+// 该值由超过半数的DN持有时strict为true，否则（如两个DN的值不同）只是数量最多的值中排在前面的一个
+func majority(holders []int, values []string) (value string, strict bool) {
+	count := map[string]int{}
+	best, bestCount := "", 0
+	for _, i := range holders {
+		count[values[i]]++
+	}
+	for _, i := range holders {
+		if c := count[values[i]]; c > bestCount {
+			best, bestCount = values[i], c
+		}
+	}
+	return best, bestCount*2 > len(holders)
+}
+
+// loadSchemaMeta 读取schema下全部表的表、列和索引属性，以及各表按位置排列的列名
+func loadSchemaMeta(dn *sql.DB, schema string) (map[string]tableAttrs, map[string][]string, error) {
+	meta := map[string]tableAttrs{}
+	orders := map[string][]string{}
+	get := func(table string) tableAttrs {
+		a, ok := meta[table]
+		if !ok {
+			a = tableAttrs{"table": valueExists}
+			meta[table] = a
+		}
+		return a
+	}
+
+	err := queryRows(dn, "SELECT TABLE_NAME, "+strings.Join(tableColumns, ", ")+" FROM information_schema.TABLES WHERE TABLE_SCHEMA = ?",
+		func(v []string) {
+			a := get(v[0])
+			for i, c := range tableColumns {
+				a["table."+c] = v[i+1]
+			}
+		}, schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = queryRows(dn, "SELECT TABLE_NAME, COLUMN_NAME, "+strings.Join(columnColumns, ", ")+" FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME, ORDINAL_POSITION",
+		func(v []string) {
+			a := get(v[0])
+			orders[v[0]] = append(orders[v[0]], v[1])
+			prefix := "column." + v[1]
+			a[prefix] = valueExists
+			for i, c := range columnColumns {
+				a[prefix+"."+c] = v[i+2]
+			}
+		}, schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 按索引汇总为一条定义：唯一性、类型和按顺序排列的列
+	type index struct {
+		unique string
+		typ    string
+		cols   []string
+	}
+	indexes := map[string]map[string]*index{}
+	err = queryRows(dn, "SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, INDEX_TYPE, COLUMN_NAME, SUB_PART FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = ? ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX",
+		func(v []string) {
+			if indexes[v[0]] == nil {
+				indexes[v[0]] = map[string]*index{}
+			}
+			idx, ok := indexes[v[0]][v[1]]
+			if !ok {
+				idx = &index{unique: "UNIQUE", typ: v[3]}
+				if v[2] != "0" {
+					idx.unique = "NON_UNIQUE"
+				}
+				indexes[v[0]][v[1]] = idx
+			}
+			col := v[4]
+			if v[5] != valueNull {
+				col += "(" + v[5] + ")"
+			}
+			idx.cols = append(idx.cols, col)
+		}, schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	for table, list := range indexes {
+		a := get(table)
+		for name, idx := range list {
+			a["index."+name] = fmt.Sprintf("%s %s (%s)", idx.unique, idx.typ, strings.Join(idx.cols, ", "))
+		}
+	}
+	return meta, orders, nil
+}
+
+// queryRows 执行查询，将每行的值（NULL为"NULL"）交给fn
+func queryRows(dn *sql.DB, query string, fn func([]string), args ...interface{}) error {
+	rows, err := dn.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		v := make([]string, len(columns))
+		for i := range values {
+			v[i] = valueNull
+			if values[i].Valid {
+				v[i] = values[i].String
+			}
+		}
+		fn(v)
+	}
+	return rows.Err()
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
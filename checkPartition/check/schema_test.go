@@ -0,0 +1,24 @@
+package check
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 缺少一列不影响其余列的顺序比较，只有真正调换了顺序的DN报告一次
+func TestDiffOrders(t *testing.T) {
+	dns := []DN{{Addr: "dn1"}, {Addr: "dn2"}, {Addr: "dn3"}, {Addr: "dn4"}, {Addr: "dn5"}}
+	present := tableAttrs{"table": valueExists}
+	attrs := []tableAttrs{present, present, present, present, present}
+	orders := [][]string{
+		{"id", "a", "b", "c"},
+		{"id", "a", "b", "c"},
+		{"id", "a", "b", "c"},
+		{"id", "b", "c"},
+		{"id", "a", "c", "b"},
+	}
+	want := []OrderDiff{{DN: "dn5", Expected: []string{"id", "a", "b", "c"}, Actual: []string{"id", "a", "c", "b"}}}
+	if got := diffOrders(dns, attrs, orders); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffOrders = %+v, 期望 %+v", got, want)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"GoldenDB/connect"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// mdsFile MDS连接配置
+const mdsFile = "config/mds.json"
+
+// initKey 按环境变量加载密钥
+func initKey() error {
+	key, err := connect.LoadKey(connect.KeyOptionsFromEnv())
+	if err != nil {
+		return fmt.Errorf("加载密钥失败: %w", err)
+	}
+	connect.SetKey(key)
+	return nil
+}
+
+// runEncrypt 加密密码。未在命令行给出明文时，在终端不回显地输入两次，或从标准输入读取一行
+// 用法: -p [明文密码 | -]
+func runEncrypt(args []string) int {
+	if err := initKey(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var plain string
+	var err error
+	if len(args) > 0 && args[0] != "-" {
+		fmt.Fprintln(os.Stderr, "警告: 命令行中的明文密码会留在shell历史和进程列表中，建议直接运行 -p 后按提示输入")
+		plain = args[0]
+	} else if plain, err = connect.ReadSecret("请输入明文密码: ", true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if plain == "" {
+		fmt.Fprintln(os.Stderr, "密码不能为空")
+		return 1
+	}
+	encrypt, err := connect.Encrypt(plain)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "加密失败")
+		return 1
+	}
+	fmt.Println(encrypt)
+	return 0
+}
+
+// runRotateKey 生成新密钥并重新加密 config/mds.json 中的所有密码
+// 用法: rotate-key [-source file|env|passphrase] [-key-file 文件]
+func runRotateKey(args []string) int {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	source := fs.String("source", connect.KeySourceFile, "新密钥来源: file | env | passphrase")
+	keyFile := fs.String("key-file", "config/master.key", "file方式的新密钥文件")
+	fs.Parse(args)
+
+	oldKey, err := connect.LoadKey(connect.KeyOptionsFromEnv())
+	if err != nil {
+		fmt.Printf("加载当前密钥失败: %v\n", err)
+		return 1
+	}
+	data, err := os.ReadFile(mdsFile)
+	if err != nil {
+		fmt.Printf("读取 %s 失败: %v\n", mdsFile, err)
+		return 1
+	}
+	var list []connect.MDS
+	if err := json.Unmarshal(data, &list); err != nil {
+		fmt.Printf("解析 %s 失败: %v\n", mdsFile, err)
+		return 1
+	}
+
+	// 先用旧密钥解密全部密码，任何一个失败都不改写文件
+	plain := make([]string, len(list))
+	for i, m := range list {
+		if plain[i], err = connect.DecryptWith(oldKey, m.Password); err != nil {
+			fmt.Printf("MDS %s 的密码无法用当前密钥解密: %v\n", m.Name, err)
+			return 1
+		}
+	}
+
+	var newKey []byte
+	var salt string
+	switch *source {
+	case connect.KeySourceFile, connect.KeySourceEnv:
+		newKey, err = connect.GenerateKey()
+	case connect.KeySourcePassphrase:
+		var passphrase string
+		if passphrase, err = connect.ReadSecret("请输入新的密钥口令: ", true); err != nil {
+			break
+		}
+		if len(passphrase) < 8 {
+			err = fmt.Errorf("口令至少需要8个字符")
+			break
+		}
+		var raw []byte
+		if raw, err = connect.NewSalt(); err != nil {
+			break
+		}
+		salt = base64.StdEncoding.EncodeToString(raw)
+		newKey, err = connect.DeriveKey(passphrase, raw)
+	default:
+		err = fmt.Errorf("不支持的密钥来源: %s", *source)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	for i := range list {
+		if list[i].Password, err = connect.EncryptWith(newKey, plain[i]); err != nil {
+			fmt.Printf("加密 MDS %s 的密码失败: %v\n", list[i].Name, err)
+			return 1
+		}
+	}
+	out, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// 同一秒内多次执行时追加序号，不覆盖已有备份
+	suffix := ".bak-" + time.Now().Format("20060102150405")
+	for i := 1; exists(mdsFile+suffix) || exists(*keyFile+suffix); i++ {
+		suffix = fmt.Sprintf(".bak-%s.%d", time.Now().Format("20060102150405"), i)
+	}
+	if *source == connect.KeySourceFile {
+		if err := connect.WriteKeyFile(*keyFile+".new", newKey); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	if err := os.WriteFile(mdsFile+suffix, data, 0600); err != nil {
+		fmt.Printf("备份 %s 失败: %v\n", mdsFile, err)
+		return 1
+	}
+	if err := os.WriteFile(mdsFile+".tmp", out, 0600); err != nil {
+		fmt.Printf("写入 %s 失败: %v\n", mdsFile, err)
+		return 1
+	}
+	if err := os.Rename(mdsFile+".tmp", mdsFile); err != nil {
+		fmt.Printf("替换 %s 失败: %v\n", mdsFile, err)
+		return 1
+	}
+	fmt.Printf("已重新加密 %d 个密码，原文件备份为 %s\n", len(list), mdsFile+suffix)
+
+	switch *source {
+	case connect.KeySourceFile:
+		if exists(*keyFile) {
+			if err := os.Rename(*keyFile, *keyFile+suffix); err != nil {
+				fmt.Printf("备份旧密钥文件失败: %v，新密钥保存在 %s\n", err, *keyFile+".new")
+				return 1
+			}
+		}
+		if err := os.Rename(*keyFile+".new", *keyFile); err != nil {
+			fmt.Printf("替换密钥文件失败: %v，新密钥保存在 %s\n", err, *keyFile+".new")
+			return 1
+		}
+		fmt.Printf("新密钥已写入 %s（权限0600），运行前请设置:\nexport %s=%s\n", *keyFile, connect.EnvKeyFile, *keyFile)
+	case connect.KeySourceEnv:
+		fmt.Printf("运行前请设置（并妥善保管密钥）:\nexport %s=%s\n", connect.EnvKey, connect.EncodeKey(newKey))
+	case connect.KeySourcePassphrase:
+		fmt.Printf("运行前请设置盐值，口令通过 %s 提供或在终端输入:\nexport %s=%s\n", connect.EnvPassphrase, connect.EnvSalt, salt)
+	}
+	return 0
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
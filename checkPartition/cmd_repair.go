@@ -0,0 +1,170 @@
+package main
+
+import (
+	"GoldenDB/check"
+	"GoldenDB/connect"
+	"GoldenDB/redact"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// clusterRepairs 一个集群的分区修复语句
+type clusterRepairs struct {
+	cluster string
+	repairs []check.Repair
+	nodes   map[string]connect.Node // 各DN的连接信息，按地址索引，执行修复语句时单独建联
+}
+
+// dnRepairs 按DN汇总修复语句，保持检查的顺序
+func (c clusterRepairs) dnRepairs() [][]check.Repair {
+	var list [][]check.Repair
+	index := map[string]int{}
+	for _, r := range c.repairs {
+		i, ok := index[r.DN.Addr]
+		if !ok {
+			i = len(list)
+			index[r.DN.Addr] = i
+			list = append(list, nil)
+		}
+		list[i] = append(list[i], r)
+	}
+	return list
+}
+
+// writeRepairScripts 按 <目录>/<集群>/<DN地址>.sql 为每个DN写一个修复脚本，只写入不执行
+func writeRepairScripts(dir string, repairs []clusterRepairs, reference string) error {
+	basis := "多数DN的分区定义"
+	if reference == check.ReferenceMDS {
+		basis = "MDS字典中的分区定义"
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	count := 0
+	for _, c := range repairs {
+		clusterDir := filepath.Join(dir, fileName(c.cluster))
+		if err := os.MkdirAll(clusterDir, 0755); err != nil {
+			return err
+		}
+		for _, list := range c.dnRepairs() {
+			dn := list[0].DN
+			var b strings.Builder
+			fmt.Fprintf(&b, "-- 租户%s DN %s（DN组 %s）分区修复脚本，以%s为准\n", c.cluster, dn.Addr, dn.Group, basis)
+			fmt.Fprintf(&b, "-- 生成时间 %s，执行前请逐条确认\n", now)
+			for _, r := range list {
+				fmt.Fprintf(&b, "\n-- 表 %s.%s\n", r.Schema, r.Table)
+				for _, n := range r.Notes {
+					fmt.Fprintf(&b, "-- 需人工处理: %s\n", n)
+				}
+				for _, s := range r.Statements {
+					if s.Warning != "" {
+						fmt.Fprintf(&b, "-- 注意: %s\n", s.Warning)
+					}
+					fmt.Fprintf(&b, "%s;\n", s.SQL)
+				}
+			}
+			name := filepath.Join(clusterDir, fileName(dn.Addr)+".sql")
+			if err := os.WriteFile(name, []byte(b.String()), 0644); err != nil {
+				return err
+			}
+			fmt.Println("已生成修复脚本:", name)
+			count++
+		}
+	}
+	if count == 0 {
+		fmt.Println("没有需要修复的分区")
+	}
+	return nil
+}
+
+// applyRepairs 逐个DN列出修复语句，在终端输入 yes 确认后按顺序执行。
+// DDL在单独的连接上执行，不占用检查用的连接池，读超时和语句超时为ddlTimeout秒（0为不限制）；
+// 一条失败时停止该表的其余语句，继续执行其它表
+func applyRepairs(repairs []clusterRepairs, ddlTimeout int) {
+	for _, c := range repairs {
+		for _, list := range c.dnRepairs() {
+			dn := list[0].DN
+			count := 0
+			for _, r := range list {
+				count += len(r.Statements)
+			}
+			if count == 0 {
+				continue
+			}
+			fmt.Printf("租户%s DN %s（DN组 %s）将执行:\n", c.cluster, dn.Addr, dn.Group)
+			for _, r := range list {
+				for _, s := range r.Statements {
+					if s.Warning != "" {
+						fmt.Printf("  -- 注意: %s\n", s.Warning)
+					}
+					fmt.Printf("  %s;\n", s.SQL)
+				}
+			}
+			ok, err := connect.Confirm(fmt.Sprintf("在DN %s 上执行以上%d条DDL？输入 yes 确认: ", dn.Addr, count))
+			if err != nil {
+				fmt.Println("未执行修复:", err)
+				return
+			}
+			if !ok {
+				fmt.Println("已跳过DN", dn.Addr)
+				continue
+			}
+			node, ok := c.nodes[dn.Addr]
+			if !ok {
+				fmt.Printf("没有DN %s 的连接信息，未执行修复\n", dn.Addr)
+				continue
+			}
+			if err := applyDN(node, list, ddlTimeout); err != nil {
+				fmt.Printf("DN %s 连接失败，未执行修复: %s\n", dn.Addr, redact.Error(err))
+			}
+		}
+	}
+}
+
+// applyDN 在DN的单独连接上执行修复语句，执行后关闭连接
+func applyDN(node connect.Node, list []check.Repair, ddlTimeout int) error {
+	node.Conn.ReadTimeout = ddlTimeout
+	node.Conn.MaxOpen, node.Conn.MaxIdle = 1, 1
+	f := connect.NewFactory()
+	defer f.Close()
+	db, err := f.Connect(context.Background(), node)
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		for _, s := range r.Statements {
+			if err := execDDL(db, s.SQL, ddlTimeout); err != nil {
+				fmt.Printf("执行失败，停止表%s.%s的其余语句: %s\n", r.Schema, r.Table, redact.Error(err))
+				break
+			}
+			fmt.Println("已执行:", s.SQL)
+		}
+	}
+	return nil
+}
+
+// execDDL 执行一条DDL，timeout大于0时为语句超时（秒）
+func execDDL(db *sql.DB, query string, timeout int) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+// fileName 将集群名、地址转为可用作文件名的字符串
+func fileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '[', ']', ' ', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, s)
+}
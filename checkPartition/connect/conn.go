@@ -0,0 +1,70 @@
+package connect
+
+// DBConn 连接设置，在 mds.json 中以 conn 字段配置，同时用于该MDS和从它查到的DN，未填写的项使用默认值
+type DBConn struct {
+	ConnectTimeout int   `json:"connect_timeout,omitempty"` // 建立连接超时（秒），默认5
+	ReadTimeout    int   `json:"read_timeout,omitempty"`    // 读超时（秒），默认60
+	WriteTimeout   int   `json:"write_timeout,omitempty"`   // 写超时（秒），默认60
+	MaxOpen        int   `json:"max_open,omitempty"`        // 每个节点的最大连接数，默认4
+	MaxIdle        int   `json:"max_idle,omitempty"`        // 每个节点的最大空闲连接数，默认2
+	MaxLifetime    int   `json:"max_lifetime,omitempty"`    // 连接最长使用时间（秒），默认300
+	TLS            DBTLS `json:"tls,omitempty"`
+}
+
+// DBTLS TLS设置
+type DBTLS struct {
+	// disable | preferred（服务端支持时加密，不校验证书）| required（加密，不校验证书）
+	// | verify-ca（校验证书链）| verify-full（校验证书链和主机名）
+	Mode       string `json:"mode,omitempty"`
+	CA         string `json:"ca,omitempty"`          // CA证书文件，verify-ca/verify-full 未配置时使用系统CA
+	Cert       string `json:"cert,omitempty"`        // 客户端证书
+	Key        string `json:"key,omitempty"`         // 客户端私钥
+	ServerName string `json:"server_name,omitempty"` // 校验主机名时使用，为空时使用节点地址
+}
+
+// DefaultConn 默认连接设置
+var DefaultConn = DBConn{
+	ConnectTimeout: 5,
+	ReadTimeout:    60,
+	WriteTimeout:   60,
+	MaxOpen:        4,
+	MaxIdle:        2,
+	MaxLifetime:    300,
+	TLS:            DBTLS{Mode: "disable"},
+}
+
+// Merge 返回用o中已填写的项覆盖c后的设置，o为nil时返回c
+func (c DBConn) Merge(o *DBConn) DBConn {
+	if o == nil {
+		return c
+	}
+	set := func(dst *int, v int) {
+		if v != 0 {
+			*dst = v
+		}
+	}
+	set(&c.ConnectTimeout, o.ConnectTimeout)
+	set(&c.ReadTimeout, o.ReadTimeout)
+	set(&c.WriteTimeout, o.WriteTimeout)
+	set(&c.MaxOpen, o.MaxOpen)
+	set(&c.MaxIdle, o.MaxIdle)
+	set(&c.MaxLifetime, o.MaxLifetime)
+	for _, f := range []struct {
+		dst *string
+		v   string
+	}{
+		{&c.TLS.Mode, o.TLS.Mode},
+		{&c.TLS.CA, o.TLS.CA},
+		{&c.TLS.Cert, o.TLS.Cert},
+		{&c.TLS.Key, o.TLS.Key},
+		{&c.TLS.ServerName, o.TLS.ServerName},
+	} {
+		if f.v != "" {
+			*f.dst = f.v
+		}
+	}
+	if c.MaxOpen > 0 && c.MaxIdle > c.MaxOpen {
+		c.MaxIdle = c.MaxOpen
+	}
+	return c
+}
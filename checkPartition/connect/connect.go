@@ -1,6 +1,8 @@
 package connect
 
 import (
+	"GoldenDB/redact"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -8,28 +10,39 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"io"
+	"net"
 	"os"
+	"strconv"
 )
 
+// EncryptionKey 旧版编译在程序中的密钥，任何拿到程序的人都能解密，仅用于兼容已有密文
 const EncryptionKey = "879kf28Ls987kF982k789lK87982k789"
 
 type MDS struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Name     string  `json:"name"`
+	Host     string  `json:"host"`
+	Port     int     `json:"port"`
+	Username string  `json:"username"`
+	Password string  `json:"password"`
+	Conn     *DBConn `json:"conn,omitempty"` // 连接设置，同时用于该MDS的DN
+	// 从MDS字典读取表的建表语句的查询，-reference mds 时使用，为空时查询 mds.dictionary_info。
+	// 参数依次为集群ID、库名、表名，取第一行第一列
+	TableDDLSQL string `json:"table_ddl_sql,omitempty"`
 }
 type MDSDemo struct {
-	DSN      string
-	Username string
-	Password string
+	Node
+	TableDDLSQL string
 }
 
+// Decrypt 用当前密钥解密
 func Decrypt(input string) (string, error) {
-	key := []byte(EncryptionKey)
+	return DecryptWith(currentKey, input)
+}
+
+// DecryptWith 用指定密钥解密
+func DecryptWith(key []byte, input string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(input)
 	if err != nil {
 		return "", err
@@ -56,10 +69,18 @@ func Decrypt(input string) (string, error) {
 		return "", err
 	}
 
+	// 解密出的明文登记到redact，之后输出的错误信息中自动遮盖
+	redact.Register(string(plaintext))
 	return string(plaintext), nil
 }
+
+// Encrypt 用当前密钥加密
 func Encrypt(input string) (string, error) {
-	key := []byte(EncryptionKey)
+	return EncryptWith(currentKey, input)
+}
+
+// EncryptWith 用指定密钥加密
+func EncryptWith(key []byte, input string) (string, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
@@ -81,49 +102,84 @@ func Encrypt(input string) (string, error) {
 	result := append(nonce, ciphertext...)
 	return base64.StdEncoding.EncodeToString(result), nil
 }
-func GetMDS() []MDSDemo {
+
+// NewConfig 生成连接单个节点的驱动配置，不含超时和TLS设置，完整配置见 Node.MySQLConfig
+func NewConfig(user, password, host string, port int, dbName string) *mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	cfg.DBName = dbName
+	cfg.LoadBalance = false
+	cfg.BlackList = -1
+	return cfg
+}
+
+// RedactDSN 返回密码被遮盖的DSN，用于输出
+func RedactDSN(cfg *mysql.Config) string {
+	if cfg == nil {
+		return ""
+	}
+	c := cfg.Clone()
+	if c.Passwd != "" {
+		c.Passwd = redact.Mask
+	}
+	return c.FormatDSN()
+}
+
+// GetMDS 读取 config/mds.json 并解密各MDS的密码，密码无法解密时返回错误，不会使用空密码连接
+func GetMDS() ([]MDSDemo, error) {
 	var MDSList []MDS
 	file, err := os.ReadFile("config/mds.json")
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("读取MDS配置失败: %w", err)
 	}
 	var MDSDemosList []MDSDemo
 	err = json.Unmarshal(file, &MDSList)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("解析MDS配置失败: %w", err)
 	}
 	for _, v := range MDSList {
-		v.Password, _ = Decrypt(v.Password)
-		dsn := v.Username + ":" + v.Password + "@tcp(" +
-			v.Host + ":" + fmt.Sprintf("%d", v.Port) + ")/" +
-			"mds" + "?loadbalance=false&blacklist=-1"
-
-		MDSDemosList = append(MDSDemosList, MDSDemo{DSN: dsn, Username: v.Username, Password: v.Password})
+		password, err := Decrypt(v.Password)
+		if err != nil {
+			return nil, fmt.Errorf("MDS %s 的密码解密失败: %w", v.Name, err)
+		}
+		MDSDemosList = append(MDSDemosList, MDSDemo{
+			Node: Node{
+				Name:     v.Name,
+				Host:     v.Host,
+				Port:     v.Port,
+				User:     v.Username,
+				Password: password,
+				DBName:   "mds",
+				Conn:     DefaultConn.Merge(v.Conn),
+			},
+			TableDDLSQL: v.TableDDLSQL,
+		})
 	}
-	return MDSDemosList
+	return MDSDemosList, nil
 }
 
-func TestDSN(dsn string) bool {
-	open, err := sql.Open("mysql", dsn)
-	if err != nil {
-		panic(err)
-		return false
-	}
-	err = open.Ping()
-	if err != nil {
-		panic(err)
-		return false
-	}
-	return true
+// TestDSN 测试能否连接节点，使用独立的连接池，测试后关闭；失败时返回的错误中密码已遮盖
+func TestDSN(n Node) error {
+	f := NewFactory()
+	defer f.Close()
+	_, err := f.Connect(context.Background(), n)
+	return err
 }
-func GetDBConnect(dsn string) *sql.DB {
-	open, err := sql.Open("mysql", dsn)
-	if err != nil {
-		panic(err)
-	}
-	err = open.Ping()
+
+// GetDBConnect 从共用工厂获取节点的连接池并Ping校验，失败时返回错误而不是panic。
+// 同一节点复用连接池，返回的连接池由 CloseAll 统一关闭
+func GetDBConnect(n Node) (*sql.DB, error) {
+	return Connect(context.Background(), n)
+}
+
+// OpenDB 创建独立的连接池但不立即建联
+func OpenDB(cfg *mysql.Config) (*sql.DB, error) {
+	connector, err := mysql.NewConnector(cfg)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s: %w", RedactDSN(cfg), err)
 	}
-	return open
+	return sql.OpenDB(connector), nil
 }
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package connect
+
+import "os"
+
+// checkKeyFileMode 非unix平台不检查文件权限
+func checkKeyFileMode(path string, info os.FileInfo) error {
+	return nil
+}
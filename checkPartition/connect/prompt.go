@@ -0,0 +1,69 @@
+package connect
+
+import (
+	"bufio"
+	"fmt"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdin 非终端输入时逐行读取，多次调用共用同一个缓冲
+var stdin = bufio.NewReader(os.Stdin)
+
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// ReadSecret 读取密码、口令等敏感输入，不经过命令行参数。标准输入是终端时不回显地提示输入，
+// confirm为true时要求输入两次；否则从标准输入读取一行，便于通过管道或重定向传入
+func ReadSecret(prompt string, confirm bool) (string, error) {
+	if !stdinIsTerminal() {
+		line, err := stdin.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("从标准输入读取失败: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	value, err := readPassword(prompt)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := readPassword("请再次输入: ")
+		if err != nil {
+			return "", err
+		}
+		if again != value {
+			return "", fmt.Errorf("两次输入不一致")
+		}
+	}
+	return value, nil
+}
+
+// readPassword 提示写到标准错误，不影响标准输出中的结果
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("读取输入失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// Confirm 在终端中提示并读取一行，输入 yes 时返回true。标准输入不是终端时返回错误，
+// 用于执行DDL等需要人工确认的操作，不接受管道或重定向的输入
+func Confirm(prompt string) (bool, error) {
+	if !stdinIsTerminal() {
+		return false, fmt.Errorf("标准输入不是终端，无法确认")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return false, fmt.Errorf("读取输入失败: %w", err)
+	}
+	return strings.TrimSpace(line) == "yes", nil
+}
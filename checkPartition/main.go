@@ -4,89 +4,309 @@ import (
 	"GoldenDB/alarm"
 	"GoldenDB/check"
 	"GoldenDB/connect"
+	"GoldenDB/redact"
 	"database/sql"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
 func main() {
 	args := os.Args
 	if len(args) < 2 {
-		fmt.Println("用法: -s | -p <明文密码>")
+		fmt.Println(usage)
 		return
 	}
 	if args[1] == "-s" {
-		startCheck()
+		opts, err := parseCheckOptions(args[2:])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+		if err := initKey(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		startCheck(opts)
 		return
 	}
 	if args[1] == "-p" {
-		if len(args) < 3 {
-			fmt.Println("请输入明文密码: -p <text>")
-			return
+		os.Exit(runEncrypt(args[2:]))
+	}
+	if args[1] == "rotate-key" {
+		os.Exit(runRotateKey(args[2:]))
+	}
+	fmt.Println("参数错误: " + usage)
+	return
+}
+
+const usage = "用法: -s [-checks partition,schema] [-reference majority|mds] [-repair 目录 [-apply [-ddl-timeout 秒]]] | -p [明文密码] | rotate-key [选项]"
+
+// checkOptions -s 的选项
+type checkOptions struct {
+	checks     map[string]bool // 执行的检查项
+	reference  string          // 分区比较和修复的参照: majority | mds
+	repairDir  string          // 分区修复脚本的输出目录，为空不生成
+	apply      bool            // 生成修复脚本后逐个DN确认并执行
+	ddlTimeout int             // 执行修复语句的超时（秒），0为不限制
+}
+
+// parseCheckOptions 解析 -s 之后的参数
+func parseCheckOptions(args []string) (checkOptions, error) {
+	fs := flag.NewFlagSet("-s", flag.ExitOnError)
+	checks := fs.String("checks", check.CheckPartition+","+check.CheckSchema, "执行的检查，逗号分隔: partition（分区）, schema（表结构）")
+	reference := fs.String("reference", check.ReferenceMajority, "分区比较和修复的参照: majority（多数DN）, mds（MDS字典中的建表语句）")
+	repairDir := fs.String("repair", "", "为分区不一致的DN生成修复脚本，写入该目录")
+	apply := fs.Bool("apply", false, "生成修复脚本后逐个DN在终端确认并执行，需同时指定 -repair")
+	ddlTimeout := fs.Int("ddl-timeout", 3600, "-apply 执行每条修复语句的超时（秒），0为不限制")
+	fs.Parse(args)
+	opts := checkOptions{checks: map[string]bool{}, reference: *reference, repairDir: *repairDir, apply: *apply, ddlTimeout: *ddlTimeout}
+	for _, c := range strings.Split(*checks, ",") {
+		c = strings.TrimSpace(c)
+		switch c {
+		case check.CheckPartition, check.CheckSchema:
+			opts.checks[c] = true
+		case "":
+		default:
+			return opts, fmt.Errorf("不支持的检查: %s", c)
 		}
-		encrypt, err := connect.Encrypt(args[2])
-		if err != nil {
-			fmt.Println("加密失败")
-			return
+	}
+	if len(opts.checks) == 0 {
+		return opts, fmt.Errorf("没有指定检查")
+	}
+	if opts.ddlTimeout < 0 {
+		return opts, fmt.Errorf("-ddl-timeout 不能为负数")
+	}
+	if opts.apply && opts.repairDir == "" {
+		return opts, fmt.Errorf("-apply 需同时指定 -repair")
+	}
+	if opts.repairDir != "" && !opts.checks[check.CheckPartition] {
+		return opts, fmt.Errorf("-repair 需执行分区检查")
+	}
+	switch opts.reference {
+	case check.ReferenceMajority:
+	case check.ReferenceMDS:
+		if !opts.checks[check.CheckPartition] {
+			return opts, fmt.Errorf("-reference mds 需执行分区检查")
 		}
-		fmt.Println(encrypt)
-		return
+	default:
+		return opts, fmt.Errorf("不支持的参照: %s", opts.reference)
 	}
-	fmt.Println("参数错误: -s | -p <明文密码>")
-	return
+	return opts, nil
 }
-func startCheck() {
+
+func startCheck(opts checkOptions) {
 	// 存放所有DDL失败的表信息
 	var ErrorList []check.ErrorInfo
+	// 各集群的分区修复语句
+	var repairs []clusterRepairs
 	// MDS连接信息
-	mdsList := connect.GetMDS()
-	// 连接所有的MDS
+	mdsList, err := connect.GetMDS()
+	if err != nil {
+		fmt.Println(redact.Error(err))
+		os.Exit(1)
+	}
+	// 连接池在检查各集群时复用，检查结束后统一关闭
+	defer connect.CloseAll()
+	// 连接所有的MDS，一个MDS连接失败时继续检查其它MDS
 	for _, mds := range mdsList {
-		dbConnect := connect.GetDBConnect(mds.DSN)
-		defer dbConnect.Close()
-
-		if err := dbConnect.Ping(); err != nil {
-			fmt.Println("MDS连接失败")
+		dbConnect, err := connect.GetDBConnect(mds.Node)
+		if err != nil {
+			fmt.Printf("MDS %s 连接失败: %s\n", mds.Name, redact.Error(err))
+			continue
 		}
 
 		// 获取所有集群ID
 		IDList := alarm.GetClusterID(dbConnect)
 
 		for _, id := range IDList {
-			//通过集群ID找到集群名
-			clusterName := alarm.GetClusterName(dbConnect, id)
-			// 连接集群下的所有DN节点
-			schemas := alarm.GetSchema(dbConnect, id)
-			dninfo := alarm.GetDNinfo(dbConnect, id, mds.Username, mds.Password)
-
-			// 所有DN连接
-			var dnlist []*sql.DB
-			for _, dndsn := range dninfo {
-				dn := connect.GetDBConnect(dndsn)
-				defer dn.Close()
-				dnlist = append(dnlist, dn)
+			errs, r := checkCluster(dbConnect, id, mds, opts)
+			ErrorList = append(ErrorList, errs...)
+			if len(r.repairs) > 0 {
+				repairs = append(repairs, r)
 			}
-			// 获取所有分区表信息
-			tables := check.GetTables(dnlist[0], schemas)
+		}
+	}
+	check.GenJson(ErrorList)
+	if opts.repairDir == "" {
+		return
+	}
+	if err := writeRepairScripts(opts.repairDir, repairs, opts.reference); err != nil {
+		fmt.Println("生成修复脚本失败:", err)
+		return
+	}
+	if opts.apply {
+		applyRepairs(repairs, opts.ddlTimeout)
+	}
+}
+
+// checkCluster 检查一个集群下各主DN上的分区信息和表结构是否一致，DN连接失败时记为错误并跳过该集群。
+// 指定 -repair 时同时返回分区修复语句
+func checkCluster(mdsConnect *sql.DB, id string, mds connect.MDSDemo, opts checkOptions) ([]check.ErrorInfo, clusterRepairs) {
+	var ErrorList []check.ErrorInfo
+	//通过集群ID找到集群名
+	clusterName := alarm.GetClusterName(mdsConnect, id)
+	repairs := clusterRepairs{cluster: clusterName, nodes: map[string]connect.Node{}}
+	// 连接集群下的所有DN节点
+	schemas := alarm.GetSchema(mdsConnect, id)
+	dninfo := alarm.GetDNinfo(mdsConnect, id, mds.User, mds.Password, mds.Conn)
 
-			// 检查所有分区表的分区信息是否一致
-			for _, table := range tables {
-				status := check.CheckTablePartitionsInfo(dnlist, table.Name, table.Schema)
-				if !status {
-					fmt.Println("分区表", table, "分区信息不一致")
-					ErrorList = append(ErrorList, check.ErrorInfo{
-						Cluster: clusterName,
-						Schema:  table.Schema,
-						Table:   table.Name,
-						Error:   fmt.Sprintf("租户%s的%s库的%s分区信息不一致", clusterName, table.Schema, table.Name),
-					})
-					continue
-				}
-				fmt.Println("分区表", table, "分区信息一致")
+	// 所有DN连接，同一DN的连接池在多次检查间复用
+	var dns []check.DN
+	for _, node := range dninfo {
+		dn, err := connect.GetDBConnect(node.Node)
+		if err != nil {
+			msg := redact.Error(err)
+			fmt.Printf("租户%s的DN连接失败: %s\n", clusterName, msg)
+			return append(ErrorList, check.ErrorInfo{
+				Cluster: clusterName,
+				Error:   fmt.Sprintf("租户%s的DN连接失败: %s", clusterName, msg),
+			}), repairs
+		}
+		dns = append(dns, check.DN{Addr: node.Addr(), Group: node.GroupID, DB: dn})
+		repairs.nodes[node.Addr()] = node.Node
+	}
+	if len(dns) == 0 {
+		fmt.Printf("租户%s没有主DN\n", clusterName)
+		return ErrorList, repairs
+	}
+	if opts.checks[check.CheckSchema] {
+		ErrorList = append(ErrorList, checkSchemas(clusterName, dns, schemas)...)
+	}
+	if opts.checks[check.CheckPartition] {
+		// 以MDS字典为参照时，从MDS读取各分区表的建表语句
+		var tableDDL func(schema, table string) (string, error)
+		if opts.reference == check.ReferenceMDS {
+			tableDDL = func(schema, table string) (string, error) {
+				return alarm.GetTableDDL(mdsConnect, mds.TableDDLSQL, id, schema, table)
 			}
+		}
+		errs, r := checkPartitions(clusterName, dns, schemas, tableDDL, opts.repairDir != "")
+		ErrorList = append(ErrorList, errs...)
+		repairs.repairs = r
+	}
+	return ErrorList, repairs
+}
+
+// checkPartitions 比较以第一个主DN为准的所有分区表在各主DN上的分区定义，repair为true时生成修复语句。
+// tableDDL不为nil时以它返回的MDS字典中的建表语句为参照，否则以多数DN为准
+func checkPartitions(clusterName string, dns []check.DN, schemas []string, tableDDL func(schema, table string) (string, error), repair bool) ([]check.ErrorInfo, []check.Repair) {
+	var ErrorList []check.ErrorInfo
+	var repairs []check.Repair
+	// 获取所有分区表信息
+	tables := check.GetTables(dns[0].DB, schemas)
 
+	// 检查所有分区表的分区信息是否一致
+	for _, table := range tables {
+		fmt.Println("正在检查分区表：", table.Name)
+		parts, err := check.LoadTablePartitions(dns, table.Name, table.Schema)
+		if err == nil && tableDDL != nil {
+			var ddl string
+			if ddl, err = tableDDL(table.Schema, table.Name); err == nil {
+				err = parts.SetReference(ddl)
+			}
+		}
+		if err != nil {
+			msg := redact.Error(err)
+			fmt.Println("分区表", table, "分区信息检查失败:", msg)
+			ErrorList = append(ErrorList, check.ErrorInfo{
+				Cluster: clusterName,
+				Schema:  table.Schema,
+				Table:   table.Name,
+				Check:   check.CheckPartition,
+				Error:   fmt.Sprintf("租户%s的%s库的%s分区信息检查失败: %s", clusterName, table.Schema, table.Name, msg),
+			})
+			continue
 		}
+		if diffs := parts.Diffs(); len(diffs) > 0 {
+			fmt.Println("分区表", table, "分区信息不一致")
+			for _, d := range diffs {
+				printPartitionDiff(d)
+			}
+			ErrorList = append(ErrorList, check.ErrorInfo{
+				Cluster:    clusterName,
+				Schema:     table.Schema,
+				Table:      table.Name,
+				Check:      check.CheckPartition,
+				Error:      fmt.Sprintf("租户%s的%s库的%s分区信息不一致", clusterName, table.Schema, table.Name),
+				Partitions: diffs,
+			})
+			if repair {
+				repairs = append(repairs, parts.Repairs()...)
+			}
+			continue
+		}
+		fmt.Println("分区表", table, "分区信息一致")
+	}
+	return ErrorList, repairs
+}
 
+// printPartitionDiff 输出一个DN的分区差异
+func printPartitionDiff(d check.PartitionDiff) {
+	fmt.Printf("  DN %s（DN组 %s）:\n", d.DN, d.Group)
+	if len(d.Missing) > 0 {
+		fmt.Printf("    缺少分区: %s\n", strings.Join(d.Missing, ", "))
 	}
-	check.GenJson(ErrorList)
+	if len(d.Extra) > 0 {
+		fmt.Printf("    多余分区: %s\n", strings.Join(d.Extra, ", "))
+	}
+	if len(d.NoMajority) > 0 {
+		fmt.Printf("    无严格多数: 分区 %s 是否存在取自排在前面的DN，需人工确认\n", strings.Join(d.NoMajority, ", "))
+	}
+	if len(d.ActualOrder) > 0 {
+		fmt.Printf("    分区顺序: %s，参照顺序为 %s%s\n", strings.Join(d.ActualOrder, ", "), strings.Join(d.ExpectedOrder, ", "), noMajority(d.OrderNoMajority))
+	}
+	for _, a := range d.Diffs {
+		fmt.Printf("    %s 为 %s，参照值为 %s%s\n", a.Attribute, a.Actual, a.Expected, noMajority(a.NoMajority))
+	}
+}
+
+// noMajority 参照值不是严格多数时附加的说明
+func noMajority(b bool) string {
+	if b {
+		return "（无严格多数，取排在前面的DN，需人工确认）"
+	}
+	return ""
+}
+
+// checkSchemas 比较各库所有表在各主DN上的表、列和索引定义
+func checkSchemas(clusterName string, dns []check.DN, schemas []string) []check.ErrorInfo {
+	var ErrorList []check.ErrorInfo
+	for _, schema := range schemas {
+		diffs, err := check.CheckSchemaConsistency(dns, schema)
+		if err != nil {
+			msg := redact.Error(err)
+			fmt.Printf("租户%s的%s库表结构检查失败: %s\n", clusterName, schema, msg)
+			ErrorList = append(ErrorList, check.ErrorInfo{
+				Cluster: clusterName,
+				Schema:  schema,
+				Check:   check.CheckSchema,
+				Error:   fmt.Sprintf("租户%s的%s库表结构检查失败: %s", clusterName, schema, msg),
+			})
+			continue
+		}
+		for _, t := range diffs {
+			fmt.Printf("表 {%s %s} 表结构不一致\n", schema, t.Table)
+			for _, d := range t.Diffs {
+				fmt.Printf("  DN %s: %s 为 %s，参照值为 %s%s\n", d.DN, d.Attribute, d.Actual, d.Expected, noMajority(d.NoMajority))
+			}
+			for _, o := range t.ColumnOrders {
+				fmt.Printf("  DN %s: 列顺序为 %s，参照顺序为 %s%s\n", o.DN, strings.Join(o.Actual, ", "), strings.Join(o.Expected, ", "), noMajority(o.NoMajority))
+			}
+			ErrorList = append(ErrorList, check.ErrorInfo{
+				Cluster:      clusterName,
+				Schema:       schema,
+				Table:        t.Table,
+				Check:        check.CheckSchema,
+				Error:        fmt.Sprintf("租户%s的%s库的%s表结构不一致", clusterName, schema, t.Table),
+				Diffs:        t.Diffs,
+				ColumnOrders: t.ColumnOrders,
+			})
+		}
+		if len(diffs) == 0 {
+			fmt.Printf("%s库表结构一致\n", schema)
+		}
+	}
+	return ErrorList
 }
@@ -0,0 +1,35 @@
+package alarm
+
+import (
+	"sort"
+	"sync"
+)
+
+// activeCaches insight -> 该insight已推送告警的缓存(*sync.Map)
+var activeCaches sync.Map
+
+// RegisterCache 登记insight的告警缓存，供摘要等功能读取当前活动告警，
+// 并恢复LoadState加载的该insight已推送告警，返回恢复的告警数
+func RegisterCache(insight string, cache *sync.Map) int {
+	activeCaches.Store(insight, cache)
+	return restoreCache(insight, cache)
+}
+
+// ActiveAlarms 返回每个insight当前已推送且未恢复的告警
+func ActiveAlarms() map[string][]AlarmInfo {
+	result := make(map[string][]AlarmInfo)
+	activeCaches.Range(func(key, value interface{}) bool {
+		insight := key.(string)
+		var list []AlarmInfo
+		value.(*sync.Map).Range(func(_, v interface{}) bool {
+			list = append(list, v.(AlarmInfo))
+			return true
+		})
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].CreateTime < list[j].CreateTime
+		})
+		result[insight] = list
+		return true
+	})
+	return result
+}
@@ -0,0 +1,82 @@
+package alarm
+
+import (
+	"GoldenDB/api"
+	"encoding/json"
+	"fmt"
+)
+
+// DefaultBatchSize 未配置批量大小时每批最多推送的事件数
+const DefaultBatchSize = 100
+
+// BatchResult 批量推送时接收方返回的单条事件处理结果
+type BatchResult struct {
+	EventId   string `json:"eventId"`
+	EventType string `json:"eventType"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+}
+
+// batchResponse 批量推送响应体，results为空时视为整批成功
+type batchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// SendAlarmsToHTTP 将一批告警作为JSON数组一次推送，返回被接收方接受的事件。
+// HTTP状态非2xx时整批失败；响应体包含results时按每条事件的success判断，否则整批成功。
+func SendAlarmsToHTTP(alarms []AlarmInfo, client *api.Client) ([]AlarmInfo, error) {
+	body, err := json.Marshal(alarms)
+	if err != nil {
+		return nil, fmt.Errorf("marshal to JSON error: %w", err)
+	}
+	if logger != nil {
+		logger.Info("批量发送告警 %d 条: %s", len(alarms), body)
+	}
+
+	status, respBody, err := client.PostJSON(body)
+	if err != nil {
+		return nil, fmt.Errorf("send alarm batch error: %w", err)
+	}
+	if status < 200 || status >= 300 {
+		return nil, fmt.Errorf("send alarm batch failed, http code %d", status)
+	}
+	if logger != nil {
+		logger.Info("响应: %s", string(respBody))
+	}
+
+	var resp batchResponse
+	if len(respBody) == 0 || json.Unmarshal(respBody, &resp) != nil || len(resp.Results) == 0 {
+		return alarms, nil
+	}
+
+	results := make(map[string]BatchResult, len(resp.Results))
+	for _, r := range resp.Results {
+		results[batchKey(r.EventId, r.EventType)] = r
+	}
+	var accepted []AlarmInfo
+	var rejected int
+	for _, a := range alarms {
+		r, ok := results[batchKey(a.EventId, a.EventType)]
+		if !ok {
+			// 兼容只返回eventId不返回eventType的响应
+			r, ok = results[batchKey(a.EventId, "")]
+		}
+		if ok && r.Success {
+			accepted = append(accepted, a)
+			continue
+		}
+		rejected++
+		if logger != nil {
+			logger.Warn("告警(EventId=%s, %s)未被接受: %s", a.EventId, a.EventType, r.Message)
+		}
+	}
+	if rejected > 0 {
+		return accepted, fmt.Errorf("send alarm batch partially failed: %d/%d rejected", rejected, len(alarms))
+	}
+	return accepted, nil
+}
+
+// batchKey 按eventId和eventType匹配推送结果
+func batchKey(eventId string, eventType string) string {
+	return eventId + "|" + eventType
+}
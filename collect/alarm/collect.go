@@ -1,15 +1,16 @@
 package alarm
 
 import (
+	"GoldenDB/api"
+	"GoldenDB/history"
 	"GoldenDB/log"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"sync"
-	"time"
 )
 
 var logger *log.Logger
@@ -49,10 +50,16 @@ type AlarmInfo struct {
 	Dn           string `json:"dn"`
 	Resource     Re     `json:"resource"`
 	EventType    string `json:"eventType"`
-	EventId      int    `json:"eventId"`
+	EventId      string `json:"eventId"` // 告警指纹，跨MDS全局唯一
+	AlarmId      int    `json:"alarmId"` // MDS中的原始告警ID
 	CreateTime   string `json:"createTime"`
 	Priority     int    `json:"priority"`
 	AlarmContent string `json:"alarmContent"`
+
+	// 以下字段仅用于本地记录，不推送
+	Code       int    `json:"-"`
+	Level      int    `json:"-"`
+	UpdateTime string `json:"-"`
 }
 
 /*
@@ -115,18 +122,6 @@ func (r *Reserve4) Scan(value interface{}) error {
 // 全局过滤配置
 var filterConfig *FilterConfig
 
-// 初始化过滤配置
-func init() {
-	// 尝试加载过滤配置，如果失败则使用空配置
-	config, err := LoadFilterConfig("config/alarm_filter.json")
-	if err != nil {
-		fmt.Printf("加载过滤配置失败: %v，将继续使用默认配置（不过滤）\n", err)
-	} else {
-		filterConfig = config
-		fmt.Printf("加载过滤配置成功，启用状态: %v, 规则数量: %d\n", filterConfig.Enabled, len(filterConfig.Rules))
-	}
-}
-
 // LogFilterStatus 记录过滤配置状态
 func LogFilterStatus() {
 	if logger == nil {
@@ -142,19 +137,28 @@ func LogFilterStatus() {
 	}
 }
 
-// 采集告警
-func GetAlarm(mds *sql.DB) []Alarm {
+// 采集告警，ctx用于控制查询超时；查询失败时返回错误，调用方应跳过本轮处理，避免把所有告警误判为已恢复
+func GetAlarm(ctx context.Context, mds *sql.DB, insight string) ([]Alarm, error) {
+	AlarmList, err := QueryAlarms(ctx, mds)
+	if err != nil {
+		return nil, err
+	}
+	return ProcessCollected(insight, AlarmList), nil
+}
+
+// QueryAlarms 从MDS查询当前所有告警，不做记录和过滤
+func QueryAlarms(ctx context.Context, mds *sql.DB) ([]Alarm, error) {
 	var AlarmList []Alarm
 	if logger != nil {
 		logger.Info("采集告警")
 	}
 	sqlstr := "select alarmid,alarmsource,code,almlevel,content,createtime,updatetime,reserve4 from goldendb_omm.gdb_alarming"
-	rows, err := mds.Query(sqlstr)
+	rows, err := mds.QueryContext(ctx, sqlstr)
 	if err != nil {
 		if logger != nil {
 			logger.Error("GetAlarm error: %v", err)
 		}
-		return nil
+		return nil, fmt.Errorf("查询告警失败: %w", err)
 	}
 	defer rows.Close()
 
@@ -172,21 +176,42 @@ func GetAlarm(mds *sql.DB) []Alarm {
 		}
 		AlarmList = append(AlarmList, alarms)
 	}
+	if err := rows.Err(); err != nil {
+		if logger != nil {
+			logger.Error("GetAlarm error: %v", err)
+		}
+		return nil, fmt.Errorf("读取告警失败: %w", err)
+	}
+	return AlarmList, nil
+}
+
+// ProcessCollected 记录采集历史并应用过滤规则，返回需要推送的告警
+func ProcessCollected(insight string, AlarmList []Alarm) []Alarm {
+	fresh := newlyCollected(insight, AlarmList)
+	recordAlarms(history.ActionCollected, insight, AlarmList, fresh)
 
 	// 应用过滤
 	if filterConfig != nil && filterConfig.Enabled {
 		originalCount := len(AlarmList)
-		for _, alarm := range AlarmList {
-			fmt.Printf("原始告警: %+v\n", alarm)
+		debug := logger != nil && logger.Enabled(log.LevelDebug)
+		if debug {
+			for _, alarm := range AlarmList {
+				logger.With("mds", insight, "alarm_id", alarm.Alarmid, "code", alarm.Code).Debug("原始告警: %+v", alarm)
+			}
 		}
-		AlarmList = FilterAlarms(AlarmList, filterConfig)
-		for _, alarm := range AlarmList {
-			fmt.Printf("过滤后的告警: %+v\n", alarm)
+		var filtered, silenced []Alarm
+		AlarmList, filtered, silenced = ClassifyAlarms(AlarmList, filterConfig)
+		recordAlarms(history.ActionFiltered, insight, filtered, fresh)
+		recordAlarms(history.ActionSilenced, insight, silenced, fresh)
+		if debug {
+			for _, alarm := range AlarmList {
+				logger.With("mds", insight, "alarm_id", alarm.Alarmid, "code", alarm.Code).Debug("过滤后的告警: %+v", alarm)
+			}
 		}
 		filteredCount := originalCount - len(AlarmList)
 		if filteredCount > 0 {
 			if logger != nil {
-				logger.Info("过滤了 %d 条告警，剩余 %d 条告警", filteredCount, len(AlarmList))
+				logger.Info("过滤了 %d 条告警，剩余 %d 条告警（其中屏蔽 %d 条）", filteredCount, len(AlarmList), len(silenced))
 			}
 		}
 	}
@@ -194,6 +219,26 @@ func GetAlarm(mds *sql.DB) []Alarm {
 	return AlarmList
 }
 
+// MergeAlarms 合并同一部署的多个MDS采集到的告警，按告警ID去重，保留更新时间最新的一条
+func MergeAlarms(lists ...[]Alarm) []Alarm {
+	var merged []Alarm
+	index := make(map[int]int)
+	for _, list := range lists {
+		for _, a := range list {
+			i, ok := index[a.Alarmid]
+			if !ok {
+				index[a.Alarmid] = len(merged)
+				merged = append(merged, a)
+				continue
+			}
+			if a.Updatetime > merged[i].Updatetime {
+				merged[i] = a
+			}
+		}
+	}
+	return merged
+}
+
 // 封装告警信息
 func GenAlarmInfo(alarm Alarm, insight string, eventtype string) AlarmInfo {
 	var alarmInfo AlarmInfo
@@ -206,13 +251,17 @@ func GenAlarmInfo(alarm Alarm, insight string, eventtype string) AlarmInfo {
 		Host:    alarm.Reserve4.DstInfo,
 	}
 	alarmInfo.EventType = eventtype
-	alarmInfo.EventId = alarm.Alarmid
+	alarmInfo.EventId = Fingerprint(insight, alarm)
+	alarmInfo.AlarmId = alarm.Alarmid
 	alarmInfo.CreateTime = alarm.Createtime
 	alarmInfo.Priority = alarm.Almlevel
 	if alarm.Almlevel == 8 {
 		alarmInfo.Priority = 5
 	}
 	alarmInfo.AlarmContent = alarm.Content
+	alarmInfo.Code = alarm.Code
+	alarmInfo.Level = alarm.Almlevel
+	alarmInfo.UpdateTime = alarm.Updatetime
 	return alarmInfo
 	/*
 		payload = {
@@ -250,7 +299,7 @@ func ToJSON(alarmInfo AlarmInfo) (string, error) {
 }
 
 // 发送告警
-func SendAlarmToHTTP(alarmInfo AlarmInfo, apiAddress string) error {
+func SendAlarmToHTTP(alarmInfo AlarmInfo, client *api.Client) error {
 	// 将AlarmInfo转换为JSON
 	body, err := ToJSON(alarmInfo)
 	if logger != nil {
@@ -260,36 +309,18 @@ func SendAlarmToHTTP(alarmInfo AlarmInfo, apiAddress string) error {
 		return fmt.Errorf("convert to JSON error: %w", err)
 	}
 
-	// 创建HTTP客户端，设置超时
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	// 创建POST请求
-	req, err := http.NewRequest("POST", apiAddress, strings.NewReader(body))
+	// 发送请求（认证、签名、TLS、代理和超时由client负责）
+	status, respBody, err := client.PostJSON([]byte(body))
 	if err != nil {
-		return fmt.Errorf("create request error: %w", err)
+		// 处理超时或其他错误。告警内容已在上面按日志等级记录，错误中不再附带请求体
+		return fmt.Errorf("send alarm error: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// 发送请求
-	resp, err := client.Do(req)
-	if err != nil {
-		// 处理超时或其他错误
-		return fmt.Errorf("send alarm error: %w, body: %s", err, body)
-	}
-	defer resp.Body.Close()
 
 	// 检查状态码
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("send alarm failed, http code %d", resp.StatusCode)
+	if status != http.StatusOK {
+		return fmt.Errorf("send alarm failed, http code %d", status)
 	}
 
-	// 读取并打印响应体
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("read response body error: %w", err)
-	}
 	if logger != nil {
 		logger.Info("响应: %s", string(respBody))
 	}
@@ -297,53 +328,52 @@ func SendAlarmToHTTP(alarmInfo AlarmInfo, apiAddress string) error {
 	return nil
 }
 
-// deleteAlarm 删除告警函数：设置EventType为"resolve"并发送（使用缓存中的完整信息）
-func deleteAlarm(alarm AlarmInfo, address string) error {
-	alarm.EventType = "resolve"
-	err := SendAlarmToHTTP(alarm, address)
-	return err
+// changed 判断已推送的告警级别或内容是否发生变化
+func changed(cached AlarmInfo, current AlarmInfo) bool {
+	return cached.Level != current.Level || cached.AlarmContent != current.AlarmContent
 }
-func addAlarm(alarm AlarmInfo, address string) error {
-	alarm.EventType = "trigger"
-	err := SendAlarmToHTTP(alarm, address)
-	return err
+
+// changeDetail 描述已推送告警实际发生的变化，记录在update历史中
+func changeDetail(cached AlarmInfo, current AlarmInfo) string {
+	var parts []string
+	if cached.Level != current.Level {
+		parts = append(parts, fmt.Sprintf("level %d -> %d", cached.Level, current.Level))
+	}
+	if cached.AlarmContent != current.AlarmContent {
+		parts = append(parts, fmt.Sprintf("content %q -> %q", cached.AlarmContent, current.AlarmContent))
+	}
+	return strings.Join(parts, "; ")
 }
 
-func ProcessAlarmChanges(currentAlarms []AlarmInfo, cache *sync.Map, alarmURL string) error {
+// diffAlarms 对比当前告警和缓存，返回消失的告警（取缓存中的完整信息）和新增的告警；
+// 已推送但级别或内容发生变化的告警在此记录历史并刷新缓存
+func diffAlarms(currentAlarms []AlarmInfo, cache *sync.Map) (resolved []AlarmInfo, added []AlarmInfo) {
 	// 当前告警的map，便于O(1)查找，key为EventId
-	currentMap := make(map[int]AlarmInfo)
+	currentMap := make(map[string]AlarmInfo)
 	for _, a := range currentAlarms {
 		currentMap[a.EventId] = a
 	}
 
 	// 检测消失的告警（在缓存中但不在当前）
-	var deleteErrs []error
 	cache.Range(func(key, value interface{}) bool {
-		id := key.(int)
-		if _, exists := currentMap[id]; !exists {
-			alarm := value.(AlarmInfo)
-			if err := deleteAlarm(alarm, alarmURL); err != nil {
-				deleteErrs = append(deleteErrs, err)
-			}
-			cache.Delete(id) // 无论成功失败，都从缓存移除
+		if _, exists := currentMap[key.(string)]; !exists {
+			resolved = append(resolved, value.(AlarmInfo))
 		}
 		return true
 	})
 
 	// 检测新增的告警（在当前但不在缓存）
-	var addErrs []error
 	for id, alarm := range currentMap {
-		if _, loaded := cache.Load(id); !loaded {
-			if err := addAlarm(alarm, alarmURL); err != nil {
-				addErrs = append(addErrs, err)
-			} else {
-				cache.Store(id, alarm) // 成功才添加缓存
-			}
+		cached, loaded := cache.Load(id)
+		if !loaded {
+			added = append(added, alarm)
+			continue
+		}
+		// 已推送的告警发生变化：记录历史并刷新缓存
+		if changed(cached.(AlarmInfo), alarm) {
+			recordInfo(history.ActionUpdate, alarm, changeDetail(cached.(AlarmInfo), alarm))
+			cache.Store(id, alarm)
 		}
 	}
-	// 汇总错误
-	if len(deleteErrs) > 0 || len(addErrs) > 0 {
-		return fmt.Errorf("处理变化时有错误: 添加错误(%d), 删除错误(%d)", len(addErrs), len(deleteErrs))
-	}
-	return nil
+	return resolved, added
 }
@@ -0,0 +1,259 @@
+package alarm
+
+import (
+	"GoldenDB/api"
+	"GoldenDB/history"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// DispatchConfig 推送阶段配置
+type DispatchConfig struct {
+	Workers   int  // 推送协程数量
+	QueueSize int  // 队列总容量，平均分配给各推送协程
+	Batch     bool // 是否批量推送
+	BatchSize int  // 每批最多事件数
+}
+
+// Event 待推送的告警事件，Cache为该告警所属insight的缓存
+type Event struct {
+	Info  AlarmInfo
+	Cache *sync.Map
+}
+
+// pendingKey 已入队但尚未推送完成的告警
+type pendingKey struct {
+	cache *sync.Map
+	id    string
+}
+
+// Dispatcher 采集与推送之间的有界队列和推送协程池。
+// 同一告警的事件总是进入同一个协程的队列，保证按顺序推送；
+// 告警的事件推送完成前不会再次入队，避免重复推送。
+type Dispatcher struct {
+	client  *api.Client
+	cfg     DispatchConfig
+	queues  []chan Event
+	pending sync.Map
+	wg      sync.WaitGroup
+	aborted atomic.Bool // Shutdown超时后不再推送剩余事件
+
+	delivered atomic.Int64
+	failed    atomic.Int64
+	dropped   atomic.Int64
+}
+
+// DispatchStats 推送队列运行状态
+type DispatchStats struct {
+	Workers   int   `json:"workers"`
+	QueueLen  int   `json:"queueLen"`
+	QueueSize int   `json:"queueSize"`
+	Delivered int64 `json:"delivered"` // 推送成功的事件数
+	Failed    int64 `json:"failed"`    // 推送失败的事件数
+	Dropped   int64 `json:"dropped"`   // 队列满被丢弃的事件数
+}
+
+// Stats 返回推送队列的当前状态
+func (d *Dispatcher) Stats() DispatchStats {
+	return DispatchStats{
+		Workers:   d.cfg.Workers,
+		QueueLen:  d.QueueLen(),
+		QueueSize: d.cfg.QueueSize,
+		Delivered: d.delivered.Load(),
+		Failed:    d.failed.Load(),
+		Dropped:   d.dropped.Load(),
+	}
+}
+
+// NewDispatcher 创建推送协程池，需调用Start启动
+func NewDispatcher(client *api.Client, cfg DispatchConfig) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize < cfg.Workers {
+		cfg.QueueSize = cfg.Workers
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	d := &Dispatcher{client: client, cfg: cfg}
+	for i := 0; i < cfg.Workers; i++ {
+		d.queues = append(d.queues, make(chan Event, cfg.QueueSize/cfg.Workers))
+	}
+	return d
+}
+
+// Start 启动推送协程
+func (d *Dispatcher) Start() {
+	for i := range d.queues {
+		d.wg.Add(1)
+		go d.worker(d.queues[i])
+	}
+}
+
+// Shutdown 关闭队列并等待已入队事件推送完成，ctx结束时放弃剩余事件并返回错误。
+// 被放弃的事件不更新缓存，保存状态后下次启动会重新检测并推送。
+// 调用Shutdown后不能再调用Submit。
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	for _, q := range d.queues {
+		close(q)
+	}
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		d.aborted.Store(true)
+		return fmt.Errorf("推送队列未在期限内处理完，放弃剩余 %d 个事件", d.QueueLen())
+	}
+}
+
+// QueueLen 返回当前排队中的事件数
+func (d *Dispatcher) QueueLen() int {
+	n := 0
+	for _, q := range d.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// Submit 对比当前告警与缓存，将新增和消失的告警放入推送队列，不会阻塞。
+// 队列已满时丢弃事件，下个周期会重新检测并入队。返回入队和丢弃的事件数。
+func (d *Dispatcher) Submit(currentAlarms []AlarmInfo, cache *sync.Map) (queued int, dropped int) {
+	resolved, added := diffAlarms(currentAlarms, cache)
+
+	var events []AlarmInfo
+	for _, a := range resolved {
+		a.EventType = "resolve"
+		events = append(events, a)
+	}
+	for _, a := range added {
+		a.EventType = "trigger"
+		events = append(events, a)
+	}
+
+	for _, a := range events {
+		key := pendingKey{cache: cache, id: a.EventId}
+		if _, busy := d.pending.LoadOrStore(key, struct{}{}); busy {
+			continue
+		}
+		select {
+		case d.queueFor(a) <- Event{Info: a, Cache: cache}:
+			queued++
+			if a.EventType == "resolve" {
+				recordInfo(history.ActionResolve, a, "")
+			} else {
+				recordInfo(history.ActionTrigger, a, "")
+			}
+		default:
+			d.pending.Delete(key)
+			dropped++
+		}
+	}
+	d.dropped.Add(int64(dropped))
+	if dropped > 0 && logger != nil {
+		logger.Warn("推送队列已满，丢弃 %d 个事件，将在下个周期重试", dropped)
+	}
+	return queued, dropped
+}
+
+// queueFor 按告警指纹选择推送协程
+func (d *Dispatcher) queueFor(a AlarmInfo) chan Event {
+	h := fnv.New32a()
+	h.Write([]byte(a.EventId))
+	return d.queues[h.Sum32()%uint32(len(d.queues))]
+}
+
+func (d *Dispatcher) worker(queue chan Event) {
+	defer d.wg.Done()
+	for ev := range queue {
+		if d.aborted.Load() {
+			d.pending.Delete(pendingKey{cache: ev.Cache, id: ev.Info.EventId})
+			continue
+		}
+		if !d.cfg.Batch {
+			d.deliver(ev)
+			continue
+		}
+		// 批量模式：取出队列中已有的事件凑成一批
+		batch := []Event{ev}
+	fill:
+		for len(batch) < d.cfg.BatchSize {
+			select {
+			case next, ok := <-queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		d.deliverBatch(batch)
+	}
+}
+
+// deliver 推送单个事件；trigger成功才写入缓存，resolve无论成败都从缓存移除，失败的trigger下个周期重新产生
+func (d *Dispatcher) deliver(ev Event) {
+	defer d.pending.Delete(pendingKey{cache: ev.Cache, id: ev.Info.EventId})
+
+	err := SendAlarmToHTTP(ev.Info, d.client)
+	recordDelivery(ev.Info, err)
+	d.count(err == nil)
+	if err != nil && logger != nil {
+		logger.With("mds", ev.Info.Dn, "alarm_id", ev.Info.AlarmId, "code", ev.Info.Code, "sink", d.client.URL()).Error("推送告警失败(EventId=%s, %s): %v", ev.Info.EventId, ev.Info.EventType, err)
+	}
+	if ev.Info.EventType == "resolve" {
+		ev.Cache.Delete(ev.Info.EventId)
+	} else if err == nil {
+		ev.Cache.Store(ev.Info.EventId, ev.Info)
+	}
+}
+
+// deliverBatch 批量推送；只有被接受的事件才更新缓存
+func (d *Dispatcher) deliverBatch(batch []Event) {
+	infos := make([]AlarmInfo, 0, len(batch))
+	for _, ev := range batch {
+		infos = append(infos, ev.Info)
+	}
+	accepted, err := SendAlarmsToHTTP(infos, d.client)
+	if err != nil && logger != nil {
+		logger.With("sink", d.client.URL()).Error("批量推送告警失败: %v", err)
+	}
+	ok := make(map[string]bool, len(accepted))
+	for _, a := range accepted {
+		ok[batchKey(a.EventId, a.EventType)] = true
+	}
+
+	for _, ev := range batch {
+		a := ev.Info
+		if !ok[batchKey(a.EventId, a.EventType)] {
+			recordDelivery(a, fmt.Errorf("not accepted: %v", err))
+			d.count(false)
+		} else {
+			recordDelivery(a, nil)
+			d.count(true)
+			if a.EventType == "resolve" {
+				ev.Cache.Delete(a.EventId)
+			} else {
+				ev.Cache.Store(a.EventId, a)
+			}
+		}
+		d.pending.Delete(pendingKey{cache: ev.Cache, id: a.EventId})
+	}
+}
+
+func (d *Dispatcher) count(ok bool) {
+	if ok {
+		d.delivered.Add(1)
+	} else {
+		d.failed.Add(1)
+	}
+}
@@ -19,9 +19,16 @@ type Filter struct {
 	Name    string `json:"name"`
 	Enabled bool   `json:"enabled"`
 	Filters []Rule `json:"filters"`
-	Logic   string `json:"logic"` // "AND" 或 "OR"
+	Logic   string `json:"logic"`  // "AND" 或 "OR"
+	Action  string `json:"action"` // "filter"（默认，直接丢弃）或 "silence"（记录历史但不推送）
 }
 
+// 规则动作
+const (
+	ActionFilter  = "filter"
+	ActionSilence = "silence"
+)
+
 // Rule 单个过滤条件
 type Rule struct {
 	Field    string      `json:"field"`    // "code", "content", "dstinfo"
@@ -35,33 +42,120 @@ func LoadFilterConfig(filename string) (*FilterConfig, error) {
 	if err != nil {
 		return nil, fmt.Errorf("读取过滤配置文件失败: %w", err)
 	}
+	return ParseFilterConfig(data)
+}
 
+// ParseFilterConfig 解析JSON格式的过滤配置
+func ParseFilterConfig(data []byte) (*FilterConfig, error) {
 	var config FilterConfig
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("解析过滤配置失败: %w", err)
 	}
-
 	return &config, nil
 }
 
+// SetFilterConfig 设置采集时使用的过滤配置，nil表示不过滤
+func SetFilterConfig(config *FilterConfig) {
+	filterConfig = config
+}
+
+// ValidateFilterConfig 检查每条规则的字段、运算符和取值，返回发现的问题
+func ValidateFilterConfig(config *FilterConfig) []string {
+	var problems []string
+	names := make(map[string]bool)
+	for i, rule := range config.Rules {
+		prefix := fmt.Sprintf("规则 #%d (%s)", i+1, rule.Name)
+		if rule.Name == "" {
+			problems = append(problems, prefix+": name不能为空")
+		} else if names[rule.Name] {
+			problems = append(problems, prefix+": 规则名称重复")
+		}
+		names[rule.Name] = true
+		if rule.Logic != "" && rule.Logic != "AND" && rule.Logic != "OR" {
+			problems = append(problems, prefix+": logic只能是 AND 或 OR")
+		}
+		if rule.Action != "" && rule.Action != ActionFilter && rule.Action != ActionSilence {
+			problems = append(problems, prefix+": action只能是 filter 或 silence")
+		}
+		if len(rule.Filters) == 0 {
+			problems = append(problems, prefix+": 没有过滤条件")
+		}
+		for j, cond := range rule.Filters {
+			if msg := validateCondition(cond); msg != "" {
+				problems = append(problems, fmt.Sprintf("%s 条件 #%d: %s", prefix, j+1, msg))
+			}
+		}
+	}
+	return problems
+}
+
+// validateCondition 与matchesCondition的匹配逻辑保持一致，不会生效的条件视为错误
+func validateCondition(cond Rule) string {
+	switch cond.Field {
+	case "code":
+		if cond.Operator != "equals" {
+			return "code字段只支持 equals 运算符"
+		}
+		switch v := cond.Value.(type) {
+		case float64:
+			if v != float64(int(v)) {
+				return fmt.Sprintf("code取值必须是整数: %v", v)
+			}
+		case string:
+			if _, err := strconv.Atoi(v); err != nil {
+				return fmt.Sprintf("code取值必须是整数: %q", v)
+			}
+		default:
+			return fmt.Sprintf("code取值必须是整数: %v", cond.Value)
+		}
+	case "content", "dstinfo":
+		if cond.Operator != "contains" {
+			return cond.Field + "字段只支持 contains 运算符"
+		}
+		switch v := cond.Value.(type) {
+		case string:
+			if v == "" {
+				return "取值不能为空字符串，否则会匹配所有告警"
+			}
+		case float64:
+		default:
+			return fmt.Sprintf("取值必须是字符串或数字: %v", cond.Value)
+		}
+	default:
+		return fmt.Sprintf("不支持的字段 %q，只能是 code、content、dstinfo", cond.Field)
+	}
+	return ""
+}
+
 // FilterAlarms 过滤告警列表
 func FilterAlarms(alarms []Alarm, config *FilterConfig) []Alarm {
+	kept, _, _ := ClassifyAlarms(alarms, config)
+	return kept
+}
+
+// ClassifyAlarms 按过滤规则将告警分为保留、过滤、屏蔽三类
+func ClassifyAlarms(alarms []Alarm, config *FilterConfig) (kept, filtered, silenced []Alarm) {
 	if config == nil || !config.Enabled {
-		return alarms
+		return alarms, nil, nil
 	}
 
-	var result []Alarm
 	for _, alarm := range alarms {
-		if !shouldFilter(alarm, config) {
-			result = append(result, alarm)
+		rule := matchedRule(alarm, config)
+		switch {
+		case rule == nil:
+			kept = append(kept, alarm)
+		case rule.Action == ActionSilence:
+			silenced = append(silenced, alarm)
+		default:
+			filtered = append(filtered, alarm)
 		}
 	}
-	return result
+	return kept, filtered, silenced
 }
 
-// shouldFilter 检查告警是否应该被过滤
-func shouldFilter(alarm Alarm, config *FilterConfig) bool {
-	for _, rule := range config.Rules {
+// matchedRule 返回告警命中的第一条启用规则，未命中返回nil
+func matchedRule(alarm Alarm, config *FilterConfig) *Filter {
+	for i, rule := range config.Rules {
 		if !rule.Enabled {
 			continue
 		}
@@ -70,10 +164,10 @@ func shouldFilter(alarm Alarm, config *FilterConfig) bool {
 			if logger != nil {
 				logger.Info("告警(ID=%d, Code=%d) 匹配过滤规则: %s", alarm.Alarmid, alarm.Code, rule.Name)
 			}
-			return true
+			return &config.Rules[i]
 		}
 	}
-	return false
+	return nil
 }
 
 // matchesRule 检查告警是否匹配规则
@@ -0,0 +1,19 @@
+package alarm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// Fingerprint 生成全局唯一且稳定的告警指纹，作为推送的eventId。
+// gdb_alarming.alarmid只在单个MDS内唯一，多套GoldenDB推送到同一平台时会冲突，
+// 因此由MDS名称、告警ID、告警代码和告警对象共同计算。
+func Fingerprint(mdsName string, alarm Alarm) string {
+	h := sha256.New()
+	for _, part := range []string{mdsName, strconv.Itoa(alarm.Alarmid), strconv.Itoa(alarm.Code), alarm.Reserve4.DstInfo} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
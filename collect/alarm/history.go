@@ -0,0 +1,105 @@
+package alarm
+
+import (
+	"GoldenDB/history"
+	"fmt"
+	"sync"
+)
+
+var historyStore *history.Store
+
+// SetHistory 设置告警历史存储，为nil时不记录
+func SetHistory(s *history.Store) {
+	historyStore = s
+}
+
+// collectedSeen 记录每个insight上一轮采集到的告警版本（alarmid+updatetime），
+// 只有新出现或发生变化的告警才写入采集历史，避免每个周期重复记录
+var collectedSeen sync.Map
+
+// newlyCollected 返回本轮新出现或有更新的告警ID集合
+func newlyCollected(insight string, alarms []Alarm) map[int]bool {
+	current := make(map[string]struct{}, len(alarms))
+	for _, a := range alarms {
+		current[versionKey(a)] = struct{}{}
+	}
+
+	var previous map[string]struct{}
+	if v, ok := collectedSeen.Load(insight); ok {
+		previous = v.(map[string]struct{})
+	}
+	collectedSeen.Store(insight, current)
+
+	fresh := make(map[int]bool)
+	for _, a := range alarms {
+		if _, seen := previous[versionKey(a)]; !seen {
+			fresh[a.Alarmid] = true
+		}
+	}
+	return fresh
+}
+
+func versionKey(a Alarm) string {
+	return fmt.Sprintf("%d|%s", a.Alarmid, a.Updatetime)
+}
+
+// recordAlarms 将原始告警写入历史，only不为nil时只记录其中的告警
+func recordAlarms(action string, insight string, alarms []Alarm, only map[int]bool) {
+	if historyStore == nil {
+		return
+	}
+	var events []history.Event
+	for _, a := range alarms {
+		if only != nil && !only[a.Alarmid] {
+			continue
+		}
+		events = append(events, history.Event{
+			Action:      action,
+			Insight:     insight,
+			Cluster:     a.Reserve4.DstClusterName,
+			AlarmID:     a.Alarmid,
+			Fingerprint: Fingerprint(insight, a),
+			Code:        a.Code,
+			Level:       a.Almlevel,
+			Host:        a.Reserve4.DstInfo,
+			Content:     a.Content,
+			CreateTime:  a.Createtime,
+		})
+	}
+	writeHistory(events...)
+}
+
+// recordInfo 将推送相关的生命周期事件写入历史
+func recordInfo(action string, info AlarmInfo, detail string) {
+	if historyStore == nil {
+		return
+	}
+	writeHistory(history.Event{
+		Action:      action,
+		Insight:     info.Dn,
+		Cluster:     info.Resource.Tenant,
+		AlarmID:     info.AlarmId,
+		Fingerprint: info.EventId,
+		Code:        info.Code,
+		Level:       info.Level,
+		Host:        info.Resource.Host,
+		Content:     info.AlarmContent,
+		CreateTime:  info.CreateTime,
+		Detail:      detail,
+	})
+}
+
+// recordDelivery 记录推送结果
+func recordDelivery(info AlarmInfo, err error) {
+	if err != nil {
+		recordInfo(history.ActionDeliveryFailed, info, fmt.Sprintf("%s: %v", info.EventType, err))
+		return
+	}
+	recordInfo(history.ActionDelivered, info, info.EventType)
+}
+
+func writeHistory(events ...history.Event) {
+	if err := historyStore.Record(events...); err != nil && logger != nil {
+		logger.Error("写入告警历史失败: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package alarm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// stateAlarm 持久化的已推送告警，补充AlarmInfo中不参与推送的字段
+type stateAlarm struct {
+	AlarmInfo
+	Code       int    `json:"code"`
+	Level      int    `json:"level"`
+	UpdateTime string `json:"updateTime"`
+}
+
+// savedState 启动或成为主实例时加载的状态，insight -> 告警列表，
+// 在RegisterCache时恢复到对应insight的缓存
+var (
+	savedMu    sync.Mutex
+	savedState map[string][]AlarmInfo
+)
+
+// LoadState 加载已推送告警状态，文件不存在时视为空状态
+func LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		setSavedState(nil)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取告警状态失败: %w", err)
+	}
+	var raw map[string][]stateAlarm
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("解析告警状态失败: %w", err)
+	}
+	state := make(map[string][]AlarmInfo, len(raw))
+	for insight, list := range raw {
+		for _, s := range list {
+			info := s.AlarmInfo
+			info.Code = s.Code
+			info.Level = s.Level
+			info.UpdateTime = s.UpdateTime
+			state[insight] = append(state[insight], info)
+		}
+	}
+	setSavedState(state)
+	return nil
+}
+
+func setSavedState(state map[string][]AlarmInfo) {
+	savedMu.Lock()
+	savedState = state
+	savedMu.Unlock()
+}
+
+// restoreCache 将已加载的状态恢复到insight的缓存，返回恢复的告警数
+func restoreCache(insight string, cache *sync.Map) int {
+	savedMu.Lock()
+	list := savedState[insight]
+	delete(savedState, insight)
+	savedMu.Unlock()
+	for _, info := range list {
+		cache.Store(info.EventId, info)
+	}
+	return len(list)
+}
+
+// SaveState 保存所有insight当前已推送的告警，先写临时文件再重命名，避免写一半的文件
+func SaveState(path string) error {
+	raw := make(map[string][]stateAlarm)
+	for insight, list := range ActiveAlarms() {
+		for _, info := range list {
+			raw[insight] = append(raw[insight], stateAlarm{AlarmInfo: info, Code: info.Code, Level: info.Level, UpdateTime: info.UpdateTime})
+		}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("序列化告警状态失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建状态目录失败: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入告警状态失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入告警状态失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Client 带认证、签名和TLS设置的HTTP推送客户端
+type Client struct {
+	url        string
+	http       *http.Client
+	authType   string
+	token      string
+	username   string
+	password   string
+	secret     []byte
+	signHeader string
+	tsHeader   string
+}
+
+// NewClient 根据目的地设置创建客户端，address为目的地未配置url时使用的地址。
+// 配置中的token、password、secret为加密密文，在此解密。
+func NewClient(address string, dest config.Destination) (*Client, error) {
+	c := &Client{
+		url:        address,
+		authType:   dest.Auth.Type,
+		username:   dest.Auth.Username,
+		signHeader: dest.Sign.Header,
+		tsHeader:   dest.Sign.TimestampHeader,
+	}
+	if dest.URL != "" {
+		c.url = dest.URL
+	}
+	if c.url == "" {
+		return nil, fmt.Errorf("未配置推送地址")
+	}
+	if c.signHeader == "" {
+		c.signHeader = "X-Signature"
+	}
+	if c.tsHeader == "" {
+		c.tsHeader = "X-Timestamp"
+	}
+
+	var err error
+	switch dest.Auth.Type {
+	case "":
+	case "bearer":
+		if c.token, err = decrypt("auth.token", dest.Auth.Token); err != nil {
+			return nil, err
+		}
+	case "basic":
+		if c.password, err = decrypt("auth.password", dest.Auth.Password); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的认证方式: %s", dest.Auth.Type)
+	}
+	if dest.Sign.Secret != "" {
+		secret, err := decrypt("sign.secret", dest.Sign.Secret)
+		if err != nil {
+			return nil, err
+		}
+		c.secret = []byte(secret)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if dest.Proxy != "" {
+		proxy, err := url.Parse(dest.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("代理地址错误: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	tlsConfig, err := newTLSConfig(dest)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := defaultTimeout
+	if dest.Timeout > 0 {
+		timeout = time.Duration(dest.Timeout) * time.Second
+	}
+	c.http = &http.Client{Transport: transport, Timeout: timeout}
+	return c, nil
+}
+
+func decrypt(field string, value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("%s 未配置", field)
+	}
+	plain, err := connect.Decrypt(value)
+	if err != nil {
+		return "", fmt.Errorf("解密 %s 失败: %w", field, err)
+	}
+	return plain, nil
+}
+
+func newTLSConfig(dest config.Destination) (*tls.Config, error) {
+	t := dest.TLS
+	tlsConfig := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.CA != "" {
+		pem, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书 %s 中没有有效的PEM证书", t.CA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if t.Cert != "" || t.Key != "" {
+		cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// URL 返回推送地址
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Probe 向推送地址发送HEAD请求，检查网络、代理和TLS握手是否正常，返回状态码。
+// 不发送告警内容，服务端返回任何HTTP状态码都说明地址可达
+func (c *Client) Probe() (int, error) {
+	req, err := http.NewRequest("HEAD", c.url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("create request error: %w", err)
+	}
+	switch c.authType {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case "basic":
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// Sign 计算签名: hex(HMAC-SHA256(secret, timestamp + "." + body))
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostJSON 发送JSON请求，返回状态码和响应体
+func (c *Client) PostJSON(body []byte) (int, []byte, error) {
+	req, err := http.NewRequest("POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("create request error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	switch c.authType {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case "basic":
+		req.SetBasicAuth(c.username, c.password)
+	}
+	if len(c.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(c.tsHeader, timestamp)
+		req.Header.Set(c.signHeader, Sign(c.secret, timestamp, body))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("read response body error: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
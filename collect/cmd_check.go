@@ -0,0 +1,169 @@
+package main
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/api"
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"GoldenDB/redact"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// runCheckConfig 检查配置文件：校验各配置项和过滤规则、解密所有密文，
+// 指定 -connect 时再测试各MDS、CN和推送地址能否连通。有错误时返回1
+// 用法: check-config [-connect] [-timeout 秒]
+func runCheckConfig(args []string) int {
+	fs := flag.NewFlagSet("check-config", flag.ExitOnError)
+	connectTest := fs.Bool("connect", false, "测试MDS、CN、推送地址和SMTP服务器能否连通")
+	timeout := fs.Int("timeout", 5, "连通性测试超时（秒）")
+	fs.Parse(args)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("配置版本: %d\n", cfg.Version)
+	for _, source := range cfg.Sources {
+		fmt.Printf("读取: %s\n", source)
+	}
+	if cfg.Version < config.CurrentVersion {
+		fmt.Printf("提示: 旧版配置格式，可将MDS、CN和过滤规则合并到主配置文件并设置 version: %d\n", config.CurrentVersion)
+	}
+
+	var failed int
+	report := func(field, format string, args ...interface{}) {
+		failed++
+		fmt.Printf("  [错误] %s: %s\n", field, fmt.Sprintf(format, args...))
+	}
+
+	fmt.Println("检查配置项:")
+	for _, p := range cfg.Validate() {
+		report(p.Field, "%s", p.Message)
+	}
+
+	fmt.Println("检查告警过滤规则:")
+	if len(cfg.FilterJSON) == 0 {
+		fmt.Println("  未配置过滤规则")
+	} else if filterConfig, err := alarm.ParseFilterConfig(cfg.FilterJSON); err != nil {
+		report("filter", "%v", err)
+	} else {
+		for _, p := range alarm.ValidateFilterConfig(filterConfig) {
+			report("filter", "%s", p)
+		}
+		fmt.Printf("  共 %d 条规则, Enabled=%v\n", len(filterConfig.Rules), filterConfig.Enabled)
+	}
+
+	fmt.Println("检查加密密码:")
+	if err := initKey(cfg); err != nil {
+		report("security", "%v", err)
+	} else {
+		if keyOptions(cfg).Source == connect.KeySourceBuiltin {
+			fmt.Println("  提示: 正在使用内置密钥，建议执行 rotate-key 更换")
+		}
+		for _, s := range cfg.Secrets() {
+			if _, err := connect.Decrypt(s.Value); err != nil {
+				report(s.Field, "解密失败: %v", err)
+			}
+		}
+	}
+
+	if *connectTest {
+		fmt.Println("检查连通性:")
+		failed += checkConnectivity(cfg, time.Duration(*timeout)*time.Second)
+	}
+
+	if failed > 0 {
+		fmt.Printf("发现 %d 个问题\n", failed)
+		return 1
+	}
+	fmt.Println("配置检查通过")
+	return 0
+}
+
+// checkConnectivity 逐个测试MDS、CN、推送地址和SMTP服务器，返回失败数量
+func checkConnectivity(cfg *config.Config, timeout time.Duration) int {
+	failed := 0
+	result := func(name string, err error) {
+		if err != nil {
+			failed++
+			fmt.Printf("  [失败] %s: %s\n", name, redact.Error(err))
+			return
+		}
+		fmt.Printf("  [正常] %s\n", name)
+	}
+
+	// 逐个节点生成连接配置，一个节点的密码错误不影响测试其它节点
+	for _, m := range cfg.MDS {
+		mdsList, err := connect.GetMDS(&config.Config{MDS: []config.MDS{m}})
+		if err == nil {
+			err = pingNode(mdsList[0].Node, timeout)
+		}
+		result("MDS "+m.Name, err)
+	}
+	for _, cn := range cfg.CN {
+		cnList, err := connect.GetCN(&config.Config{CN: []config.CN{cn}})
+		if err == nil {
+			err = pingNode(cnList[0].Node, timeout)
+		}
+		result("CN "+cn.Name, err)
+	}
+
+	result("告警推送 "+alarmAddress(cfg), probeDestination(cfg.Alarm.ApiAddress, cfg.Alarm.HTTP))
+	if cfg.Digest.Enabled {
+		switch cfg.Digest.Type {
+		case "webhook":
+			result("摘要推送 "+cfg.Digest.Webhook, probeDestination(cfg.Digest.Webhook, cfg.Digest.HTTP))
+		case "email":
+			addr := net.JoinHostPort(cfg.Digest.Email.Host, strconv.Itoa(cfg.Digest.Email.Port))
+			result("SMTP "+addr, dialTCP(addr, timeout))
+		}
+	}
+	return failed
+}
+
+func alarmAddress(cfg *config.Config) string {
+	if cfg.Alarm.HTTP.URL != "" {
+		return cfg.Alarm.HTTP.URL
+	}
+	return cfg.Alarm.ApiAddress
+}
+
+// pingNode 使用节点的超时和TLS设置建立一个连接并执行Ping，-timeout 限制总的等待时间
+func pingNode(n connect.Node, timeout time.Duration) error {
+	f := connect.NewFactory()
+	defer f.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := f.Connect(ctx, n)
+	return err
+}
+
+// probeDestination 使用与推送相同的认证、代理和TLS设置访问推送地址
+func probeDestination(address string, dest config.Destination) error {
+	client, err := api.NewClient(address, dest)
+	if err != nil {
+		return err
+	}
+	code, err := client.Probe()
+	if err != nil {
+		return err
+	}
+	if code == 401 || code == 403 {
+		return fmt.Errorf("认证失败, HTTP %d", code)
+	}
+	return nil
+}
+
+func dialTCP(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"GoldenDB/history"
+	"GoldenDB/tools"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// runHistory 查询本地告警历史
+// 用法: history [-from 时间] [-to 时间] [-cluster 名称] [-code 代码] [-level 级别] [-action 动作] [-format table|csv|json] [-o 文件]
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	from := fs.String("from", "", "开始时间，格式 2006-01-02 或 \"2006-01-02 15:04:05\"，默认24小时前")
+	to := fs.String("to", "", "结束时间（含），格式同 -from，只给日期时到当天结束，默认当前时间")
+	cluster := fs.String("cluster", "", "insight名称或GoldenDB集群名")
+	code := fs.Int("code", 0, "告警代码")
+	level := fs.Int("level", 0, "告警级别")
+	action := fs.String("action", "", "生命周期动作: collected|trigger|update|resolve|filtered|silenced|delivered|delivery_failed")
+	format := fs.String("format", "table", "输出格式: table|csv|json")
+	output := fs.String("o", "", "输出文件，csv默认写入 history-YYYYMMDDHHMMSS.csv，其它格式默认输出到屏幕")
+	fs.Parse(args)
+
+	q := history.Query{Cluster: *cluster, Code: *code, Level: *level, Action: *action}
+	var err error
+	if q.From, err = parseTime(*from, time.Now().Add(-24*time.Hour)); err != nil {
+		fmt.Println("开始时间格式错误:", err)
+		os.Exit(2)
+	}
+	if q.To, err = parseEndTime(*to, time.Now()); err != nil {
+		fmt.Println("结束时间格式错误:", err)
+		os.Exit(2)
+	}
+
+	store, err := openHistoryStore()
+	if err != nil {
+		fmt.Println("打开告警历史失败:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	events, err := store.Query(q)
+	if err != nil {
+		fmt.Println("查询告警历史失败:", err)
+		os.Exit(1)
+	}
+
+	switch *format {
+	case "json":
+		if events == nil {
+			events = []history.Event{}
+		}
+		b, err := json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			fmt.Println("生成JSON失败:", err)
+			os.Exit(1)
+		}
+		if *output == "" {
+			fmt.Println(string(b))
+			return
+		}
+		if err := os.WriteFile(*output, b, 0644); err != nil {
+			fmt.Println("写入文件失败:", err)
+			os.Exit(1)
+		}
+	case "csv":
+		path := *output
+		if path == "" {
+			path = "history-" + time.Now().Format("20060102150405") + ".csv"
+		}
+		if err := tools.PrintSliceAsTable(eventRows(events), true, path); err != nil {
+			fmt.Println("写入CSV失败:", err)
+			os.Exit(1)
+		}
+	case "table":
+		if err := tools.PrintSliceAsTable(eventRows(events), false, ""); err != nil {
+			fmt.Println("输出表格失败:", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Println("不支持的输出格式:", *format)
+		os.Exit(2)
+	}
+}
+
+// openHistoryStore 按配置文件打开历史存储，配置缺失时使用默认目录
+func openHistoryStore() (*history.Store, error) {
+	dir, keepDays := "data/history", 30
+	if cfg, err := loadConfig(); err == nil {
+		dir, keepDays = cfg.History.Path, cfg.History.KeepDays
+	}
+	return history.Open(dir, keepDays)
+}
+
+// parseTime 解析命令行时间参数，为空时返回默认值
+func parseTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间 %q", value)
+}
+
+// parseEndTime 解析结束时间，只给日期时为当天最后一刻，如 -to 2026-10-18 包含18日全天
+func parseEndTime(value string, def time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+	}
+	return parseTime(value, def)
+}
+
+func eventRows(events []history.Event) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(events))
+	for _, ev := range events {
+		rows = append(rows, map[string]interface{}{
+			"time":        ev.Time.Format("2006-01-02 15:04:05"),
+			"action":      ev.Action,
+			"insight":     ev.Insight,
+			"cluster":     ev.Cluster,
+			"alarm_id":    ev.AlarmID,
+			"fingerprint": ev.Fingerprint,
+			"code":        ev.Code,
+			"level":       ev.Level,
+			"host":        ev.Host,
+			"content":     ev.Content,
+			"detail":      ev.Detail,
+		})
+	}
+	return rows
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// keyOptions 实际使用的密钥来源：配置了 security.key_source 时以配置为准，
+// 否则与 checkPartition 相同，按环境变量 GDB_KEY_FILE、GDB_KEY、GDB_KEY_SALT 确定
+func keyOptions(cfg *config.Config) connect.KeyOptions {
+	if cfg.Security.KeySource == "" {
+		return connect.KeyOptionsFromEnv()
+	}
+	return connect.KeyOptions{Source: cfg.Security.KeySource, File: cfg.Security.KeyFile, Salt: cfg.Security.Salt}
+}
+
+// initKey 按配置加载密钥，供解密配置中的密文使用
+func initKey(cfg *config.Config) error {
+	key, err := connect.LoadKey(keyOptions(cfg))
+	if err != nil {
+		return fmt.Errorf("加载密钥失败: %w", err)
+	}
+	connect.SetKey(key)
+	return nil
+}
+
+// runEncrypt 用配置的密钥加密密码。未在命令行给出明文时，在终端不回显地输入两次，
+// 或从标准输入读取一行，避免明文留在shell历史和进程列表中
+// 用法: -p [明文密码 | -]
+func runEncrypt(args []string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取配置失败: %v\n", err)
+		return 1
+	}
+	if err := initKey(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var plain string
+	if len(args) > 0 && args[0] != "-" {
+		fmt.Fprintln(os.Stderr, "警告: 命令行中的明文密码会留在shell历史和进程列表中，建议直接运行 -p 后按提示输入")
+		plain = args[0]
+	} else if plain, err = connect.ReadSecret("请输入明文密码: ", true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if plain == "" {
+		fmt.Fprintln(os.Stderr, "密码不能为空")
+		return 1
+	}
+	encrypt, err := connect.Encrypt(plain)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "加密失败")
+		return 1
+	}
+	fmt.Println(encrypt)
+	return 0
+}
+
+// runRotateKey 生成新密钥并用它重新加密配置文件中的所有密文
+// 用法: rotate-key [-source file|env|passphrase] [-key-file 文件]
+func runRotateKey(args []string) int {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	source := fs.String("source", connect.KeySourceFile, "新密钥来源: file | env | passphrase")
+	keyFile := fs.String("key-file", "", "file方式的新密钥文件，默认使用 security.key_file")
+	fs.Parse(args)
+
+	// 旧密钥按实际生效的配置（含环境变量覆盖）加载，改写的内容只来自配置文件本身
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return 1
+	}
+	oldKey, err := connect.LoadKey(keyOptions(cfg))
+	if err != nil {
+		fmt.Printf("加载当前密钥失败: %v\n", err)
+		return 1
+	}
+	files, err := config.LoadFile(configPath)
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return 1
+	}
+
+	// 先用旧密钥解密全部密文，任何一个失败都不改写文件
+	secrets := files.Secrets()
+	plain := make(map[string]string, len(secrets))
+	for _, s := range secrets {
+		value, err := connect.DecryptWith(oldKey, s.Value)
+		if err != nil {
+			fmt.Printf("%s (%s) 无法用当前密钥解密: %v\n", s.Field, s.File, err)
+			return 1
+		}
+		plain[s.Field] = value
+	}
+
+	sec := files.Security
+	sec.KeySource = *source
+	sec.Salt = ""
+	var newKey []byte
+	switch *source {
+	case connect.KeySourceFile, connect.KeySourceEnv:
+		newKey, err = connect.GenerateKey()
+		if *keyFile != "" {
+			sec.KeyFile = *keyFile
+		}
+	case connect.KeySourcePassphrase:
+		var passphrase string
+		if passphrase, err = connect.ReadSecret("请输入新的密钥口令: ", true); err != nil {
+			break
+		}
+		if len(passphrase) < 8 {
+			err = fmt.Errorf("口令至少需要8个字符")
+			break
+		}
+		var salt []byte
+		if salt, err = connect.NewSalt(); err != nil {
+			break
+		}
+		sec.Salt = base64.StdEncoding.EncodeToString(salt)
+		newKey, err = connect.DeriveKey(passphrase, salt)
+	default:
+		err = fmt.Errorf("不支持的密钥来源: %s", *source)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	values := make(map[string]string, len(plain))
+	for field, value := range plain {
+		if values[field], err = connect.EncryptWith(newKey, value); err != nil {
+			fmt.Printf("加密 %s 失败: %v\n", field, err)
+			return 1
+		}
+	}
+
+	// 新密钥先写入临时文件，配置改写成功后再替换，避免新密文没有对应的密钥
+	suffix := backupSuffix(append(files.Sources, sec.KeyFile, keyOptions(cfg).File))
+	tmpKey := sec.KeyFile + ".new"
+	if *source == connect.KeySourceFile {
+		if err := connect.WriteKeyFile(tmpKey, newKey); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	written, err := files.RewriteSecrets(values, sec, suffix)
+	if err != nil {
+		os.Remove(tmpKey)
+		fmt.Printf("改写配置失败: %v\n", err)
+		return 1
+	}
+	for _, name := range written {
+		fmt.Printf("已改写 %s，原文件备份为 %s\n", name, name+suffix)
+	}
+	fmt.Printf("已重新加密 %d 个密文\n", len(values))
+
+	switch *source {
+	case connect.KeySourceFile:
+		if _, err := os.Stat(sec.KeyFile); err == nil {
+			if err := os.Rename(sec.KeyFile, sec.KeyFile+suffix); err != nil {
+				fmt.Printf("备份旧密钥文件失败: %v，新密钥保存在 %s\n", err, tmpKey)
+				return 1
+			}
+			fmt.Printf("旧密钥文件备份为 %s\n", sec.KeyFile+suffix)
+		}
+		if err := os.Rename(tmpKey, sec.KeyFile); err != nil {
+			fmt.Printf("替换密钥文件失败: %v，新密钥保存在 %s\n", err, tmpKey)
+			return 1
+		}
+		fmt.Printf("新密钥已写入 %s（权限0600）\n", sec.KeyFile)
+	case connect.KeySourceEnv:
+		fmt.Printf("新密钥（请设置到运行环境的 %s 中，并妥善保管）:\n%s\n", connect.EnvKey, connect.EncodeKey(newKey))
+	case connect.KeySourcePassphrase:
+		fmt.Printf("已启用口令派生密钥，运行时通过环境变量 %s 提供口令，或在终端按提示输入\n", connect.EnvPassphrase)
+	}
+	fmt.Println("确认服务可以正常启动后，请删除备份文件；运行中的服务需要重启后才会使用新密钥")
+	return 0
+}
+
+// backupSuffix 返回备份文件的后缀，同一秒内多次执行时追加序号，不覆盖已有备份
+func backupSuffix(names []string) string {
+	base := ".bak-" + time.Now().Format("20060102150405")
+	suffix := base
+	for i := 1; ; i++ {
+		taken := false
+		for _, name := range names {
+			if _, err := os.Stat(name + suffix); err == nil {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return suffix
+		}
+		suffix = fmt.Sprintf("%s.%d", base, i)
+	}
+}
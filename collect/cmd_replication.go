@@ -0,0 +1,109 @@
+package main
+
+import (
+	"GoldenDB/connect"
+	"GoldenDB/monitor"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runReplication 立即检查一次各DN组的主备复制状态并输出，阈值取自配置的 replication 段。
+// 有异常时返回1
+// 用法: replication [-format text|json]
+func runReplication(args []string) int {
+	fs := flag.NewFlagSet("replication", flag.ExitOnError)
+	format := fs.String("format", "text", "输出格式: text|json")
+	fs.Parse(args)
+	if *format != "text" && *format != "json" {
+		fmt.Println("不支持的输出格式:", *format)
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return 1
+	}
+	if err := initKey(cfg); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	mdsList, err := connect.GetMDS(cfg)
+	if err != nil {
+		fmt.Printf("读取MDS配置失败: %v\n", err)
+		return 1
+	}
+	defer connect.CloseAll()
+
+	results := monitor.NewReplicationMonitor(cfg.Replication, mdsList, cfg.DNConn()).Collect(context.Background())
+	failed := 0
+	for _, r := range results {
+		if len(r.Problems) > 0 {
+			failed++
+		}
+	}
+
+	if *format == "json" {
+		if results == nil {
+			results = []monitor.ReplicaResult{}
+		}
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			fmt.Println("生成JSON失败:", err)
+			return 1
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, r := range results {
+			fmt.Println(replicaLine(r))
+		}
+		fmt.Printf("共检查 %d 个复制通道，%d 个异常\n", len(results), failed)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// replicaLine 一个复制通道检查结果的单行描述
+func replicaLine(r monitor.ReplicaResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "部署 %s", r.Deployment)
+	if r.Cluster != "" {
+		fmt.Fprintf(&b, " 集群 %s", r.Cluster)
+	}
+	if r.Addr != "" {
+		fmt.Fprintf(&b, " DN组 %s 备库 %s", r.Group, r.Addr)
+		if r.Channel != "" {
+			fmt.Fprintf(&b, " 通道 %s", r.Channel)
+		}
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, ": 检查失败, 错误: %s", r.Error)
+	} else {
+		gap := "-"
+		if r.GTIDMissing >= 0 {
+			gap = fmt.Sprintf("缺少%d/多余%d", r.GTIDMissing, r.GTIDErrant)
+		}
+		fmt.Fprintf(&b, ": 主库 %s, 复制源 %s, IO %s, SQL %s, 延迟 %s秒, GTID %s",
+			orDash(r.Master), orDash(r.Source), r.IORunning, r.SQLRunning, count(r.LagSeconds), gap)
+		if r.LastError != "" {
+			fmt.Fprintf(&b, ", 最近错误: %s", r.LastError)
+		}
+	}
+	if len(r.Problems) > 0 {
+		fmt.Fprintf(&b, ", 异常: %s", strings.Join(r.Problems, ","))
+	}
+	fmt.Fprintf(&b, ", 检查时间 %s", r.CheckedAt)
+	return b.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
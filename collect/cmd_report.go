@@ -0,0 +1,82 @@
+package main
+
+import (
+	"GoldenDB/history"
+	"GoldenDB/report"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runReport 根据告警历史生成统计与噪声报告
+// 用法: report [-from 时间] [-to 时间] [-cluster 名称] [-format md|html|csv] [-o 文件] [-short 5m] [-flap 30m] [-min 5] [-top 3]
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	from := fs.String("from", "", "开始时间，格式 2006-01-02 或 \"2006-01-02 15:04:05\"，默认7天前")
+	to := fs.String("to", "", "结束时间（含），格式同 -from，只给日期时到当天结束，默认当前时间")
+	cluster := fs.String("cluster", "", "只统计指定insight或GoldenDB集群")
+	format := fs.String("format", "md", "输出格式: md|html|csv")
+	output := fs.String("o", "", "输出文件，默认输出到屏幕")
+	short := fs.Duration("short", 5*time.Minute, "在此时间内自动恢复视为短时告警")
+	flap := fs.Duration("flap", 30*time.Minute, "恢复后在此时间内再次触发视为抖动")
+	minTriggers := fs.Int("min", 5, "给出过滤/屏蔽建议所需的最少触发次数")
+	top := fs.Int("top", 3, "每组展示的噪声主机数量")
+	fs.Parse(args)
+
+	opts := report.Options{ShortClear: *short, FlapWindow: *flap, MinTriggers: *minTriggers, TopHosts: *top}
+	var err error
+	if opts.From, err = parseTime(*from, time.Now().AddDate(0, 0, -7)); err != nil {
+		fmt.Println("开始时间格式错误:", err)
+		os.Exit(2)
+	}
+	if opts.To, err = parseEndTime(*to, time.Now()); err != nil {
+		fmt.Println("结束时间格式错误:", err)
+		os.Exit(2)
+	}
+
+	store, err := openHistoryStore()
+	if err != nil {
+		fmt.Println("打开告警历史失败:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	events, err := store.Query(history.Query{From: opts.From, To: opts.To, Cluster: *cluster})
+	if err != nil {
+		fmt.Println("查询告警历史失败:", err)
+		os.Exit(1)
+	}
+	r := report.Build(events, opts)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Println("创建输出文件失败:", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *format {
+	case "md", "markdown":
+		err = r.WriteMarkdown(w)
+	case "html":
+		err = r.WriteHTML(w)
+	case "csv":
+		err = r.WriteCSV(w)
+	default:
+		fmt.Println("不支持的输出格式:", *format)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println("生成报告失败:", err)
+		os.Exit(1)
+	}
+	if *output != "" {
+		fmt.Println("报告已写入:", *output)
+	}
+}
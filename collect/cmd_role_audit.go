@@ -0,0 +1,127 @@
+package main
+
+import (
+	"GoldenDB/connect"
+	"GoldenDB/monitor"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// runRoleAudit 立即检查一次MDS中登记的DN角色与DN实际的只读设置和复制配置是否一致并输出报告。
+// 有异常时返回1
+// 用法: role-audit [-format text|json] [-all]
+func runRoleAudit(args []string) int {
+	fs := flag.NewFlagSet("role-audit", flag.ExitOnError)
+	format := fs.String("format", "text", "输出格式: text|json")
+	all := fs.Bool("all", false, "text格式时同时列出没有异常的DN组")
+	fs.Parse(args)
+	if *format != "text" && *format != "json" {
+		fmt.Println("不支持的输出格式:", *format)
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return 1
+	}
+	if err := initKey(cfg); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	mdsList, err := connect.GetMDS(cfg)
+	if err != nil {
+		fmt.Printf("读取MDS配置失败: %v\n", err)
+		return 1
+	}
+	defer connect.CloseAll()
+
+	groups := monitor.NewRoleAudit(cfg.RoleAudit, mdsList, cfg.DNConn()).Collect(context.Background())
+	failed := 0
+	for _, g := range groups {
+		if roleProblems(g) > 0 {
+			failed++
+		}
+	}
+
+	if *format == "json" {
+		if groups == nil {
+			groups = []monitor.RoleGroup{}
+		}
+		b, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			fmt.Println("生成JSON失败:", err)
+			return 1
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, g := range groups {
+			if *all || roleProblems(g) > 0 {
+				fmt.Println(strings.Join(roleLines(g, true), "\n  "))
+			}
+		}
+		fmt.Printf("共检查 %d 个DN组，%d 个异常\n", len(groups), failed)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// roleProblems DN组及组内DN的异常数
+func roleProblems(g monitor.RoleGroup) int {
+	n := len(g.Problems)
+	for _, dn := range g.DNs {
+		n += len(dn.Problems)
+	}
+	return n
+}
+
+// roleLines DN组检查结果的描述，第一行为DN组，其后每个DN一行；all为false时只列出有异常的DN
+func roleLines(g monitor.RoleGroup, all bool) []string {
+	head := fmt.Sprintf("部署 %s", g.Deployment)
+	if g.Cluster != "" {
+		head += " 集群 " + g.Cluster
+	}
+	if g.Error != "" {
+		return []string{fmt.Sprintf("%s: 检查失败, 错误: %s, 检查时间 %s", head, g.Error, g.CheckedAt)}
+	}
+	head += fmt.Sprintf(" DN组 %s: MDS登记主库 %d 个, 可写DN %d 个", g.Group, g.Masters, g.Writable)
+	if len(g.Problems) > 0 {
+		head += ", 异常: " + strings.Join(g.Problems, ",")
+	}
+	lines := []string{head + ", 检查时间 " + g.CheckedAt}
+	for _, dn := range g.DNs {
+		if !all && len(dn.Problems) == 0 {
+			continue
+		}
+		line := fmt.Sprintf("DN %s (%s)", dn.Addr, dn.Role)
+		if dn.Error != "" {
+			lines = append(lines, line+": 检查失败, 错误: "+dn.Error)
+			continue
+		}
+		line += fmt.Sprintf(": read_only %s, super_read_only %s, 复制源 %s", onOff(dn.ReadOnly), onOff(dn.SuperReadOnly), orDash(strings.Join(dn.Sources, ",")))
+		if len(dn.Sources) > 0 {
+			line += fmt.Sprintf(" (IO %s, SQL %s)", dn.IORunning, dn.SQLRunning)
+		}
+		if len(dn.Notes) > 0 {
+			line += ", 异常: " + strings.Join(dn.Notes, "; ")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func onOff(v *bool) string {
+	switch {
+	case v == nil:
+		return "-"
+	case *v:
+		return "ON"
+	default:
+		return "OFF"
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"GoldenDB/config"
+	"GoldenDB/service"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// status 命令的退出码，与LSB init脚本约定一致
+const (
+	statusRunning    = 0
+	statusNotRunning = 3
+	statusUnknown    = 4
+)
+
+// runService 服务管理命令: start | stop | restart | status | unit，返回进程退出码
+func runService(cmd string, args []string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return 1
+	}
+	switch cmd {
+	case "start":
+		return serviceStart(cfg)
+	case "stop":
+		return serviceStop(cfg)
+	case "restart":
+		if code := serviceStop(cfg); code != 0 {
+			return code
+		}
+		return serviceStart(cfg)
+	case "status":
+		return serviceStatus(cfg, args)
+	case "unit":
+		return serviceUnit(cfg, args)
+	}
+	return 1
+}
+
+// serviceStart 以后台方式运行 foreground，并等待实例在控制套接字上响应
+func serviceStart(cfg *config.Config) int {
+	pid, err := service.RunningPID(cfg.Service.PIDFile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if pid != 0 {
+		fmt.Printf("服务已经在运行 (PID: %d)\n", pid)
+		return 0
+	}
+
+	pid, err = service.Spawn([]string{"--config", cfg.Path, "foreground"}, cfg.Service.ConsoleLog)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		if _, err := service.QueryStatus(cfg.Service.Socket, time.Second); err == nil {
+			fmt.Printf("服务启动成功 (PID: %d)\n", pid)
+			return 0
+		}
+		if running, _ := service.RunningPID(cfg.Service.PIDFile); running == 0 {
+			break
+		}
+	}
+	fmt.Printf("服务未能启动，请查看 %s 和日志文件\n", cfg.Service.ConsoleLog)
+	return 1
+}
+
+// serviceStop 发送SIGTERM并等待实例推送完队列、保存状态后退出
+func serviceStop(cfg *config.Config) int {
+	pid, err := service.RunningPID(cfg.Service.PIDFile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if pid == 0 {
+		fmt.Println("服务未运行")
+		return 0
+	}
+	fmt.Printf("正在停止服务 (PID: %d)\n", pid)
+	if err := service.Terminate(cfg.Service.PIDFile, pid, time.Duration(cfg.Service.StopTimeout)*time.Second); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Println("服务已停止")
+	return 0
+}
+
+// serviceStatus 通过控制套接字查询运行中实例的采集和推送队列状态
+func serviceStatus(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "以JSON格式输出")
+	fs.Parse(args)
+
+	pid, err := service.RunningPID(cfg.Service.PIDFile)
+	if err != nil {
+		fmt.Println(err)
+		return statusUnknown
+	}
+	if pid == 0 {
+		fmt.Println("服务未运行")
+		return statusNotRunning
+	}
+	data, err := service.QueryStatus(cfg.Service.Socket, 5*time.Second)
+	if err != nil {
+		fmt.Printf("服务正在运行 (PID: %d)，但未响应状态查询: %v\n", pid, err)
+		return statusUnknown
+	}
+	if *asJSON {
+		os.Stdout.Write(data)
+		return statusRunning
+	}
+
+	var r statusReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		fmt.Printf("解析状态失败: %v\n", err)
+		return statusUnknown
+	}
+	health := "正常"
+	if !r.Healthy {
+		health = "异常（存在超时未完成采集的分组）"
+	}
+	fmt.Printf("服务正在运行 (PID: %d)\n", r.PID)
+	fmt.Printf("启动时间: %s, 已运行: %s\n", r.StartedAt, r.Uptime)
+	fmt.Printf("角色: %s, 健康状态: %s\n", r.Role, health)
+	if r.Queue != nil {
+		q := r.Queue
+		fmt.Printf("推送队列: %d/%d, 推送协程: %d, 成功: %d, 失败: %d, 丢弃: %d\n", q.QueueLen, q.QueueSize, q.Workers, q.Delivered, q.Failed, q.Dropped)
+	} else {
+		fmt.Println("推送队列: 未运行")
+	}
+	for _, g := range r.Groups {
+		lastRun := g.LastRun
+		if lastRun == "" {
+			lastRun = "-"
+		}
+		fmt.Printf("分组 %s: 模式 %s, 成员 %d, 当前采集 %s, 最近采集 %s, 告警数 %d", g.Name, g.Mode, g.Members, g.Active, lastRun, g.Alarms)
+		if g.LastError != "" {
+			fmt.Printf(", 错误: %s", g.LastError)
+		}
+		fmt.Println()
+	}
+	for _, c := range r.CN {
+		if !c.Up {
+			fmt.Printf("CN %s (%s): 无法登录或探测查询失败, 错误: %s, 检查时间 %s\n", c.Name, c.Addr, c.Error, c.CheckedAt)
+			continue
+		}
+		fmt.Printf("CN %s (%s): 延迟 %.1fms, Threads_running %s, 连接 %s/%s, 积压 %s, 检查时间 %s",
+			c.Name, c.Addr, c.LatencyMs, count(c.ThreadsRunning), count(c.ThreadsConnected), count(c.MaxConnections), count(c.Backlog), c.CheckedAt)
+		if len(c.Problems) > 0 {
+			fmt.Printf(", 异常: %s", strings.Join(c.Problems, ","))
+		}
+		fmt.Println()
+	}
+	for _, r := range r.Replication {
+		fmt.Println("复制 " + replicaLine(r))
+	}
+	for _, g := range r.Roles {
+		// 只列出有异常的DN组和DN，避免DN组较多时输出过长
+		if roleProblems(g) > 0 {
+			fmt.Println("角色 " + strings.Join(roleLines(g, false), "\n  "))
+		}
+	}
+	return statusRunning
+}
+
+// count 输出计数，无法获取（-1）时显示为 -
+func count(n int) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
+
+// serviceUnit 生成systemd unit文件
+func serviceUnit(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("unit", flag.ExitOnError)
+	user := fs.String("user", "", "运行用户，默认root")
+	watchdog := fs.Int("watchdog", 60, "看门狗超时（秒），0表示不启用")
+	output := fs.String("o", "", "输出文件，默认输出到屏幕")
+	fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Printf("获取程序路径失败: %v\n", err)
+		return 1
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("获取工作目录失败: %v\n", err)
+		return 1
+	}
+	configFile, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		fmt.Printf("获取配置文件路径失败: %v\n", err)
+		return 1
+	}
+	unit := service.UnitFile(service.UnitOptions{
+		Exec:        exe,
+		Config:      configFile,
+		WorkDir:     dir,
+		User:        *user,
+		WatchdogSec: *watchdog,
+		StopTimeout: cfg.Service.StopTimeout,
+	})
+	if *output == "" {
+		fmt.Print(unit)
+		return 0
+	}
+	if err := os.WriteFile(*output, []byte(unit), 0644); err != nil {
+		fmt.Printf("写入unit文件失败: %v\n", err)
+		return 1
+	}
+	fmt.Printf("已生成 %s\n", *output)
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"GoldenDB/connect"
+	"GoldenDB/topology"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// runTopology 从各MDS发现集群、DN组、DN和Schema并导出，
+// 有部署无法查询或（指定 -check 时）有节点不可达时返回1
+// 用法: topology [-format json|yaml|table|dot] [-o 文件] [-check] [-timeout 秒]
+func runTopology(args []string) int {
+	fs := flag.NewFlagSet("topology", flag.ExitOnError)
+	format := fs.String("format", "table", "输出格式: json|yaml|table|dot")
+	output := fs.String("o", "", "输出文件，默认输出到屏幕")
+	check := fs.Bool("check", false, "检查各MDS和DN的端口能否连通")
+	timeout := fs.Int("timeout", 3, "单个节点的连通性检查超时（秒）")
+	fs.Parse(args)
+
+	var write func(topology.Topology, io.Writer) error
+	switch *format {
+	case "json":
+		write = topology.Topology.WriteJSON
+	case "yaml", "yml":
+		write = topology.Topology.WriteYAML
+	case "table":
+		write = topology.Topology.WriteTable
+	case "dot":
+		write = topology.Topology.WriteDOT
+	default:
+		fmt.Println("不支持的输出格式:", *format)
+		return 2
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return 1
+	}
+	if err := initKey(cfg); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	mdsList, err := connect.GetMDS(cfg)
+	if err != nil {
+		fmt.Printf("读取MDS配置失败: %v\n", err)
+		return 1
+	}
+	defer connect.CloseAll()
+
+	t := topology.Discover(context.Background(), mdsList, topology.Options{
+		Check:   *check,
+		Timeout: time.Duration(*timeout) * time.Second,
+	})
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Println("创建输出文件失败:", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := write(t, w); err != nil {
+		fmt.Println("输出拓扑失败:", err)
+		return 1
+	}
+	if *output != "" {
+		fmt.Println("拓扑已写入:", *output)
+	}
+
+	failed := 0
+	for _, d := range t.Deployments {
+		if d.Error != "" {
+			failed++
+			fmt.Fprintf(os.Stderr, "部署 %s 拓扑获取失败: %s\n", d.Name, d.Error)
+		}
+	}
+	if n := t.Unreachable(); n > 0 {
+		failed += n
+		fmt.Fprintf(os.Stderr, "%d 个节点不可达\n", n)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
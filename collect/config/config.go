@@ -1,57 +1,304 @@
 package config
 
 import (
-	"fmt"
 	"gopkg.in/yaml.v3"
-	"os"
 )
 
+// Config 统一配置。version为0（未填写）时是旧版布局，MDS、CN和过滤规则
+// 分别从配置文件同目录的 mds.json、cn.json、alarm_filter.json 读取；
+// version 1 可以在同一文件中配置 mds、cn、filter，未配置的部分仍从旧文件读取
 type Config struct {
-	Alarm struct {
-		ApiAddress string `yaml:"api_address"`
-		Time       int    `yaml:"time"`
+	Version int `yaml:"version"`
+	Alarm   struct {
+		ApiAddress string      `yaml:"api_address"`
+		Time       int         `yaml:"time"`
+		HTTP       Destination `yaml:"http"` // 推送地址的认证、签名、TLS等设置
+		Batch      struct {
+			Enabled bool `yaml:"enabled"`
+			MaxSize int  `yaml:"max_size"` // 每批最多推送的事件数
+		} `yaml:"batch"`
+		GroupMode string `yaml:"group_mode"` // 同组MDS的采集方式: standby | merge
+		Dispatch  struct {
+			Workers      int `yaml:"workers"`       // 推送协程数量
+			QueueSize    int `yaml:"queue_size"`    // 推送队列容量
+			QueryTimeout int `yaml:"query_timeout"` // 单次告警查询超时（秒）
+			DrainTimeout int `yaml:"drain_timeout"` // 退出时等待推送队列处理完成的最长时间（秒）
+		} `yaml:"dispatch"`
 	} `yaml:"alarm"`
 	Log struct {
 		Path          string `yaml:"path"`
-		Level         string `yaml:"level"`
-		KeepDays      int    `yaml:"keep_days"`
+		Level         string `yaml:"level"`      // DEBUG | INFO | WARN | ERROR
+		Format        string `yaml:"format"`     // text | json
+		Stdout        bool   `yaml:"stdout"`     // 同时输出到标准输出
+		KeepDays      int    `yaml:"keep_days"`  // 切分文件保留天数
+		KeepFiles     int    `yaml:"keep_files"` // 切分文件保留数量，0表示不限制
+		MaxSize       int    `yaml:"max_size"`   // 单个日志文件最大MB数，负数表示不按大小切分
+		Daily         *bool  `yaml:"daily"`      // 每天切分一次，默认true
+		Compress      *bool  `yaml:"compress"`   // gzip压缩切分文件，默认true
 		CleanInterval int    `yaml:"clean_interval"`
 	} `yaml:"log"`
+	History struct {
+		Enabled  bool   `yaml:"enabled"`
+		Path     string `yaml:"path"`
+		KeepDays int    `yaml:"keep_days"`
+	} `yaml:"history"`
+	Digest Digest `yaml:"digest"`
+	HA     struct {
+		Enabled       bool   `yaml:"enabled"`
+		Mode          string `yaml:"mode"`           // 选主方式: mysql | file
+		LockName      string `yaml:"lock_name"`      // mysql模式的 GET_LOCK 锁名
+		MDS           string `yaml:"mds"`            // mysql模式加锁的MDS名称，为空时在全部MDS的多数上加锁
+		LockFile      string `yaml:"lock_file"`      // file模式的锁文件，需放在共享存储上
+		CheckInterval int    `yaml:"check_interval"` // 抢锁和检查锁的间隔（秒）
+	} `yaml:"ha"`
+	State struct {
+		Path     string `yaml:"path"`     // 已推送告警状态文件，开启ha时需在共享存储上
+		Interval int    `yaml:"interval"` // 保存间隔（秒）
+	} `yaml:"state"`
+	Security    Security    `yaml:"security"`
+	CNMonitor   CNMonitor   `yaml:"cn_monitor"`
+	Replication Replication `yaml:"replication"`
+	RoleAudit   RoleAudit   `yaml:"role_audit"`
+	Database    struct {
+		DBConn `yaml:",inline"` // MDS、CN、DN共用的默认值
+		DN     DBConn           `yaml:"dn"` // 从MDS查到的DN使用的设置，覆盖默认值
+	} `yaml:"database"`
+	MDS    []MDS     `yaml:"mds"`
+	CN     []CN      `yaml:"cn"`
+	Filter yaml.Node `yaml:"filter"` // 告警过滤规则，格式同 alarm_filter.json
+
+	// 以下字段由Load填写
+	Path       string   `yaml:"-"` // 配置文件路径
+	FilterJSON []byte   `yaml:"-"` // 过滤规则（JSON），未配置时为nil
+	Sources    []string `yaml:"-"` // 实际读取的文件
+	mdsFile    string   // MDS从旧版 mds.json 读取时的文件路径
+	cnFile     string   // CN从旧版 cn.json 读取时的文件路径
+
+	Service struct {
+		PIDFile     string `yaml:"pid_file"`     // 加锁的PID文件
+		Socket      string `yaml:"socket"`       // 本地控制套接字，status 命令通过它查询运行状态
+		ConsoleLog  string `yaml:"console_log"`  // start 后台运行时标准输出和标准错误的写入文件
+		StopTimeout int    `yaml:"stop_timeout"` // stop 等待服务退出的最长时间（秒）
+	} `yaml:"service"`
 }
 
-// ReadAlarmConfig 函数读取YAML文件，返回api_address和time值
-func ReadAlarmConfig(filename string) (string, int, error) {
-	// 读取文件内容
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return "", 0, fmt.Errorf("读取文件失败: %w", err)
-	}
+// Security 加密密码等密文所用密钥的来源
+type Security struct {
+	KeySource string `yaml:"key_source"` // builtin | file | env | passphrase，为空时由环境变量 GDB_KEY_FILE、GDB_KEY、GDB_KEY_SALT 决定
+	KeyFile   string `yaml:"key_file"`   // file方式的密钥文件，权限需为0600
+	Salt      string `yaml:"salt"`       // passphrase方式的scrypt盐值（base64），由 rotate-key 生成
+}
 
-	// 解析YAML
-	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return "", 0, fmt.Errorf("解析YAML失败: %w", err)
-	}
+// CNMonitor CN健康探测配置。探测结果异常时产生程序自己的告警，与MDS中的告警走同一推送流程
+type CNMonitor struct {
+	Enabled           bool   `yaml:"enabled"`
+	Insight           string `yaml:"insight"`             // 告警的insight（平台名称），默认 CN
+	Interval          int    `yaml:"interval"`            // 探测间隔（秒）
+	Timeout           int    `yaml:"timeout"`             // 单个CN一轮探测的超时（秒）
+	Query             string `yaml:"query"`               // 测量往返延迟的探测查询
+	SlowMs            int    `yaml:"slow_ms"`             // 探测查询超过该毫秒数视为慢
+	MaxThreadsRunning int    `yaml:"max_threads_running"` // Threads_running 超过该值视为饱和，0表示不检查
+	MaxConnUsage      int    `yaml:"max_conn_usage"`      // Threads_connected 占 max_connections 的百分比超过该值视为饱和，0表示不检查
+	BacklogTime       int    `yaml:"backlog_time"`        // processlist中执行超过该秒数的会话计入积压
+	MaxBacklog        int    `yaml:"max_backlog"`         // 积压会话数超过该值时告警，0表示不检查
+	Trigger           int    `yaml:"trigger"`             // 连续多少轮异常才告警，恢复时立即消除
+	Codes             struct {
+		Down      int `yaml:"down"`      // 无法登录
+		Slow      int `yaml:"slow"`      // 探测查询慢
+		Saturated int `yaml:"saturated"` // 线程或连接数饱和
+		Backlog   int `yaml:"backlog"`   // processlist积压
+	} `yaml:"codes"` // 告警代码，需与GoldenDB自身的告警代码（如20515）区分
+}
 
-	// 返回提取的值
-	return config.Alarm.ApiAddress, config.Alarm.Time, nil
+// Replication DN主备复制健康检查配置。从MDS的 mds.db_info 获取各DN组，
+// 使用MDS的用户和密码登录DN，异常时产生程序自己的告警
+type Replication struct {
+	Enabled     bool   `yaml:"enabled"`
+	Insight     string `yaml:"insight"`      // 告警的insight（平台名称），默认 replication
+	Interval    int    `yaml:"interval"`     // 检查间隔（秒）
+	Timeout     int    `yaml:"timeout"`      // 一轮检查的超时（秒）
+	Concurrency int    `yaml:"concurrency"`  // 同时检查的DN组数
+	MaxLag      int    `yaml:"max_lag"`      // 复制延迟超过该秒数时告警
+	MaxGTIDGap  int64  `yaml:"max_gtid_gap"` // 备库缺少的主库事务数超过该值时告警
+	Trigger     int    `yaml:"trigger"`      // 连续多少轮异常才告警，恢复时立即消除
+	Codes       struct {
+		IOStopped   int `yaml:"io_stopped"`   // IO线程未运行
+		SQLStopped  int `yaml:"sql_stopped"`  // SQL线程未运行
+		Lag         int `yaml:"lag"`          // 复制延迟
+		GTIDGap     int `yaml:"gtid_gap"`     // 缺少主库事务或有主库没有的事务
+		CheckFailed int `yaml:"check_failed"` // 无法登录或未配置复制
+	} `yaml:"codes"` // 告警代码，需与GoldenDB自身的告警代码和CN探测的代码区分
 }
 
-// ReadFullConfig 读取完整配置
-func ReadFullConfig(filename string) (*Config, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("读取文件失败: %w", err)
+// RoleAudit DN角色一致性检查配置。比较MDS中登记的角色与DN实际的只读设置和复制配置，
+// 登录DN的方式与复制检查相同
+type RoleAudit struct {
+	Enabled     bool   `yaml:"enabled"`
+	Insight     string `yaml:"insight"`     // 告警的insight（平台名称），默认 role_audit
+	Interval    int    `yaml:"interval"`    // 检查间隔（秒）
+	Timeout     int    `yaml:"timeout"`     // 一轮检查的超时（秒）
+	Concurrency int    `yaml:"concurrency"` // 同时检查的DN组数
+	Trigger     int    `yaml:"trigger"`     // 连续多少轮异常才告警，恢复时立即消除
+	Codes       struct {
+		NoMaster       int `yaml:"no_master"`       // MDS中DN组没有主库
+		MultiMaster    int `yaml:"multi_master"`    // MDS中DN组有多个主库
+		SplitBrain     int `yaml:"split_brain"`     // 组内多个DN可写
+		MasterReadOnly int `yaml:"master_readonly"` // 主库只读
+		SlaveWritable  int `yaml:"slave_writable"`  // 备库可写
+		Replication    int `yaml:"replication"`     // 复制配置与角色不符
+		CheckFailed    int `yaml:"check_failed"`    // 无法登录DN或无法获取DN信息
+		ReplicaStatus  int `yaml:"replica_status"`  // 已读取只读开关，但无法读取复制状态
+	} `yaml:"codes"` // 告警代码，需与GoldenDB自身的告警代码和其它检查的代码区分
+}
+
+// DBConn 数据库连接设置。未填写（0或空）的项沿用上一级：节点的conn段 -> database段 -> 默认值
+type DBConn struct {
+	ConnectTimeout int   `yaml:"connect_timeout" json:"connect_timeout,omitempty"` // 建立连接超时（秒）
+	ReadTimeout    int   `yaml:"read_timeout" json:"read_timeout,omitempty"`       // 读超时（秒）
+	WriteTimeout   int   `yaml:"write_timeout" json:"write_timeout,omitempty"`     // 写超时（秒）
+	MaxOpen        int   `yaml:"max_open" json:"max_open,omitempty"`               // 每个节点的最大连接数
+	MaxIdle        int   `yaml:"max_idle" json:"max_idle,omitempty"`               // 每个节点的最大空闲连接数
+	MaxLifetime    int   `yaml:"max_lifetime" json:"max_lifetime,omitempty"`       // 连接最长使用时间（秒），超过后重建
+	TLS            DBTLS `yaml:"tls" json:"tls,omitempty"`
+}
+
+// DBTLS 数据库连接的TLS设置
+type DBTLS struct {
+	// disable | preferred（服务端支持时加密，不校验证书）| required（加密，不校验证书）
+	// | verify-ca（校验证书链）| verify-full（校验证书链和主机名）
+	Mode       string `yaml:"mode" json:"mode,omitempty"`
+	CA         string `yaml:"ca" json:"ca,omitempty"`                   // CA证书文件，verify-ca/verify-full 未配置时使用系统CA
+	Cert       string `yaml:"cert" json:"cert,omitempty"`               // 客户端证书
+	Key        string `yaml:"key" json:"key,omitempty"`                 // 客户端私钥
+	ServerName string `yaml:"server_name" json:"server_name,omitempty"` // 校验主机名时使用，为空时使用节点地址
+}
+
+// Merge 返回用o中已填写的项覆盖c后的设置
+func (c DBConn) Merge(o DBConn) DBConn {
+	set := func(dst *int, v int) {
+		if v != 0 {
+			*dst = v
+		}
+	}
+	set(&c.ConnectTimeout, o.ConnectTimeout)
+	set(&c.ReadTimeout, o.ReadTimeout)
+	set(&c.WriteTimeout, o.WriteTimeout)
+	set(&c.MaxOpen, o.MaxOpen)
+	set(&c.MaxIdle, o.MaxIdle)
+	set(&c.MaxLifetime, o.MaxLifetime)
+	for _, f := range []struct {
+		dst *string
+		v   string
+	}{
+		{&c.TLS.Mode, o.TLS.Mode},
+		{&c.TLS.CA, o.TLS.CA},
+		{&c.TLS.Cert, o.TLS.Cert},
+		{&c.TLS.Key, o.TLS.Key},
+		{&c.TLS.ServerName, o.TLS.ServerName},
+	} {
+		if f.v != "" {
+			*f.dst = f.v
+		}
 	}
+	return c
+}
+
+// MDS 一个MDS节点，password为 -p 加密后的密文
+type MDS struct {
+	Name     string  `yaml:"name" json:"name"`
+	Host     string  `yaml:"host" json:"host"`
+	Port     int     `yaml:"port" json:"port"`
+	Username string  `yaml:"username" json:"username"`
+	Password string  `yaml:"password" json:"password"`
+	Group    string  `yaml:"group" json:"group"`         // 同一GoldenDB部署的主备MDS配置相同的group，为空时自成一组
+	Conn     *DBConn `yaml:"conn" json:"conn,omitempty"` // 覆盖database段的连接设置
+}
+
+// CN 一个CN节点，password为 -p 加密后的密文
+type CN struct {
+	Name     string  `yaml:"name" json:"name"`
+	Host     string  `yaml:"host" json:"host"`
+	Port     int     `yaml:"port" json:"port"`
+	Schema   string  `yaml:"schema" json:"schema"`
+	Username string  `yaml:"username" json:"username"`
+	Password string  `yaml:"password" json:"password"`
+	Conn     *DBConn `yaml:"conn" json:"conn,omitempty"` // 覆盖database段的连接设置
+}
+
+// MDSConn MDS节点实际使用的连接设置
+func (c *Config) MDSConn(m MDS) DBConn {
+	return c.nodeConn(m.Conn)
+}
+
+// CNConn CN节点实际使用的连接设置
+func (c *Config) CNConn(cn CN) DBConn {
+	return c.nodeConn(cn.Conn)
+}
 
-	var config Config
-	err = yaml.Unmarshal(data, &config)
-	if err != nil {
-		return nil, fmt.Errorf("解析YAML失败: %w", err)
+// DNConn 从MDS查到的DN实际使用的连接设置
+func (c *Config) DNConn() DBConn {
+	return c.Database.DBConn.Merge(c.Database.DN)
+}
+
+func (c *Config) nodeConn(o *DBConn) DBConn {
+	if o == nil {
+		return c.Database.DBConn
 	}
+	return c.Database.DBConn.Merge(*o)
+}
+
+// Destination HTTP推送目的地设置，token、password、secret均为 -p 加密后的密文
+type Destination struct {
+	URL     string `yaml:"url"`     // 为空时使用所属配置段的地址
+	Timeout int    `yaml:"timeout"` // 请求超时（秒），默认10
+	Proxy   string `yaml:"proxy"`   // HTTP代理地址，如 http://proxy:3128
+	Auth    struct {
+		Type     string `yaml:"type"` // bearer | basic，为空不认证
+		Token    string `yaml:"token"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"auth"`
+	Sign struct {
+		Secret          string `yaml:"secret"`           // 为空不签名
+		Header          string `yaml:"header"`           // 签名头，默认 X-Signature
+		TimestampHeader string `yaml:"timestamp_header"` // 时间戳头，默认 X-Timestamp
+	} `yaml:"sign"`
+	TLS struct {
+		CA                 string `yaml:"ca"`   // 自定义CA证书文件
+		Cert               string `yaml:"cert"` // mTLS客户端证书
+		Key                string `yaml:"key"`  // mTLS客户端私钥
+		ServerName         string `yaml:"server_name"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	} `yaml:"tls"`
+}
+
+// Digest 定时告警摘要配置
+type Digest struct {
+	Enabled bool     `yaml:"enabled"`
+	Times   []string `yaml:"times"` // 每天发送时间，格式 HH:MM
+	Top     int      `yaml:"top"`   // 每个insight展示的持续时间最长的告警数量
+	// 发送目的地: webhook | email | file，默认 file
+	Type    string      `yaml:"type"`
+	Webhook string      `yaml:"webhook"`
+	HTTP    Destination `yaml:"http"` // webhook的认证、签名、TLS等设置
+	Dir     string      `yaml:"dir"`
+	Email   struct {
+		Host     string   `yaml:"host"`
+		Port     int      `yaml:"port"`
+		Username string   `yaml:"username"`
+		Password string   `yaml:"password"` // 加密后的密码
+		From     string   `yaml:"from"`
+		To       []string `yaml:"to"`
+	} `yaml:"email"`
+}
+
+// ReadFullConfig 读取完整配置，等同于Load
+func ReadFullConfig(filename string) (*Config, error) {
+	return Load(filename)
+}
 
-	// 设置默认值
+// applyDefaults 设置默认值
+func applyDefaults(config *Config) {
 	if config.Log.Path == "" {
 		config.Log.Path = "log/app.log"
 	}
@@ -61,9 +308,204 @@ func ReadFullConfig(filename string) (*Config, error) {
 	if config.Log.KeepDays == 0 {
 		config.Log.KeepDays = 7
 	}
+	if config.Log.MaxSize == 0 {
+		config.Log.MaxSize = 100
+	}
 	if config.Log.CleanInterval == 0 {
 		config.Log.CleanInterval = 86400
 	}
+	// 与示例配置一致，未填写时按天切分并压缩；显式填写false时关闭
+	if config.Log.Daily == nil {
+		config.Log.Daily = boolPtr(true)
+	}
+	if config.Log.Compress == nil {
+		config.Log.Compress = boolPtr(true)
+	}
+	if config.Alarm.Batch.MaxSize == 0 {
+		config.Alarm.Batch.MaxSize = 100
+	}
+	if config.Alarm.GroupMode == "" {
+		config.Alarm.GroupMode = "standby"
+	}
+	if config.Alarm.Dispatch.Workers == 0 {
+		config.Alarm.Dispatch.Workers = 4
+	}
+	if config.Alarm.Dispatch.QueueSize == 0 {
+		config.Alarm.Dispatch.QueueSize = 1000
+	}
+	if config.Alarm.Dispatch.QueryTimeout == 0 {
+		config.Alarm.Dispatch.QueryTimeout = 30
+	}
+	if config.Alarm.Dispatch.DrainTimeout == 0 {
+		config.Alarm.Dispatch.DrainTimeout = 20
+	}
+	if config.History.Path == "" {
+		config.History.Path = "data/history"
+	}
+	if config.History.KeepDays == 0 {
+		config.History.KeepDays = 30
+	}
+	if len(config.Digest.Times) == 0 {
+		config.Digest.Times = []string{"09:00"}
+	}
+	if config.Digest.Top == 0 {
+		config.Digest.Top = 10
+	}
+	if config.Digest.Type == "" {
+		config.Digest.Type = "file"
+	}
+	if config.Digest.Dir == "" {
+		config.Digest.Dir = "data/digest"
+	}
+	if config.HA.Mode == "" {
+		config.HA.Mode = "mysql"
+	}
+	if config.HA.LockName == "" {
+		config.HA.LockName = "gdb_alarm_leader"
+	}
+	if config.HA.LockFile == "" {
+		config.HA.LockFile = "data/leader.lock"
+	}
+	if config.HA.CheckInterval == 0 {
+		config.HA.CheckInterval = 5
+	}
+	if config.State.Path == "" {
+		config.State.Path = "data/state.json"
+	}
+	if config.State.Interval == 0 {
+		config.State.Interval = 60
+	}
+	if config.Service.PIDFile == "" {
+		config.Service.PIDFile = "data/gdbalarm.pid"
+	}
+	if config.Service.Socket == "" {
+		config.Service.Socket = "data/gdbalarm.sock"
+	}
+	if config.Service.ConsoleLog == "" {
+		config.Service.ConsoleLog = "log/console.log"
+	}
+	if config.Service.StopTimeout == 0 {
+		config.Service.StopTimeout = 60
+	}
+	if config.Security.KeyFile == "" {
+		config.Security.KeyFile = "config/master.key"
+	}
+	cm := &config.CNMonitor
+	if cm.Insight == "" {
+		cm.Insight = "CN"
+	}
+	if cm.Interval == 0 {
+		cm.Interval = 30
+	}
+	if cm.Timeout == 0 {
+		cm.Timeout = 10
+	}
+	if cm.Query == "" {
+		cm.Query = "SELECT 1"
+	}
+	if cm.SlowMs == 0 {
+		cm.SlowMs = 1000
+	}
+	if cm.MaxConnUsage == 0 {
+		cm.MaxConnUsage = 90
+	}
+	if cm.BacklogTime == 0 {
+		cm.BacklogTime = 5
+	}
+	if cm.MaxBacklog == 0 {
+		cm.MaxBacklog = 50
+	}
+	if cm.Trigger == 0 {
+		cm.Trigger = 2
+	}
+	for _, c := range []struct {
+		code *int
+		def  int
+	}{{&cm.Codes.Down, 90101}, {&cm.Codes.Slow, 90102}, {&cm.Codes.Saturated, 90103}, {&cm.Codes.Backlog, 90104}} {
+		if *c.code == 0 {
+			*c.code = c.def
+		}
+	}
+	rm := &config.Replication
+	if rm.Insight == "" {
+		rm.Insight = "replication"
+	}
+	if rm.Interval == 0 {
+		rm.Interval = 60
+	}
+	if rm.Timeout == 0 {
+		rm.Timeout = 20
+	}
+	if rm.Concurrency == 0 {
+		rm.Concurrency = 8
+	}
+	if rm.MaxLag == 0 {
+		rm.MaxLag = 60
+	}
+	if rm.MaxGTIDGap == 0 {
+		rm.MaxGTIDGap = 1000
+	}
+	if rm.Trigger == 0 {
+		rm.Trigger = 2
+	}
+	for _, c := range []struct {
+		code *int
+		def  int
+	}{{&rm.Codes.IOStopped, 90111}, {&rm.Codes.SQLStopped, 90112}, {&rm.Codes.Lag, 90113}, {&rm.Codes.GTIDGap, 90114}, {&rm.Codes.CheckFailed, 90115}} {
+		if *c.code == 0 {
+			*c.code = c.def
+		}
+	}
+	ra := &config.RoleAudit
+	if ra.Insight == "" {
+		ra.Insight = "role_audit"
+	}
+	if ra.Interval == 0 {
+		ra.Interval = 60
+	}
+	if ra.Timeout == 0 {
+		ra.Timeout = 20
+	}
+	if ra.Concurrency == 0 {
+		ra.Concurrency = 8
+	}
+	if ra.Trigger == 0 {
+		ra.Trigger = 2
+	}
+	for _, c := range []struct {
+		code *int
+		def  int
+	}{{&ra.Codes.NoMaster, 90121}, {&ra.Codes.MultiMaster, 90122}, {&ra.Codes.SplitBrain, 90123}, {&ra.Codes.MasterReadOnly, 90124},
+		{&ra.Codes.SlaveWritable, 90125}, {&ra.Codes.Replication, 90126}, {&ra.Codes.CheckFailed, 90127}, {&ra.Codes.ReplicaStatus, 90128}} {
+		if *c.code == 0 {
+			*c.code = c.def
+		}
+	}
+
+	db := &config.Database.DBConn
+	if db.ConnectTimeout == 0 {
+		db.ConnectTimeout = 5
+	}
+	if db.ReadTimeout == 0 {
+		db.ReadTimeout = 30
+	}
+	if db.WriteTimeout == 0 {
+		db.WriteTimeout = 30
+	}
+	if db.MaxOpen == 0 {
+		db.MaxOpen = 4
+	}
+	if db.MaxIdle == 0 {
+		db.MaxIdle = min(2, db.MaxOpen)
+	}
+	if db.MaxLifetime == 0 {
+		db.MaxLifetime = 300
+	}
+	if db.TLS.Mode == "" {
+		db.TLS.Mode = "disable"
+	}
+}
 
-	return &config, nil
+func boolPtr(b bool) *bool {
+	return &b
 }
@@ -0,0 +1,44 @@
+//go:build linux
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// 本地文件系统的类型，见 statfs(2)
+var localFilesystems = map[int64]string{
+	0xEF53:     "ext2/ext3/ext4",
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x794C7630: "overlayfs",
+	0x4D44:     "vfat",
+	0x5346544E: "ntfs",
+	0xF2F52010: "f2fs",
+}
+
+// localFilesystem path所在（不存在时取最近的已存在上级目录）的文件系统是本地文件系统时返回其类型。
+// 无法判断或不是已知的本地文件系统时返回false
+func localFilesystem(path string) (string, bool) {
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return "", false
+	}
+	name, ok := localFilesystems[int64(st.Type)]
+	return name, ok
+}
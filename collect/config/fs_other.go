@@ -0,0 +1,8 @@
+//go:build !linux
+
+package config
+
+// localFilesystem 当前平台不判断文件系统类型
+func localFilesystem(path string) (string, bool) {
+	return "", false
+}
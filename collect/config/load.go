@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CurrentVersion 当前配置格式版本
+const CurrentVersion = 1
+
+// DefaultPath 默认配置文件
+const DefaultPath = "config/amp_api.yaml"
+
+// EnvConfig 指定配置文件路径的环境变量
+const EnvConfig = "GDB_ALARM_CONFIG"
+
+// EnvPrefix 覆盖单个配置项的环境变量前缀，变量名由各级yaml键名大写后用下划线连接，
+// 如 GDB_ALARM_ALARM_API_ADDRESS 覆盖 alarm.api_address，GDB_ALARM_LOG_LEVEL 覆盖 log.level
+const EnvPrefix = "GDB_ALARM_"
+
+// 旧版布局中与主配置文件同目录的文件
+const (
+	legacyMDSFile    = "mds.json"
+	legacyCNFile     = "cn.json"
+	legacyFilterFile = "alarm_filter.json"
+)
+
+// ResolvePath 确定配置文件路径：命令行指定的path优先，其次是环境变量 GDB_ALARM_CONFIG，最后是默认路径
+func ResolvePath(path string) string {
+	if path != "" {
+		return path
+	}
+	if env := os.Getenv(EnvConfig); env != "" {
+		return env
+	}
+	return DefaultPath
+}
+
+// Load 读取配置文件，补充旧版布局中的 mds.json、cn.json、alarm_filter.json，
+// 再应用环境变量覆盖和默认值。旧文件不存在时对应部分为空，由Validate报告
+func Load(path string) (*Config, error) {
+	return load(path, true)
+}
+
+// LoadFile 与Load相同但不应用环境变量覆盖，供改写配置文件的命令使用，
+// 避免把环境变量中的值写入文件
+func LoadFile(path string) (*Config, error) {
+	return load(path, false)
+}
+
+func load(path string, env bool) (*Config, error) {
+	path = ResolvePath(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析YAML失败: %w", err)
+	}
+	if config.Version > CurrentVersion {
+		return nil, fmt.Errorf("不支持的配置版本 %d，当前程序支持到 %d", config.Version, CurrentVersion)
+	}
+	config.Path = path
+	config.Sources = []string{path}
+
+	dir := filepath.Dir(path)
+	if len(config.MDS) == 0 {
+		name := filepath.Join(dir, legacyMDSFile)
+		if ok, err := config.readLegacy(name, &config.MDS); err != nil {
+			return nil, err
+		} else if ok {
+			config.mdsFile = name
+		}
+	}
+	if len(config.CN) == 0 {
+		name := filepath.Join(dir, legacyCNFile)
+		if ok, err := config.readLegacy(name, &config.CN); err != nil {
+			return nil, err
+		} else if ok {
+			config.cnFile = name
+		}
+	}
+	if config.Filter.Kind != 0 {
+		var v interface{}
+		if err := config.Filter.Decode(&v); err != nil {
+			return nil, fmt.Errorf("解析过滤规则失败: %w", err)
+		}
+		// 统一转换为JSON，与 alarm_filter.json 使用同一解析逻辑
+		if config.FilterJSON, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("解析过滤规则失败: %w", err)
+		}
+	} else {
+		name := filepath.Join(dir, legacyFilterFile)
+		if data, err := os.ReadFile(name); err == nil {
+			config.FilterJSON = data
+			config.Sources = append(config.Sources, name)
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("读取过滤配置失败: %w", err)
+		}
+	}
+
+	if env {
+		if err := applyEnv(reflect.ValueOf(&config).Elem(), EnvPrefix); err != nil {
+			return nil, err
+		}
+	}
+	applyDefaults(&config)
+	return &config, nil
+}
+
+// readLegacy 读取旧版JSON文件，文件不存在时忽略并返回false
+func (c *Config) readLegacy(name string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取 %s 失败: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	c.Sources = append(c.Sources, name)
+	return true, nil
+}
+
+// applyEnv 用环境变量覆盖字符串、整数、布尔和字符串列表（逗号分隔）类型的配置项
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("yaml")
+		key := strings.Split(tag, ",")[0]
+		// 内嵌（inline）的结构体与所在层级共用前缀
+		if f.Anonymous && strings.HasSuffix(tag, ",inline") && v.Field(i).Kind() == reflect.Struct {
+			if err := applyEnv(v.Field(i), prefix); err != nil {
+				return err
+			}
+			continue
+		}
+		if key == "" || key == "-" || !f.IsExported() {
+			continue
+		}
+		name := prefix + strings.ToUpper(key)
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if field.Type() == reflect.TypeOf(yaml.Node{}) {
+				continue
+			}
+			if err := applyEnv(field, name+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是整数或超出范围: %s", name, value)
+			}
+			field.SetInt(n)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是非负整数或超出范围: %s", name, value)
+			}
+			field.SetUint(n)
+		case reflect.Float32, reflect.Float64:
+			n, err := strconv.ParseFloat(value, field.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是数字: %s", name, value)
+			}
+			field.SetFloat(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是布尔值: %s", name, value)
+			}
+			field.SetBool(b)
+		case reflect.Ptr:
+			// 区分未填写和false的可选布尔值
+			if field.Type().Elem().Kind() != reflect.Bool {
+				return unsupportedEnv(name)
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是布尔值: %s", name, value)
+			}
+			field.Set(reflect.ValueOf(&b))
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				return unsupportedEnv(name)
+			}
+			var list []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			field.Set(reflect.ValueOf(list))
+		default:
+			return unsupportedEnv(name)
+		}
+	}
+	return nil
+}
+
+// unsupportedEnv 设置了无法从环境变量覆盖的配置项，报错而不是忽略，避免误以为已生效
+func unsupportedEnv(name string) error {
+	return fmt.Errorf("环境变量 %s 对应的配置项类型不支持环境变量覆盖", name)
+}
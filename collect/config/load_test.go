@@ -0,0 +1,52 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig 在临时目录写入配置文件，返回文件路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "amp_api.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEnvInt64(t *testing.T) {
+	path := writeConfig(t, "replication:\n  max_gtid_gap: 10\n")
+	t.Setenv("GDB_ALARM_REPLICATION_MAX_GTID_GAP", "5000000000")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Replication.MaxGTIDGap != 5000000000 {
+		t.Errorf("replication.max_gtid_gap = %d, 期望被环境变量覆盖为 5000000000", cfg.Replication.MaxGTIDGap)
+	}
+	// 不应用环境变量时保留文件中的值
+	if cfg, err = LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Replication.MaxGTIDGap != 10 {
+		t.Errorf("replication.max_gtid_gap = %d, 期望 10", cfg.Replication.MaxGTIDGap)
+	}
+}
+
+func TestLoadEnvInvalid(t *testing.T) {
+	path := writeConfig(t, "replication:\n  max_gtid_gap: 10\n")
+	for name, value := range map[string]string{
+		"GDB_ALARM_REPLICATION_MAX_GTID_GAP": "x",
+		"GDB_ALARM_REPLICATION_INTERVAL":     "1.5",
+		"GDB_ALARM_MDS":                      "mds1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := Load(path); err == nil {
+				t.Errorf("%s=%s 应返回错误", name, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Secret 配置中的一个密文
+type Secret struct {
+	Field string // 配置项，如 mds[0].password、alarm.http.auth.token
+	File  string // 所在文件
+	Value string
+}
+
+// Secrets 返回配置中所有非空的密文：MDS和CN密码、推送地址的token/password/签名密钥和SMTP密码
+func (c *Config) Secrets() []Secret {
+	var list []Secret
+	add := func(field, file, value string) {
+		if value != "" {
+			list = append(list, Secret{Field: field, File: file, Value: value})
+		}
+	}
+	mdsFile, cnFile := c.Path, c.Path
+	if c.mdsFile != "" {
+		mdsFile = c.mdsFile
+	}
+	if c.cnFile != "" {
+		cnFile = c.cnFile
+	}
+	for i, m := range c.MDS {
+		add(fmt.Sprintf("mds[%d].password", i), mdsFile, m.Password)
+	}
+	for i, cn := range c.CN {
+		add(fmt.Sprintf("cn[%d].password", i), cnFile, cn.Password)
+	}
+	for _, d := range []struct {
+		field string
+		dest  Destination
+	}{{"alarm.http", c.Alarm.HTTP}, {"digest.http", c.Digest.HTTP}} {
+		add(d.field+".auth.token", c.Path, d.dest.Auth.Token)
+		add(d.field+".auth.password", c.Path, d.dest.Auth.Password)
+		add(d.field+".sign.secret", c.Path, d.dest.Sign.Secret)
+	}
+	add("digest.email.password", c.Path, c.Digest.Email.Password)
+	return list
+}
+
+// RewriteSecrets 把values（配置项 -> 新密文）和新的密钥设置写回配置文件。
+// 主配置文件只替换对应的值，保留注释和格式；旧版 mds.json、cn.json 整体重新生成。
+// 原文件先复制为 文件名+backupSuffix，返回改写的文件
+func (c *Config) RewriteSecrets(values map[string]string, sec Security, backupSuffix string) ([]string, error) {
+	contents := make(map[string][]byte)
+
+	data, err := os.ReadFile(c.Path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", c.Path, err)
+	}
+	var fields []string
+	for _, s := range c.Secrets() {
+		if _, ok := values[s.Field]; ok && s.File == c.Path {
+			fields = append(fields, s.Field)
+		}
+	}
+	if contents[c.Path], err = rewriteYAML(data, fields, values, sec); err != nil {
+		return nil, fmt.Errorf("改写 %s 失败: %w", c.Path, err)
+	}
+
+	if c.mdsFile != "" {
+		list := append([]MDS(nil), c.MDS...)
+		for i := range list {
+			if v, ok := values[fmt.Sprintf("mds[%d].password", i)]; ok {
+				list[i].Password = v
+			}
+		}
+		if contents[c.mdsFile], err = json.MarshalIndent(list, "", "  "); err != nil {
+			return nil, err
+		}
+	}
+	if c.cnFile != "" {
+		list := append([]CN(nil), c.CN...)
+		for i := range list {
+			if v, ok := values[fmt.Sprintf("cn[%d].password", i)]; ok {
+				list[i].Password = v
+			}
+		}
+		if contents[c.cnFile], err = json.MarshalIndent(list, "", "  "); err != nil {
+			return nil, err
+		}
+	}
+
+	// 先写入全部临时文件，再逐个备份和替换
+	var files []string
+	for name, data := range contents {
+		if err := os.WriteFile(name+".tmp", data, 0600); err != nil {
+			return nil, fmt.Errorf("写入 %s 失败: %w", name, err)
+		}
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		if err := copyFile(name, name+backupSuffix); err != nil {
+			return nil, fmt.Errorf("备份 %s 失败: %w", name, err)
+		}
+		if info, err := os.Stat(name); err == nil {
+			os.Chmod(name+".tmp", info.Mode().Perm())
+		}
+		if err := os.Rename(name+".tmp", name); err != nil {
+			return nil, fmt.Errorf("替换 %s 失败: %w", name, err)
+		}
+	}
+	return files, nil
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	// 不覆盖已有的备份
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lineEdit 替换原文第line行第col列（从1开始）处的标量，或在第line行之后插入新行
+type lineEdit struct {
+	line   int
+	col    int
+	value  string
+	insert []string
+}
+
+// rewriteYAML 按行替换fields和security段中的值，不经过YAML重新序列化，以保留注释、空行和引号风格
+func rewriteYAML(data []byte, fields []string, values map[string]string, sec Security) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("配置文件顶层不是映射")
+	}
+	root := doc.Content[0]
+
+	var edits []lineEdit
+	for _, field := range fields {
+		node := findNode(root, field)
+		if node == nil || node.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("未找到配置项 %s", field)
+		}
+		edits = append(edits, lineEdit{line: node.Line, col: node.Column, value: values[field]})
+	}
+
+	settings := [][2]string{{"key_source", sec.KeySource}, {"key_file", sec.KeyFile}, {"salt", sec.Salt}}
+	var appendLines []string
+	key, section := mappingEntry(root, "security")
+	switch {
+	case key == nil:
+		appendLines = append(appendLines, "", "# 密钥设置，由 rotate-key 生成", "security:")
+		for _, s := range settings {
+			appendLines = append(appendLines, "  "+s[0]+": "+strconv.Quote(s[1]))
+		}
+	case section.Kind == yaml.MappingNode && section.Style&yaml.FlowStyle == 0 || section.Tag == "!!null":
+		indent := key.Column - 1 + 2
+		if section.Kind == yaml.MappingNode && len(section.Content) > 0 {
+			indent = section.Content[0].Column - 1
+		}
+		var missing []string
+		for _, s := range settings {
+			if _, node := mappingEntry(section, s[0]); node != nil && node.Kind == yaml.ScalarNode {
+				edits = append(edits, lineEdit{line: node.Line, col: node.Column, value: s[1]})
+			} else {
+				missing = append(missing, strings.Repeat(" ", indent)+s[0]+": "+strconv.Quote(s[1]))
+			}
+		}
+		if len(missing) > 0 {
+			edits = append(edits, lineEdit{line: key.Line, insert: missing})
+		}
+	default:
+		return nil, fmt.Errorf("security 段格式不支持自动改写，请改为块格式")
+	}
+
+	lines := strings.Split(string(data), "\n")
+	// 从后往前修改，插入的行不影响前面的行号
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].line != edits[j].line {
+			return edits[i].line > edits[j].line
+		}
+		return edits[i].col > edits[j].col
+	})
+	for _, e := range edits {
+		if e.line < 1 || e.line > len(lines) {
+			return nil, fmt.Errorf("第 %d 行不存在", e.line)
+		}
+		if e.insert != nil {
+			rest := append(append([]string(nil), e.insert...), lines[e.line:]...)
+			lines = append(lines[:e.line], rest...)
+			continue
+		}
+		line, err := replaceScalar(lines[e.line-1], e.col, e.value)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", e.line, err)
+		}
+		lines[e.line-1] = line
+	}
+	if len(appendLines) > 0 {
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(append(lines, appendLines...), "")
+	}
+	out := []byte(strings.Join(lines, "\n"))
+
+	// 确认改写后的文件仍能解析且值正确
+	var check yaml.Node
+	if err := yaml.Unmarshal(out, &check); err != nil {
+		return nil, fmt.Errorf("改写后的配置无法解析: %w", err)
+	}
+	for _, field := range fields {
+		if node := findNode(check.Content[0], field); node == nil || node.Value != values[field] {
+			return nil, fmt.Errorf("改写 %s 失败", field)
+		}
+	}
+	return out, nil
+}
+
+// replaceScalar 把line中从第col列开始的标量（带引号或不带）替换为双引号字符串value
+func replaceScalar(line string, col int, value string) (string, error) {
+	runes := []rune(line)
+	start := col - 1
+	if start < 0 || start >= len(runes) {
+		return "", fmt.Errorf("列 %d 超出范围", col)
+	}
+	end := -1
+	switch q := runes[start]; q {
+	case '"', '\'':
+		for i := start + 1; i < len(runes); i++ {
+			if runes[i] == '\\' && q == '"' {
+				i++
+				continue
+			}
+			if runes[i] == q {
+				end = i + 1
+				break
+			}
+		}
+		if end < 0 {
+			return "", fmt.Errorf("不支持跨行的字符串")
+		}
+	default:
+		rest := string(runes[start:])
+		if i := strings.Index(rest, " #"); i >= 0 {
+			rest = rest[:i]
+		}
+		rest = strings.TrimRight(rest, " \t")
+		if strings.ContainsAny(rest, ",}]") {
+			return "", fmt.Errorf("不支持改写单行格式中的值")
+		}
+		end = start + len([]rune(rest))
+	}
+	return string(runes[:start]) + strconv.Quote(value) + string(runes[end:]), nil
+}
+
+// findNode 按 mds[0].password、alarm.http.auth.token 形式的路径查找节点
+func findNode(node *yaml.Node, field string) *yaml.Node {
+	for _, part := range strings.Split(field, ".") {
+		name, index := part, -1
+		if i := strings.IndexByte(part, '['); i >= 0 && strings.HasSuffix(part, "]") {
+			n, err := strconv.Atoi(part[i+1 : len(part)-1])
+			if err != nil {
+				return nil
+			}
+			name, index = part[:i], n
+		}
+		if _, node = mappingEntry(node, name); node == nil {
+			return nil
+		}
+		if index >= 0 {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[index]
+		}
+	}
+	return node
+}
+
+// mappingEntry 返回映射中name对应的键和值节点
+func mappingEntry(node *yaml.Node, name string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
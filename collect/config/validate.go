@@ -0,0 +1,433 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Problem 配置中的一个错误
+type Problem struct {
+	Field   string // 配置项，如 alarm.api_address、mds[0].port
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+type problems []Problem
+
+func (ps *problems) add(field, format string, args ...interface{}) {
+	*ps = append(*ps, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate 检查各配置项的取值，主备模式下检查状态文件是否在本地文件系统上，不检查密文能否解密和地址能否连通
+func (c *Config) Validate() []Problem {
+	var ps problems
+
+	if c.Version < 0 || c.Version > CurrentVersion {
+		ps.add("version", "不支持的版本 %d", c.Version)
+	}
+
+	// 告警采集与推送
+	// alarm.http.url 配置后替代 api_address
+	checkURL(&ps, "alarm.api_address", c.Alarm.ApiAddress, c.Alarm.HTTP.URL == "")
+	if c.Alarm.Time <= 0 {
+		ps.add("alarm.time", "监控周期必须大于0")
+	}
+	if c.Alarm.GroupMode != "standby" && c.Alarm.GroupMode != "merge" {
+		ps.add("alarm.group_mode", "只能是 standby 或 merge")
+	}
+	if c.Alarm.Batch.MaxSize <= 0 {
+		ps.add("alarm.batch.max_size", "必须大于0")
+	}
+	d := c.Alarm.Dispatch
+	if d.Workers <= 0 {
+		ps.add("alarm.dispatch.workers", "必须大于0")
+	}
+	if d.QueueSize < d.Workers {
+		ps.add("alarm.dispatch.queue_size", "不能小于推送协程数量 %d", d.Workers)
+	}
+	if d.QueryTimeout <= 0 {
+		ps.add("alarm.dispatch.query_timeout", "必须大于0")
+	}
+	if d.DrainTimeout <= 0 {
+		ps.add("alarm.dispatch.drain_timeout", "必须大于0")
+	}
+	checkDestination(&ps, "alarm.http", c.Alarm.HTTP)
+
+	// 日志
+	if c.Log.Path == "" {
+		ps.add("log.path", "不能为空")
+	}
+	switch strings.ToUpper(c.Log.Level) {
+	case "DEBUG", "INFO", "WARN", "WARNING", "ERROR":
+	default:
+		ps.add("log.level", "只能是 DEBUG、INFO、WARN、ERROR")
+	}
+	if c.Log.Format != "" && c.Log.Format != "text" && c.Log.Format != "json" {
+		ps.add("log.format", "只能是 text 或 json")
+	}
+	if c.Log.KeepDays < 0 {
+		ps.add("log.keep_days", "不能小于0")
+	}
+	if c.Log.KeepFiles < 0 {
+		ps.add("log.keep_files", "不能小于0")
+	}
+	if c.Log.CleanInterval <= 0 {
+		ps.add("log.clean_interval", "必须大于0")
+	}
+
+	if c.History.Enabled && c.History.Path == "" {
+		ps.add("history.path", "不能为空")
+	}
+	if c.Digest.Enabled {
+		c.validateDigest(&ps)
+	}
+	if c.CNMonitor.Enabled {
+		c.validateCNMonitor(&ps)
+	}
+	if c.Replication.Enabled {
+		c.validateReplication(&ps)
+	}
+	if c.RoleAudit.Enabled {
+		c.validateRoleAudit(&ps)
+	}
+
+	// 主备与状态
+	if c.HA.Enabled {
+		switch c.HA.Mode {
+		case "mysql":
+			if c.HA.MDS != "" && !c.hasMDS(c.HA.MDS) {
+				ps.add("ha.mds", "未找到名为 %s 的MDS", c.HA.MDS)
+			}
+		case "file":
+			if c.HA.LockFile == "" {
+				ps.add("ha.lock_file", "不能为空")
+			}
+		default:
+			ps.add("ha.mode", "只能是 mysql 或 file")
+		}
+		if c.HA.CheckInterval <= 0 {
+			ps.add("ha.check_interval", "必须大于0")
+		}
+	}
+	if c.State.Path == "" {
+		ps.add("state.path", "不能为空")
+	} else if c.HA.Enabled {
+		// 备实例接管时需读取主实例保存的状态，状态文件必须在各实例共享的存储上
+		if !filepath.IsAbs(c.State.Path) {
+			ps.add("state.path", "主备模式下需为共享存储上的绝对路径，%s 是本机安装目录下的相对路径", c.State.Path)
+		} else if fs, ok := localFilesystem(c.State.Path); ok {
+			ps.add("state.path", "主备模式下需放在共享存储上，%s 位于本地文件系统 %s", c.State.Path, fs)
+		}
+	}
+	if c.State.Interval <= 0 {
+		ps.add("state.interval", "必须大于0")
+	}
+	if c.Service.StopTimeout <= c.Alarm.Dispatch.DrainTimeout {
+		ps.add("service.stop_timeout", "应大于 alarm.dispatch.drain_timeout (%d)", c.Alarm.Dispatch.DrainTimeout)
+	}
+
+	switch c.Security.KeySource {
+	case "", "builtin", "env":
+	case "file":
+		if c.Security.KeyFile == "" {
+			ps.add("security.key_file", "不能为空")
+		}
+	case "passphrase":
+		if salt, err := base64.StdEncoding.DecodeString(c.Security.Salt); err != nil || len(salt) == 0 {
+			ps.add("security.salt", "passphrase方式需要有效的盐值，请使用 rotate-key 生成")
+		}
+	default:
+		ps.add("security.key_source", "只能是 builtin、file、env 或 passphrase")
+	}
+
+	// 数据库连接
+	checkDBConn(&ps, "database", c.Database.DBConn)
+	if c.Database.DN != (DBConn{}) {
+		checkDBConn(&ps, "database.dn", c.DNConn())
+	}
+
+	// 节点
+	if len(c.MDS) == 0 {
+		ps.add("mds", "没有配置MDS")
+	}
+	names := make(map[string]bool)
+	for i, m := range c.MDS {
+		field := fmt.Sprintf("mds[%d]", i)
+		if m.Name == "" {
+			ps.add(field+".name", "不能为空")
+		} else if names[m.Name] {
+			ps.add(field+".name", "重复的MDS名称 %s", m.Name)
+		}
+		names[m.Name] = true
+		checkNode(&ps, field, m.Host, m.Port, m.Username, m.Password)
+		if m.Conn != nil {
+			checkDBConn(&ps, field+".conn", c.MDSConn(m))
+		}
+	}
+	for i, cn := range c.CN {
+		field := fmt.Sprintf("cn[%d]", i)
+		if cn.Name == "" {
+			ps.add(field+".name", "不能为空")
+		}
+		checkNode(&ps, field, cn.Host, cn.Port, cn.Username, cn.Password)
+		if cn.Conn != nil {
+			checkDBConn(&ps, field+".conn", c.CNConn(cn))
+		}
+	}
+	return ps
+}
+
+func (c *Config) validateDigest(ps *problems) {
+	if len(c.Digest.Times) == 0 {
+		ps.add("digest.times", "不能为空")
+	}
+	for i, t := range c.Digest.Times {
+		if _, err := time.Parse("15:04", t); err != nil {
+			ps.add(fmt.Sprintf("digest.times[%d]", i), "格式应为 HH:MM: %s", t)
+		}
+	}
+	if c.Digest.Top <= 0 {
+		ps.add("digest.top", "必须大于0")
+	}
+	switch c.Digest.Type {
+	case "webhook":
+		checkURL(ps, "digest.webhook", c.Digest.Webhook, true)
+		checkDestination(ps, "digest.http", c.Digest.HTTP)
+	case "email":
+		e := c.Digest.Email
+		if e.Host == "" {
+			ps.add("digest.email.host", "不能为空")
+		}
+		if e.Port <= 0 || e.Port > 65535 {
+			ps.add("digest.email.port", "端口无效: %d", e.Port)
+		}
+		if e.From == "" {
+			ps.add("digest.email.from", "不能为空")
+		} else if _, err := mail.ParseAddress(e.From); err != nil {
+			ps.add("digest.email.from", "不是有效的邮件地址: %s", e.From)
+		}
+		if len(e.To) == 0 {
+			ps.add("digest.email.to", "不能为空")
+		}
+	case "file":
+		if c.Digest.Dir == "" {
+			ps.add("digest.dir", "不能为空")
+		}
+	default:
+		ps.add("digest.type", "只能是 webhook、email 或 file")
+	}
+}
+
+func (c *Config) validateCNMonitor(ps *problems) {
+	m := c.CNMonitor
+	if len(c.CN) == 0 {
+		ps.add("cn_monitor.enabled", "没有配置CN")
+	}
+	for _, v := range []struct {
+		name  string
+		value int
+	}{{"interval", m.Interval}, {"timeout", m.Timeout}, {"slow_ms", m.SlowMs}, {"backlog_time", m.BacklogTime}, {"trigger", m.Trigger}} {
+		if v.value <= 0 {
+			ps.add("cn_monitor."+v.name, "必须大于0")
+		}
+	}
+	for _, v := range []struct {
+		name  string
+		value int
+	}{{"max_threads_running", m.MaxThreadsRunning}, {"max_conn_usage", m.MaxConnUsage}, {"max_backlog", m.MaxBacklog}} {
+		if v.value < 0 {
+			ps.add("cn_monitor."+v.name, "不能小于0")
+		}
+	}
+	if m.MaxConnUsage > 100 {
+		ps.add("cn_monitor.max_conn_usage", "是百分比，不能大于100")
+	}
+	if m.Timeout >= m.Interval {
+		ps.add("cn_monitor.timeout", "应小于探测间隔 %d", m.Interval)
+	}
+	codes := map[int]string{}
+	for _, v := range []struct {
+		name string
+		code int
+	}{{"down", m.Codes.Down}, {"slow", m.Codes.Slow}, {"saturated", m.Codes.Saturated}, {"backlog", m.Codes.Backlog}} {
+		if prev, ok := codes[v.code]; ok {
+			ps.add("cn_monitor.codes."+v.name, "与 %s 重复: %d", prev, v.code)
+		}
+		codes[v.code] = v.name
+	}
+}
+
+func (c *Config) validateReplication(ps *problems) {
+	m := c.Replication
+	for _, v := range []struct {
+		name  string
+		value int
+	}{{"interval", m.Interval}, {"timeout", m.Timeout}, {"concurrency", m.Concurrency}, {"max_lag", m.MaxLag}, {"trigger", m.Trigger}} {
+		if v.value <= 0 {
+			ps.add("replication."+v.name, "必须大于0")
+		}
+	}
+	if m.MaxGTIDGap < 0 {
+		ps.add("replication.max_gtid_gap", "不能小于0")
+	}
+	if m.Timeout >= m.Interval {
+		ps.add("replication.timeout", "应小于检查间隔 %d", m.Interval)
+	}
+	codes := map[int]string{}
+	for _, v := range []struct {
+		name string
+		code int
+	}{{"io_stopped", m.Codes.IOStopped}, {"sql_stopped", m.Codes.SQLStopped}, {"lag", m.Codes.Lag}, {"gtid_gap", m.Codes.GTIDGap}, {"check_failed", m.Codes.CheckFailed}} {
+		if prev, ok := codes[v.code]; ok {
+			ps.add("replication.codes."+v.name, "与 %s 重复: %d", prev, v.code)
+		}
+		codes[v.code] = v.name
+	}
+}
+
+func (c *Config) validateRoleAudit(ps *problems) {
+	m := c.RoleAudit
+	for _, v := range []struct {
+		name  string
+		value int
+	}{{"interval", m.Interval}, {"timeout", m.Timeout}, {"concurrency", m.Concurrency}, {"trigger", m.Trigger}} {
+		if v.value <= 0 {
+			ps.add("role_audit."+v.name, "必须大于0")
+		}
+	}
+	if m.Timeout >= m.Interval {
+		ps.add("role_audit.timeout", "应小于检查间隔 %d", m.Interval)
+	}
+	codes := map[int]string{}
+	for _, v := range []struct {
+		name string
+		code int
+	}{{"no_master", m.Codes.NoMaster}, {"multi_master", m.Codes.MultiMaster}, {"split_brain", m.Codes.SplitBrain}, {"master_readonly", m.Codes.MasterReadOnly},
+		{"slave_writable", m.Codes.SlaveWritable}, {"replication", m.Codes.Replication}, {"check_failed", m.Codes.CheckFailed}, {"replica_status", m.Codes.ReplicaStatus}} {
+		if prev, ok := codes[v.code]; ok {
+			ps.add("role_audit.codes."+v.name, "与 %s 重复: %d", prev, v.code)
+		}
+		codes[v.code] = v.name
+	}
+}
+
+func (c *Config) hasMDS(name string) bool {
+	for _, m := range c.MDS {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func checkURL(ps *problems, field, value string, required bool) {
+	if value == "" {
+		if required {
+			ps.add(field, "不能为空")
+		}
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		ps.add(field, "不是有效的http/https地址: %s", value)
+	}
+}
+
+func checkDestination(ps *problems, field string, d Destination) {
+	checkURL(ps, field+".url", d.URL, false)
+	if d.Timeout < 0 {
+		ps.add(field+".timeout", "不能小于0")
+	}
+	if d.Proxy != "" {
+		if u, err := url.Parse(d.Proxy); err != nil || u.Host == "" {
+			ps.add(field+".proxy", "不是有效的代理地址: %s", d.Proxy)
+		}
+	}
+	switch d.Auth.Type {
+	case "":
+	case "bearer":
+		if d.Auth.Token == "" {
+			ps.add(field+".auth.token", "bearer认证需要token")
+		}
+	case "basic":
+		if d.Auth.Username == "" || d.Auth.Password == "" {
+			ps.add(field+".auth", "basic认证需要username和password")
+		}
+	default:
+		ps.add(field+".auth.type", "只能是 bearer 或 basic")
+	}
+	if (d.TLS.Cert == "") != (d.TLS.Key == "") {
+		ps.add(field+".tls", "cert和key需同时配置")
+	}
+	files := [][2]string{{"ca", d.TLS.CA}, {"cert", d.TLS.Cert}, {"key", d.TLS.Key}}
+	for _, f := range files {
+		if f[1] == "" {
+			continue
+		}
+		if _, err := os.Stat(f[1]); err != nil {
+			ps.add(field+".tls."+f[0], "文件不可读: %v", err)
+		}
+	}
+}
+
+func checkNode(ps *problems, field, host string, port int, username, password string) {
+	if host == "" {
+		ps.add(field+".host", "不能为空")
+	}
+	if port <= 0 || port > 65535 {
+		ps.add(field+".port", "端口无效: %d", port)
+	}
+	if username == "" {
+		ps.add(field+".username", "不能为空")
+	}
+	if password == "" {
+		ps.add(field+".password", "不能为空")
+	}
+}
+
+// checkDBConn 检查合并后的连接设置
+func checkDBConn(ps *problems, field string, d DBConn) {
+	for _, v := range []struct {
+		name  string
+		value int
+	}{
+		{"connect_timeout", d.ConnectTimeout},
+		{"read_timeout", d.ReadTimeout},
+		{"write_timeout", d.WriteTimeout},
+		{"max_open", d.MaxOpen},
+		{"max_idle", d.MaxIdle},
+		{"max_lifetime", d.MaxLifetime},
+	} {
+		if v.value < 0 {
+			ps.add(field+"."+v.name, "不能小于0")
+		}
+	}
+	if d.MaxOpen > 0 && d.MaxIdle > d.MaxOpen {
+		ps.add(field+".max_idle", "不能大于 max_open (%d)", d.MaxOpen)
+	}
+	switch d.TLS.Mode {
+	case "", "disable", "preferred", "required", "verify-ca", "verify-full":
+	default:
+		ps.add(field+".tls.mode", "只能是 disable、preferred、required、verify-ca 或 verify-full")
+	}
+	if (d.TLS.Cert == "") != (d.TLS.Key == "") {
+		ps.add(field+".tls", "cert和key需同时配置")
+	}
+	for _, f := range [][2]string{{"ca", d.TLS.CA}, {"cert", d.TLS.Cert}, {"key", d.TLS.Key}} {
+		if f[1] == "" {
+			continue
+		}
+		if _, err := os.Stat(f[1]); err != nil {
+			ps.add(field+".tls."+f[0], "文件不可读: %v", err)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package connect
+
+import "GoldenDB/config"
+
+// DBConn 连接设置，由配置文件的 database 段和节点的 conn 段合并得到
+type DBConn = config.DBConn
+
+// DBTLS TLS设置
+type DBTLS = config.DBTLS
@@ -1,52 +1,43 @@
 package connect
 
 import (
+	"GoldenDB/config"
+	"GoldenDB/redact"
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"io"
-	"os"
+	"net"
+	"strconv"
 )
 
+// EncryptionKey 旧版编译在程序中的密钥，任何拿到程序的人都能解密，仅用于兼容已有密文（key_source: builtin）
 const EncryptionKey = "879kf28Ls987kF982k789lK87982k789"
 
-type MDS struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-type CN struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Schema   string `json:"schema"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
+type MDS = config.MDS
+type CN = config.CN
 type CNConnect struct {
-	DSN      string
-	Username string
-	Password string
-	Schema   string
+	Node
+	Schema string
 }
 
 type MDSDemo struct {
-	DSN      string
-	Name     string
-	Username string
-	Password string
+	Node
+	Group string
 }
 
-// 加密函数
+// Decrypt 用当前密钥解密
 func Decrypt(input string) (string, error) {
-	key := []byte(EncryptionKey)
+	return DecryptWith(currentKey, input)
+}
+
+// DecryptWith 用指定密钥解密
+func DecryptWith(key []byte, input string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(input)
 	if err != nil {
 		return "", err
@@ -73,12 +64,18 @@ func Decrypt(input string) (string, error) {
 		return "", err
 	}
 
+	// 解密出的明文登记到redact，之后写入日志和错误信息时自动遮盖
+	redact.Register(string(plaintext))
 	return string(plaintext), nil
 }
 
-// 解密函数
+// Encrypt 用当前密钥加密
 func Encrypt(input string) (string, error) {
-	key := []byte(EncryptionKey)
+	return EncryptWith(currentKey, input)
+}
+
+// EncryptWith 用指定密钥加密
+func EncryptWith(key []byte, input string) (string, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return "", err
@@ -101,67 +98,109 @@ func Encrypt(input string) (string, error) {
 	return base64.StdEncoding.EncodeToString(result), nil
 }
 
-func GetMDS() []MDSDemo {
-	var MDSList []MDS
-	file, err := os.ReadFile("config/mds.json")
-	if err != nil {
-		panic(err)
+// NewConfig 生成连接单个节点的驱动配置，不含超时和TLS设置，完整配置见 Node.MySQLConfig
+func NewConfig(user, password, host string, port int, dbName string) *mysql.Config {
+	cfg := mysql.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	cfg.DBName = dbName
+	cfg.LoadBalance = false
+	cfg.BlackList = -1
+	return cfg
+}
+
+// RedactDSN 返回密码被遮盖的DSN，用于日志和错误信息
+func RedactDSN(cfg *mysql.Config) string {
+	if cfg == nil {
+		return ""
 	}
-	var MDSDemosList []MDSDemo
-	err = json.Unmarshal(file, &MDSList)
-	if err != nil {
-		panic(err)
+	c := cfg.Clone()
+	if c.Passwd != "" {
+		c.Passwd = redact.Mask
 	}
-	for _, v := range MDSList {
-		v.Password, _ = Decrypt(v.Password)
-		dsn := v.Username + ":" + v.Password + "@tcp(" +
-			v.Host + ":" + fmt.Sprintf("%d", v.Port) + ")/" +
-			"mds" + "?loadbalance=false&blacklist=-1"
+	return c.FormatDSN()
+}
 
-		MDSDemosList = append(MDSDemosList, MDSDemo{DSN: dsn, Username: v.Username, Password: v.Password, Name: v.Name})
+// GetMDS 解密各MDS的密码并生成节点连接信息，密码无法解密时返回错误，不会使用空密码连接
+func GetMDS(cfg *config.Config) ([]MDSDemo, error) {
+	var MDSDemosList []MDSDemo
+	for _, v := range cfg.MDS {
+		password, err := Decrypt(v.Password)
+		if err != nil {
+			return nil, fmt.Errorf("MDS %s 的密码解密失败: %w", v.Name, err)
+		}
+
+		group := v.Group
+		if group == "" {
+			group = v.Name
+		}
+		MDSDemosList = append(MDSDemosList, MDSDemo{
+			Node: Node{
+				Name:     v.Name,
+				Host:     v.Host,
+				Port:     v.Port,
+				User:     v.Username,
+				Password: password,
+				DBName:   "mds",
+				Conn:     cfg.MDSConn(v),
+			},
+			Group: group,
+		})
 	}
-	return MDSDemosList
+	return MDSDemosList, nil
 }
-func GetCN() []CNConnect {
-	var CNList []CN
+
+// GetCN 解密各CN的密码并生成节点连接信息，密码无法解密时返回错误
+func GetCN(cfg *config.Config) ([]CNConnect, error) {
 	var DemoList []CNConnect
-	file, err := os.ReadFile("config/mds.json")
-	if err != nil {
-		panic(err)
+	for _, v := range cfg.CN {
+		password, err := Decrypt(v.Password)
+		if err != nil {
+			return nil, fmt.Errorf("CN %s 的密码解密失败: %w", v.Name, err)
+		}
+		DemoList = append(DemoList, CNConnect{
+			Node: Node{
+				Name:     v.Name,
+				Host:     v.Host,
+				Port:     v.Port,
+				User:     v.Username,
+				Password: password,
+				DBName:   v.Schema,
+				Conn:     cfg.CNConn(v),
+			},
+			Schema: v.Schema,
+		})
 	}
-	err = json.Unmarshal(file, &CNList)
+	return DemoList, nil
+}
+
+// OpenDB 创建独立的连接池但不立即建联，连接失败在首次查询时返回错误而不是panic。
+// 需要复用连接时使用 Open/Connect
+func OpenDB(cfg *mysql.Config) (*sql.DB, error) {
+	connector, err := mysql.NewConnector(cfg)
 	if err != nil {
-		panic(err)
-	}
-	for _, v := range CNList {
-		v.Password, _ = Decrypt(v.Password)
-		dsn := v.Username + ":" + v.Password + "@tcp(" +
-			v.Host + ":" + fmt.Sprintf("%d", v.Port) + ")/" +
-			v.Schema + "?loadbalance=false&blacklist=-1"
-		DemoList = append(DemoList, CNConnect{DSN: dsn, Username: v.Username, Password: v.Password, Schema: v.Schema})
+		return nil, fmt.Errorf("%s: %w", RedactDSN(cfg), err)
 	}
-	return DemoList
+	return sql.OpenDB(connector), nil
 }
 
-func GetDBConnect(dsn string) *sql.DB {
-	return GetDBConnects([]string{dsn})[0]
+// GetDBConnect 从共用工厂获取节点的连接池并Ping校验
+func GetDBConnect(n Node) (*sql.DB, error) {
+	return Connect(context.Background(), n)
 }
-func GetDBConnects(dsns []string) []*sql.DB {
-	// 初始化一个切片，用于存储所有成功创建的数据库连接
-	dbList := make([]*sql.DB, 0, len(dsns))
-	// 遍历每一个传入的DSN
-	for _, dsn := range dsns {
-		open, err := sql.Open("mysql", dsn)
-		if err != nil {
-			panic("dsn: " + dsn + ", open fail: " + err.Error())
-		}
-		// Ping校验连接可用性，sql.Open是懒加载，必须Ping才会真正建联
-		err = open.Ping()
+
+// GetDBConnects 逐个获取节点的连接池并Ping校验，任一失败时返回错误，错误中的密码已遮盖。
+// 同一节点在多次检查间复用连接池，返回的连接池由 CloseAll 统一关闭
+func GetDBConnects(nodes []Node) ([]*sql.DB, error) {
+	dbList := make([]*sql.DB, 0, len(nodes))
+	for _, n := range nodes {
+		db, err := GetDBConnect(n)
 		if err != nil {
-			panic("dsn: " + dsn + ", ping fail: " + err.Error())
+			return nil, err
 		}
-		// 连接成功，加入切片
-		dbList = append(dbList, open)
+		dbList = append(dbList, db)
 	}
-	return dbList
+	return dbList, nil
 }
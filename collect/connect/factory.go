@@ -0,0 +1,209 @@
+package connect
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Node 一个MDS、CN或DN节点的连接信息，Password为解密后的明文。
+// 本文件在 collect 和 checkPartition 中内容相同，DBConn 由各自的 conn.go 定义
+type Node struct {
+	Name     string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DBName   string
+	Conn     DBConn
+}
+
+// Addr 节点地址 host:port
+func (n Node) Addr() string {
+	return net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+}
+
+// key 连接池的复用键，地址、用户、库和连接设置都相同时共用一个连接池
+func (n Node) key() string {
+	return fmt.Sprintf("%s@%s/%s|%+v", n.User, n.Addr(), n.DBName, n.Conn)
+}
+
+// MySQLConfig 生成驱动配置，包含超时和TLS设置。密码直接写入配置而不拼接DSN字符串，
+// 含有@、/、:等特殊字符时也能正确连接
+func (n Node) MySQLConfig() (*mysql.Config, error) {
+	cfg := NewConfig(n.User, n.Password, n.Host, n.Port, n.DBName)
+	cfg.Timeout = seconds(n.Conn.ConnectTimeout)
+	cfg.ReadTimeout = seconds(n.Conn.ReadTimeout)
+	cfg.WriteTimeout = seconds(n.Conn.WriteTimeout)
+	tlsConfig, fallback, err := newTLSConfig(n.Conn.TLS, n.Host)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", n.Name, n.Addr(), err)
+	}
+	cfg.TLS = tlsConfig
+	cfg.AllowFallbackToPlaintext = fallback
+	return cfg, nil
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+// newTLSConfig 按模式生成TLS配置，disable时返回nil；preferred时允许服务端不支持TLS时回退到明文
+func newTLSConfig(t DBTLS, host string) (*tls.Config, bool, error) {
+	if t.Mode == "" || t.Mode == "disable" {
+		return nil, false, nil
+	}
+	// 驱动在未设置ServerName时会读取负载均衡地址列表，这里总是显式设置
+	tlsConfig := &tls.Config{ServerName: t.ServerName}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	if t.Cert != "" || t.Key != "" {
+		cert, err := tls.LoadX509KeyPair(t.Cert, t.Key)
+		if err != nil {
+			return nil, false, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	var roots *x509.CertPool
+	if t.CA != "" {
+		pem, err := os.ReadFile(t.CA)
+		if err != nil {
+			return nil, false, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, false, fmt.Errorf("CA证书 %s 中没有有效的PEM证书", t.CA)
+		}
+	}
+
+	switch t.Mode {
+	case "preferred", "required":
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, t.Mode == "preferred", nil
+	case "verify-ca":
+		// 只校验证书链，不校验主机名：跳过默认校验，在握手后用CA自行校验
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(raw, roots)
+		}
+		return tlsConfig, false, nil
+	case "verify-full":
+		tlsConfig.RootCAs = roots
+		return tlsConfig, false, nil
+	}
+	return nil, false, fmt.Errorf("不支持的TLS模式: %s", t.Mode)
+}
+
+// verifyChain 用roots（为nil时使用系统CA）校验服务端证书链
+func verifyChain(raw [][]byte, roots *x509.CertPool) error {
+	if len(raw) == 0 {
+		return fmt.Errorf("服务端未提供证书")
+	}
+	certs := make([]*x509.Certificate, len(raw))
+	for i, b := range raw {
+		cert, err := x509.ParseCertificate(b)
+		if err != nil {
+			return fmt.Errorf("解析服务端证书失败: %w", err)
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// Factory 连接池工厂。同一节点的连接池只创建一次，在多次查询之间复用；
+// 由工厂创建的*sql.DB不要自行Close，统一由Factory.Close关闭
+type Factory struct {
+	mu    sync.Mutex
+	pools map[string]*sql.DB
+}
+
+// NewFactory 创建连接池工厂
+func NewFactory() *Factory {
+	return &Factory{pools: make(map[string]*sql.DB)}
+}
+
+// Open 返回节点的连接池，已有时直接复用。连接池不立即建联，连接失败在首次查询时返回错误
+func (f *Factory) Open(n Node) (*sql.DB, error) {
+	key := n.key()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if db, ok := f.pools[key]; ok {
+		return db, nil
+	}
+	cfg, err := n.MySQLConfig()
+	if err != nil {
+		return nil, err
+	}
+	db, err := OpenDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(n.Conn.MaxOpen)
+	db.SetMaxIdleConns(n.Conn.MaxIdle)
+	db.SetConnMaxLifetime(seconds(n.Conn.MaxLifetime))
+	f.pools[key] = db
+	return db, nil
+}
+
+// Connect 返回节点的连接池并Ping校验，Ping超时为节点的connect_timeout
+func (f *Factory) Connect(ctx context.Context, n Node) (*sql.DB, error) {
+	db, err := f.Open(n)
+	if err != nil {
+		return nil, err
+	}
+	if n.Conn.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, seconds(n.Conn.ConnectTimeout))
+		defer cancel()
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("%s %s: ping失败: %w", n.Name, n.Addr(), err)
+	}
+	return db, nil
+}
+
+// Close 关闭工厂创建的全部连接池
+func (f *Factory) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var first error
+	for key, db := range f.pools {
+		if err := db.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(f.pools, key)
+	}
+	return first
+}
+
+// pools 程序共用的连接池工厂
+var pools = NewFactory()
+
+// Open 从共用工厂获取节点的连接池
+func Open(n Node) (*sql.DB, error) {
+	return pools.Open(n)
+}
+
+// Connect 从共用工厂获取节点的连接池并Ping校验
+func Connect(ctx context.Context, n Node) (*sql.DB, error) {
+	return pools.Connect(ctx, n)
+}
+
+// CloseAll 关闭共用工厂中的全部连接池，程序退出时调用
+func CloseAll() error {
+	return pools.Close()
+}
@@ -0,0 +1,177 @@
+package connect
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 密钥来源
+const (
+	KeySourceBuiltin    = "builtin"    // 编译在程序中的旧版密钥，仅用于兼容已有密文
+	KeySourceFile       = "file"       // 密钥文件，只允许属主读写
+	KeySourceEnv        = "env"        // 环境变量 GDB_KEY
+	KeySourcePassphrase = "passphrase" // 由口令经scrypt派生
+)
+
+// 密钥相关的环境变量。未在配置中指定密钥来源时，按 GDB_KEY_FILE、GDB_KEY、GDB_KEY_SALT 的顺序决定，
+// 都未设置时使用内置密钥
+const (
+	EnvKeyFile    = "GDB_KEY_FILE"       // 密钥文件路径
+	EnvKey        = "GDB_KEY"            // base64编码的32字节密钥
+	EnvSalt       = "GDB_KEY_SALT"       // 口令派生密钥的盐值（base64）
+	EnvPassphrase = "GDB_KEY_PASSPHRASE" // 派生密钥的口令，未设置时在终端提示输入
+)
+
+// KeySize AES-256密钥长度
+const KeySize = 32
+
+// scrypt参数，派生一次约需100ms，提高暴力猜测口令的成本
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// KeyOptions 密钥来源设置
+type KeyOptions struct {
+	Source string // builtin | file | env | passphrase
+	File   string // file方式的密钥文件
+	Salt   string // passphrase方式的盐值（base64）
+}
+
+// KeyOptionsFromEnv 由环境变量确定密钥来源，供未配置密钥来源时使用
+func KeyOptionsFromEnv() KeyOptions {
+	switch {
+	case os.Getenv(EnvKeyFile) != "":
+		return KeyOptions{Source: KeySourceFile, File: os.Getenv(EnvKeyFile)}
+	case os.Getenv(EnvKey) != "":
+		return KeyOptions{Source: KeySourceEnv}
+	case os.Getenv(EnvSalt) != "":
+		return KeyOptions{Source: KeySourcePassphrase, Salt: os.Getenv(EnvSalt)}
+	}
+	return KeyOptions{Source: KeySourceBuiltin}
+}
+
+// currentKey Encrypt/Decrypt使用的密钥，启动时由SetKey设置
+var currentKey = []byte(EncryptionKey)
+
+// SetKey 设置Encrypt/Decrypt使用的密钥
+func SetKey(key []byte) {
+	currentKey = key
+}
+
+// LoadKey 按来源读取密钥
+func LoadKey(opts KeyOptions) ([]byte, error) {
+	switch opts.Source {
+	case "", KeySourceBuiltin:
+		return []byte(EncryptionKey), nil
+	case KeySourceFile:
+		return ReadKeyFile(opts.File)
+	case KeySourceEnv:
+		value := os.Getenv(EnvKey)
+		if value == "" {
+			return nil, fmt.Errorf("环境变量 %s 未设置", EnvKey)
+		}
+		return DecodeKey(value)
+	case KeySourcePassphrase:
+		salt, err := base64.StdEncoding.DecodeString(opts.Salt)
+		if err != nil || len(salt) == 0 {
+			return nil, fmt.Errorf("密钥盐值无效，请检查配置或环境变量 %s", EnvSalt)
+		}
+		passphrase := os.Getenv(EnvPassphrase)
+		if passphrase == "" {
+			if !stdinIsTerminal() {
+				return nil, fmt.Errorf("未设置环境变量 %s", EnvPassphrase)
+			}
+			if passphrase, err = ReadSecret("请输入密钥口令: ", false); err != nil {
+				return nil, err
+			}
+		}
+		return DeriveKey(passphrase, salt)
+	}
+	return nil, fmt.Errorf("不支持的密钥来源: %s", opts.Source)
+}
+
+// GenerateKey 生成随机密钥
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// NewSalt 生成口令派生密钥用的随机盐值
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成盐值失败: %w", err)
+	}
+	return salt, nil
+}
+
+// DeriveKey 用scrypt由口令派生密钥
+func DeriveKey(passphrase string, salt []byte) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("口令不能为空")
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("派生密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// EncodeKey 把密钥编码为base64，用于密钥文件和环境变量
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// DecodeKey 解析base64编码的密钥
+func DecodeKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("密钥不是有效的base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("密钥长度应为 %d 字节，实际为 %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// ReadKeyFile 读取密钥文件，文件允许组或其他用户访问时拒绝使用
+func ReadKeyFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	if err := checkKeyFileMode(path, info); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+	}
+	key, err := DecodeKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("密钥文件 %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// WriteKeyFile 以0600权限写入密钥文件，已存在时覆盖
+func WriteKeyFile(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("创建密钥目录失败: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(EncodeKey(key)+"\n"), 0600); err != nil {
+		return fmt.Errorf("写入密钥文件失败: %w", err)
+	}
+	// 覆盖已有文件时WriteFile不会修改权限
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package connect
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkKeyFileMode 密钥文件只允许属主读写
+func checkKeyFileMode(path string, info os.FileInfo) error {
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("密钥文件 %s 的权限为 %04o，只允许属主读写，请执行 chmod 600 %s", path, perm, path)
+	}
+	return nil
+}
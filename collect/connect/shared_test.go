@@ -0,0 +1,48 @@
+package connect
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sharedFiles collect 和 checkPartition 中内容必须相同的文件，路径相对于各自的模块根目录
+var sharedFiles = []string{
+	"connect/factory.go",
+	"connect/key.go",
+	"connect/key_unix.go",
+	"connect/key_other.go",
+	"connect/prompt.go",
+	"connect/shared_test.go",
+	"redact/redact.go",
+}
+
+// TestSharedFiles 两个程序中共用的文件被单独修改时失败。本文件在两边相同，另一份程序不在时跳过
+func TestSharedFiles(t *testing.T) {
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := map[string]string{"collect": "checkPartition", "checkPartition": "collect"}[filepath.Base(root)]
+	if other == "" {
+		t.Skipf("未知的模块目录 %s", root)
+	}
+	otherRoot := filepath.Join(filepath.Dir(root), other)
+	if _, err := os.Stat(otherRoot); err != nil {
+		t.Skipf("没有找到 %s: %v", otherRoot, err)
+	}
+	for _, name := range sharedFiles {
+		a, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(filepath.Join(otherRoot, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s 与 %s 中的同名文件不同，需同时修改两份", name, other)
+		}
+	}
+}
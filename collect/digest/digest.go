@@ -0,0 +1,153 @@
+package digest
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/history"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// LevelCount 某个级别的活动告警数量
+type LevelCount struct {
+	Level int    `json:"level"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// OpenAlarm 持续时间较长的活动告警
+type OpenAlarm struct {
+	AlarmID    int    `json:"alarmId"`
+	Code       int    `json:"code"`
+	Level      int    `json:"level"`
+	Host       string `json:"host"`
+	Content    string `json:"content"`
+	CreateTime string `json:"createTime"`
+	OpenFor    string `json:"openFor"`
+}
+
+// InsightDigest 单个insight的告警摘要
+type InsightDigest struct {
+	Insight  string       `json:"insight"`
+	Open     int          `json:"open"`
+	ByLevel  []LevelCount `json:"byLevel"`
+	Longest  []OpenAlarm  `json:"longest"`
+	New24h   int          `json:"new24h"`
+	Resolved int          `json:"resolved24h"`
+}
+
+// Digest 告警摘要
+type Digest struct {
+	GeneratedAt string          `json:"generatedAt"`
+	History     bool            `json:"history"` // 是否有告警历史可用于统计最近24小时
+	Insights    []InsightDigest `json:"insights"`
+}
+
+// Build 根据当前活动告警和最近24小时的历史事件生成摘要，
+// events为nil表示未启用告警历史
+func Build(now time.Time, active map[string][]alarm.AlarmInfo, events []history.Event, top int) *Digest {
+	d := &Digest{GeneratedAt: now.Format(timeLayout), History: events != nil}
+	byInsight := make(map[string]*InsightDigest)
+	get := func(insight string) *InsightDigest {
+		item, ok := byInsight[insight]
+		if !ok {
+			item = &InsightDigest{Insight: insight}
+			byInsight[insight] = item
+		}
+		return item
+	}
+
+	for insight, alarms := range active {
+		item := get(insight)
+		item.Open = len(alarms)
+		levels := make(map[int]int)
+		for _, a := range alarms {
+			levels[a.Level]++
+		}
+		for level, count := range levels {
+			item.ByLevel = append(item.ByLevel, LevelCount{Level: level, Name: alarm.AlarmLevelMap[level], Count: count})
+		}
+		sort.Slice(item.ByLevel, func(i, j int) bool { return item.ByLevel[i].Level < item.ByLevel[j].Level })
+
+		// alarms已按创建时间升序排列
+		for i, a := range alarms {
+			if i >= top {
+				break
+			}
+			item.Longest = append(item.Longest, OpenAlarm{
+				AlarmID:    a.AlarmId,
+				Code:       a.Code,
+				Level:      a.Level,
+				Host:       a.Resource.Host,
+				Content:    a.AlarmContent,
+				CreateTime: a.CreateTime,
+				OpenFor:    openFor(now, a.CreateTime),
+			})
+		}
+	}
+
+	// 同一告警推送失败重试会重复记录trigger，按告警去重
+	triggered := make(map[string]bool)
+	resolved := make(map[string]bool)
+	for _, ev := range events {
+		key := ev.Key()
+		switch ev.Action {
+		case history.ActionTrigger:
+			if !triggered[key] {
+				triggered[key] = true
+				get(ev.Insight).New24h++
+			}
+		case history.ActionResolve:
+			if !resolved[key] {
+				resolved[key] = true
+				get(ev.Insight).Resolved++
+			}
+		}
+	}
+
+	for _, item := range byInsight {
+		d.Insights = append(d.Insights, *item)
+	}
+	sort.Slice(d.Insights, func(i, j int) bool { return d.Insights[i].Insight < d.Insights[j].Insight })
+	return d
+}
+
+func openFor(now time.Time, createTime string) string {
+	t, err := time.ParseInLocation(timeLayout, createTime, time.Local)
+	if err != nil {
+		return ""
+	}
+	return now.Sub(t).Round(time.Minute).String()
+}
+
+// Text 以纯文本（Markdown）形式输出摘要，用于邮件和文件
+func (d *Digest) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# GoldenDB 告警摘要 %s\n\n", d.GeneratedAt)
+	if len(d.Insights) == 0 {
+		fmt.Fprintf(&b, "当前没有活动告警。\n")
+	}
+	for _, item := range d.Insights {
+		fmt.Fprintf(&b, "## %s\n\n", item.Insight)
+		fmt.Fprintf(&b, "- 当前活动告警: %d\n", item.Open)
+		for _, lc := range item.ByLevel {
+			fmt.Fprintf(&b, "  - %s(%d): %d\n", lc.Name, lc.Level, lc.Count)
+		}
+		if d.History {
+			fmt.Fprintf(&b, "- 最近24小时: 新增 %d，恢复 %d\n", item.New24h, item.Resolved)
+		} else {
+			fmt.Fprintf(&b, "- 最近24小时: 未启用告警历史，无法统计\n")
+		}
+		if len(item.Longest) > 0 {
+			fmt.Fprintf(&b, "\n| 告警ID | 代码 | 级别 | 主机 | 创建时间 | 持续 | 内容 |\n|---|---|---|---|---|---|---|\n")
+			for _, a := range item.Longest {
+				fmt.Fprintf(&b, "| %d | %d | %d | %s | %s | %s | %s |\n", a.AlarmID, a.Code, a.Level, a.Host, a.CreateTime, a.OpenFor, a.Content)
+			}
+		}
+		fmt.Fprintln(&b)
+	}
+	return b.String()
+}
@@ -0,0 +1,200 @@
+package digest
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/api"
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"GoldenDB/history"
+	"GoldenDB/log"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var logger *log.Logger
+
+func SetLogger(l *log.Logger) {
+	logger = l
+}
+
+// Scheduler 按配置时间定时生成并发送告警摘要
+type Scheduler struct {
+	cfg    config.Digest
+	client *api.Client    // webhook方式的推送客户端
+	store  *history.Store // 为nil时不统计最近24小时
+	times  []clock
+	from   *mail.Address // email方式的发件人
+}
+
+type clock struct {
+	hour, minute int
+}
+
+// NewScheduler 校验配置并创建调度器
+func NewScheduler(cfg config.Digest, store *history.Store) (*Scheduler, error) {
+	s := &Scheduler{cfg: cfg, store: store}
+	for _, v := range cfg.Times {
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			return nil, fmt.Errorf("摘要发送时间格式错误 %q: %w", v, err)
+		}
+		s.times = append(s.times, clock{hour: t.Hour(), minute: t.Minute()})
+	}
+	if len(s.times) == 0 {
+		return nil, fmt.Errorf("未配置摘要发送时间")
+	}
+	sort.Slice(s.times, func(i, j int) bool {
+		return s.times[i].hour*60+s.times[i].minute < s.times[j].hour*60+s.times[j].minute
+	})
+	switch cfg.Type {
+	case "webhook":
+		client, err := api.NewClient(cfg.Webhook, cfg.HTTP)
+		if err != nil {
+			return nil, fmt.Errorf("摘要webhook配置错误: %w", err)
+		}
+		s.client = client
+	case "email":
+		if cfg.Email.Host == "" || len(cfg.Email.To) == 0 {
+			return nil, fmt.Errorf("摘要发送方式为email但未配置SMTP服务器或收件人")
+		}
+		// 未配置发件人时，SMTP用户名是邮件地址才用作发件人
+		for _, v := range []string{cfg.Email.From, cfg.Email.Username} {
+			if addr, err := mail.ParseAddress(v); v != "" && err == nil {
+				s.from = addr
+				break
+			}
+		}
+		if s.from == nil {
+			return nil, fmt.Errorf("摘要邮件的发件人 %q 和SMTP用户名 %q 都不是有效的邮件地址", cfg.Email.From, cfg.Email.Username)
+		}
+	case "file":
+	default:
+		return nil, fmt.Errorf("不支持的摘要发送方式: %s", cfg.Type)
+	}
+	return s, nil
+}
+
+// next 返回now之后最近的发送时间
+func (s *Scheduler) next(now time.Time) time.Time {
+	for day := 0; day <= 1; day++ {
+		for _, c := range s.times {
+			t := time.Date(now.Year(), now.Month(), now.Day()+day, c.hour, c.minute, 0, 0, now.Location())
+			if t.After(now) {
+				return t
+			}
+		}
+	}
+	return now.Add(24 * time.Hour)
+}
+
+// Run 阻塞运行调度循环，ctx结束时返回
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		at := s.next(time.Now())
+		if logger != nil {
+			logger.Info("下一次告警摘要发送时间: %s", at.Format(timeLayout))
+		}
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.Send(time.Now()); err != nil {
+			if logger != nil {
+				logger.Error("发送告警摘要失败: %v", err)
+			}
+			continue
+		}
+		if logger != nil {
+			logger.Info("告警摘要已发送 (%s)", s.cfg.Type)
+		}
+	}
+}
+
+// Send 立即生成并发送一次摘要
+func (s *Scheduler) Send(now time.Time) error {
+	var events []history.Event
+	if s.store != nil {
+		var err error
+		events, err = s.store.Query(history.Query{From: now.Add(-24 * time.Hour), To: now})
+		if err != nil {
+			return err
+		}
+		if events == nil {
+			events = []history.Event{}
+		}
+	}
+	d := Build(now, alarm.ActiveAlarms(), events, s.cfg.Top)
+
+	switch s.cfg.Type {
+	case "webhook":
+		return s.sendWebhook(d)
+	case "email":
+		return s.sendEmail(d)
+	default:
+		return s.writeFile(d, now)
+	}
+}
+
+func (s *Scheduler) sendWebhook(d *Digest) error {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("marshal digest error: %w", err)
+	}
+	status, _, err := s.client.PostJSON(body)
+	if err != nil {
+		return fmt.Errorf("send digest error: %w", err)
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("send digest failed, http code %d", status)
+	}
+	return nil
+}
+
+func (s *Scheduler) sendEmail(d *Digest) error {
+	e := s.cfg.Email
+	var auth smtp.Auth
+	if e.Username != "" {
+		password, err := connect.Decrypt(e.Password)
+		if err != nil {
+			return fmt.Errorf("解密SMTP密码失败: %w", err)
+		}
+		auth = smtp.PlainAuth("", e.Username, password, e.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	// 主题含中文，按 RFC 2047 编码
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", "GoldenDB 告警摘要 "+d.GeneratedAt))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(d.Text(), "\n", "\r\n"))
+
+	addr := fmt.Sprintf("%s:%d", e.Host, e.Port)
+	if err := smtp.SendMail(addr, auth, s.from.Address, e.To, []byte(msg.String())); err != nil {
+		return fmt.Errorf("发送摘要邮件失败: %w", err)
+	}
+	return nil
+}
+
+func (s *Scheduler) writeFile(d *Digest, now time.Time) error {
+	if err := os.MkdirAll(s.cfg.Dir, 0755); err != nil {
+		return fmt.Errorf("创建摘要目录失败: %w", err)
+	}
+	name := filepath.Join(s.cfg.Dir, "digest-"+now.Format("20060102-1504")+".md")
+	if err := os.WriteFile(name, []byte(d.Text()), 0644); err != nil {
+		return fmt.Errorf("写入摘要文件失败: %w", err)
+	}
+	return nil
+}
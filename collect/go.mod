@@ -1,9 +1,15 @@
 module GoldenDB
 
-go 1.24
+go 1.24.0
 
 require github.com/go-sql-driver/mysql v1.7.1 // 声明依赖官方驱动
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	golang.org/x/crypto v0.48.0
+	golang.org/x/term v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.41.0 // indirect
 
 replace github.com/go-sql-driver/mysql v1.7.1 => ./mysql-master
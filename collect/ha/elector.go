@@ -0,0 +1,108 @@
+package ha
+
+import (
+	"GoldenDB/log"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+var logger *log.Logger
+
+func SetLogger(l *log.Logger) {
+	logger = l
+}
+
+// Elector 主备选举：持有锁的实例为主，其余实例每隔interval重试获取锁。
+// 主实例每隔interval检查一次锁，失去锁或检查失败时立即退位，
+// 因此备实例接管时间不超过 interval 加上锁被释放所需的时间。
+type Elector struct {
+	locker   Locker
+	interval time.Duration
+	leader   atomic.Bool
+}
+
+// NewElector 创建选举器
+func NewElector(locker Locker, interval time.Duration) *Elector {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Elector{locker: locker, interval: interval}
+}
+
+// IsLeader 当前实例是否为主。失去锁后立即返回false，lead仍在退出时也是如此
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run 参与选举直到ctx结束。成为主时在新协程中调用lead，
+// 失去主身份时取消传给lead的ctx并等待其返回后再重新参与选举。
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	defer e.locker.Release()
+	for ctx.Err() == nil {
+		if !e.tryLock(ctx) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(e.interval):
+			}
+			continue
+		}
+
+		e.leader.Store(true)
+		if logger != nil {
+			logger.Info("已成为主实例，开始推送告警")
+		}
+		leaderCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			lead(leaderCtx)
+		}()
+
+		if e.hold(leaderCtx, done) {
+			// 失去锁后新的主实例可能已接管，lead在退出期间不应再写共享状态
+			e.leader.Store(false)
+		}
+		cancel()
+		<-done
+		e.leader.Store(false)
+		e.locker.Release()
+		if logger != nil {
+			logger.Warn("已退为备实例")
+		}
+	}
+}
+
+func (e *Elector) tryLock(ctx context.Context) bool {
+	lockCtx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+	ok, err := e.locker.TryLock(lockCtx)
+	if err != nil && logger != nil {
+		logger.Error("获取主锁失败: %v", err)
+	}
+	return ok
+}
+
+// hold 定期检查锁，直到失去锁、ctx结束或lead提前返回，失去锁时返回true
+func (e *Elector) hold(ctx context.Context, done <-chan struct{}) bool {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-done:
+			return false
+		case <-ticker.C:
+			checkCtx, cancel := context.WithTimeout(ctx, e.interval)
+			held, err := e.locker.Held(checkCtx)
+			cancel()
+			if err != nil || !held {
+				if logger != nil {
+					logger.Error("主锁已丢失: %v", err)
+				}
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package ha
+
+import (
+	"GoldenDB/connect"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Locker 分布式锁，持有锁的实例为主
+type Locker interface {
+	// TryLock 尝试获取锁，不阻塞
+	TryLock(ctx context.Context) (bool, error)
+	// Held 检查锁是否仍由本实例持有
+	Held(ctx context.Context) (bool, error)
+	// Release 释放锁
+	Release()
+}
+
+// MySQLTarget 可用于加锁的一个MDS
+type MySQLTarget struct {
+	Name string
+	Node connect.Node
+}
+
+// mysqlLocker 基于MDS的 GET_LOCK 实现，在超过半数的MDS上都获得锁才为主，
+// 任意时刻至多一个实例持有多数，MDS故障或网络分区时不会出现两个实例各自在不同MDS上持锁。
+// 每把锁绑定在单独建立的会话上，不占用采集用的连接池，会话断开（实例退出或网络中断）后由MySQL自动释放
+type mysqlLocker struct {
+	targets []MySQLTarget
+	name    string
+	timeout time.Duration
+	pools   *connect.Factory
+	conns   []*sql.Conn // 与targets对应，未持有该MDS上的锁时为nil
+}
+
+// NewMySQLLocker 在targets的多数MDS上使用名为name的用户锁。锁会话的 wait_timeout
+// 设为timeout，主实例所在主机宕机时MDS最迟在timeout后断开会话并释放锁
+func NewMySQLLocker(targets []MySQLTarget, name string, timeout time.Duration) Locker {
+	return &mysqlLocker{
+		targets: targets,
+		name:    name,
+		timeout: timeout,
+		pools:   connect.NewFactory(),
+		conns:   make([]*sql.Conn, len(targets)),
+	}
+}
+
+// quorum 成为主需要持锁的MDS数量
+func (l *mysqlLocker) quorum() int {
+	return len(l.targets)/2 + 1
+}
+
+// TryLock 在各MDS上加锁，未达到多数时释放已获得的锁，避免两个实例各持一部分而都无法成为主
+func (l *mysqlLocker) TryLock(ctx context.Context) (bool, error) {
+	l.reset()
+	held, contested, errs := l.lockAll(ctx)
+	if held >= l.quorum() {
+		if logger != nil {
+			logger.Info("已在%d/%d个MDS上获得主锁", held, len(l.targets))
+		}
+		return true, nil
+	}
+	l.reset()
+	if contested {
+		// 锁由其它实例持有
+		return false, nil
+	}
+	return false, fmt.Errorf("可加锁的MDS不足%d个: %s", l.quorum(), strings.Join(errs, "; "))
+}
+
+// Held 检查各锁会话，并在此前未持锁的MDS（如已恢复连接的MDS）上补加锁，仍持有多数时返回true
+func (l *mysqlLocker) Held(ctx context.Context) (bool, error) {
+	for i, conn := range l.conns {
+		if conn == nil {
+			continue
+		}
+		var held sql.NullInt64
+		err := conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", l.name).Scan(&held)
+		if err != nil || !held.Valid || held.Int64 != 1 {
+			if err != nil && logger != nil {
+				logger.Warn("检查MDS %s 上的主锁失败: %v", l.targets[i].Name, err)
+			}
+			conn.Close()
+			l.conns[i] = nil
+		}
+	}
+	held, _, errs := l.lockAll(ctx)
+	if held < l.quorum() {
+		l.reset()
+		if len(errs) > 0 {
+			return false, fmt.Errorf("持锁的MDS不足%d个: %s", l.quorum(), strings.Join(errs, "; "))
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// lockAll 在尚未持锁的MDS上加锁，返回持锁的MDS数量、是否有锁由其它实例持有以及各MDS的错误
+func (l *mysqlLocker) lockAll(ctx context.Context) (held int, contested bool, errs []string) {
+	for i, t := range l.targets {
+		if l.conns[i] != nil {
+			held++
+			continue
+		}
+		conn, err := l.lock(ctx, t)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("%s: %v", t.Name, err))
+		case conn == nil:
+			contested = true
+		default:
+			l.conns[i] = conn
+			held++
+		}
+	}
+	return held, contested, errs
+}
+
+// lock 在单独的会话上加锁，锁由其它实例持有时返回nil
+func (l *mysqlLocker) lock(ctx context.Context, t MySQLTarget) (*sql.Conn, error) {
+	conn, err := l.open(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", l.name).Scan(&got); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("GET_LOCK失败: %w", err)
+	}
+	if !got.Valid || got.Int64 != 1 {
+		conn.Close()
+		return nil, nil
+	}
+	return conn, nil
+}
+
+// open 从锁专用的连接池获取一个会话并设置超时
+func (l *mysqlLocker) open(ctx context.Context, t MySQLTarget) (*sql.Conn, error) {
+	node := t.Node
+	// 不保留空闲连接，会话归还即断开，其上的锁随之释放；持锁期间不因连接最长使用时间被替换
+	node.Conn.MaxOpen, node.Conn.MaxIdle, node.Conn.MaxLifetime = 1, 0, 0
+	db, err := l.pools.Open(node)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取锁连接失败: %w", err)
+	}
+	if l.timeout > 0 {
+		seconds := int(l.timeout.Seconds())
+		if _, err := conn.ExecContext(ctx, "SET SESSION wait_timeout = ?", seconds); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("设置锁会话超时失败: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (l *mysqlLocker) Release() {
+	for _, conn := range l.conns {
+		if conn != nil {
+			conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", l.name)
+		}
+	}
+	l.reset()
+	l.pools.Close()
+}
+
+// reset 关闭锁会话，会话上的锁随之释放
+func (l *mysqlLocker) reset() {
+	for i, conn := range l.conns {
+		if conn != nil {
+			conn.Close()
+			l.conns[i] = nil
+		}
+	}
+}
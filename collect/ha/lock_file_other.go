@@ -0,0 +1,25 @@
+//go:build !unix
+
+package ha
+
+import (
+	"context"
+	"fmt"
+)
+
+type fileLocker struct{}
+
+// NewFileLocker 当前平台不支持文件锁
+func NewFileLocker(path string) Locker {
+	return fileLocker{}
+}
+
+func (fileLocker) TryLock(ctx context.Context) (bool, error) {
+	return false, fmt.Errorf("当前平台不支持文件锁")
+}
+
+func (fileLocker) Held(ctx context.Context) (bool, error) {
+	return false, nil
+}
+
+func (fileLocker) Release() {}
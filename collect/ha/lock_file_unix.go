@@ -0,0 +1,67 @@
+//go:build unix
+
+package ha
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileLocker 基于共享文件的 flock 实现，文件需放在各实例都能访问的共享存储上
+type fileLocker struct {
+	path string
+	file *os.File
+}
+
+// NewFileLocker 使用path文件作为锁
+func NewFileLocker(path string) Locker {
+	return &fileLocker{path: path}
+}
+
+func (l *fileLocker) TryLock(ctx context.Context) (bool, error) {
+	if l.file != nil {
+		return l.Held(ctx)
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false, fmt.Errorf("打开锁文件失败: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, fmt.Errorf("锁定文件失败: %w", err)
+	}
+	l.file = file
+	return true, nil
+}
+
+// Held 锁文件被删除或替换时视为失去锁
+func (l *fileLocker) Held(ctx context.Context) (bool, error) {
+	if l.file == nil {
+		return false, nil
+	}
+	held, err := l.file.Stat()
+	if err != nil {
+		l.Release()
+		return false, fmt.Errorf("检查锁文件失败: %w", err)
+	}
+	current, err := os.Stat(l.path)
+	if err != nil || !os.SameFile(held, current) {
+		l.Release()
+		return false, nil
+	}
+	return true, nil
+}
+
+func (l *fileLocker) Release() {
+	if l.file == nil {
+		return
+	}
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	l.file.Close()
+	l.file = nil
+}
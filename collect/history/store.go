@@ -0,0 +1,266 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 告警生命周期动作
+const (
+	ActionCollected      = "collected"       // 采集到告警
+	ActionTrigger        = "trigger"         // 新增告警，准备推送
+	ActionUpdate         = "update"          // 已推送告警的级别或内容发生变化
+	ActionResolve        = "resolve"         // 告警消失，准备推送恢复
+	ActionFiltered       = "filtered"        // 被过滤规则丢弃
+	ActionSilenced       = "silenced"        // 被屏蔽规则屏蔽，不推送
+	ActionDelivered      = "delivered"       // 推送成功
+	ActionDeliveryFailed = "delivery_failed" // 推送失败
+)
+
+const fileTimeLayout = "20060102"
+
+// Event 一条告警历史记录
+type Event struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	Insight     string    `json:"insight"`
+	Cluster     string    `json:"cluster"`
+	AlarmID     int       `json:"alarm_id"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Code        int       `json:"code"`
+	Level       int       `json:"level"`
+	Host        string    `json:"host"`
+	Content     string    `json:"content"`
+	CreateTime  string    `json:"create_time"`
+	Detail      string    `json:"detail,omitempty"`
+}
+
+// Key 告警的唯一标识，旧记录没有指纹时使用insight和原始告警ID
+func (ev Event) Key() string {
+	if ev.Fingerprint != "" {
+		return ev.Fingerprint
+	}
+	return fmt.Sprintf("%s|%d", ev.Insight, ev.AlarmID)
+}
+
+// Query 历史查询条件，零值字段表示不限制
+type Query struct {
+	From    time.Time
+	To      time.Time
+	Cluster string // 匹配insight名称或GoldenDB集群名
+	Code    int
+	Level   int
+	Action  string
+}
+
+// Store 本地告警历史存储，每天一个 JSON Lines 文件
+type Store struct {
+	mu       sync.Mutex
+	dir      string
+	keepDays int
+	day      string
+	file     *os.File
+}
+
+// Open 打开（必要时创建）历史存储目录
+func Open(dir string, keepDays int) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建历史目录失败: %w", err)
+	}
+	return &Store{dir: dir, keepDays: keepDays}, nil
+}
+
+// Record 追加写入历史事件，可并发调用
+func (s *Store) Record(events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ev := range events {
+		if ev.Time.IsZero() {
+			ev.Time = time.Now()
+		}
+		if err := s.rotate(ev.Time); err != nil {
+			return err
+		}
+		line, err := json.Marshal(ev)
+		if err != nil {
+			return fmt.Errorf("序列化历史事件失败: %w", err)
+		}
+		if _, err := s.file.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("写入历史文件失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// rotate 按事件日期切换写入文件
+func (s *Store) rotate(t time.Time) error {
+	day := t.Format(fileTimeLayout)
+	if s.file != nil && s.day == day {
+		return nil
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+	file, err := os.OpenFile(s.fileName(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开历史文件失败: %w", err)
+	}
+	s.file = file
+	s.day = day
+	return nil
+}
+
+func (s *Store) fileName(day string) string {
+	return filepath.Join(s.dir, "alarm-"+day+".jsonl")
+}
+
+// Query 按条件查询历史事件，结果按时间升序
+func (s *Store) Query(q Query) ([]Event, error) {
+	days, err := s.days()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Event
+	for _, day := range days {
+		t, _ := time.ParseInLocation(fileTimeLayout, day, time.Local)
+		if !q.From.IsZero() && t.AddDate(0, 0, 1).Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && t.After(q.To) {
+			continue
+		}
+		events, err := readFile(s.fileName(day))
+		if err != nil {
+			return nil, err
+		}
+		for _, ev := range events {
+			if q.match(ev) {
+				result = append(result, ev)
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
+
+func (q Query) match(ev Event) bool {
+	if !q.From.IsZero() && ev.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && ev.Time.After(q.To) {
+		return false
+	}
+	if q.Cluster != "" && ev.Insight != q.Cluster && ev.Cluster != q.Cluster {
+		return false
+	}
+	if q.Code != 0 && ev.Code != q.Code {
+		return false
+	}
+	if q.Level != 0 && ev.Level != q.Level {
+		return false
+	}
+	if q.Action != "" && ev.Action != q.Action {
+		return false
+	}
+	return true
+}
+
+// Clean 删除超过保留天数的历史文件，返回删除的文件数
+func (s *Store) Clean() (int, error) {
+	if s.keepDays <= 0 {
+		return 0, nil
+	}
+	days, err := s.days()
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -s.keepDays).Format(fileTimeLayout)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, day := range days {
+		if day >= cutoff || day == s.day {
+			continue
+		}
+		if err := os.Remove(s.fileName(day)); err != nil {
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// Close 关闭当前写入文件
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// days 返回目录下所有历史文件的日期，升序
+func (s *Store) days() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史目录失败: %w", err)
+	}
+	var days []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "alarm-") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		day := strings.TrimSuffix(strings.TrimPrefix(name, "alarm-"), ".jsonl")
+		if _, err := time.Parse(fileTimeLayout, day); err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+func readFile(name string) ([]Event, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("打开历史文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// 跳过写了一半的行
+			continue
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取历史文件失败: %w", err)
+	}
+	return events, nil
+}
@@ -1,6 +1,9 @@
 package info
 
 import (
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"context"
 	"database/sql"
 	"fmt"
 )
@@ -47,69 +50,130 @@ type Reserve4 struct {
 	RecoveryFlag   int    `json:"recoveryFlag"`
 }
 
-func GetClusterInfo(mds *sql.DB) []Cluster {
-	// 重构
-	GetClusterIDSQL := "select cluster_id,cluster_name,issingle from mds.cluster_info where cluster_id <> '1024';"
-	ClusterList := []Cluster{}
-	rows, err := mds.Query(GetClusterIDSQL)
+// DNInfo mds.db_info 中登记的一个DN
+type DNInfo struct {
+	ClusterID string
+	GroupID   string
+	DBID      string
+	Host      string
+	Port      int
+	Role      int // db_role: 0 主, 1 备
+}
+
+// DN角色
+const (
+	RoleMaster = 0
+	RoleSlave  = 1
+)
+
+// RoleName 角色名称
+func (d DNInfo) RoleName() string {
+	switch d.Role {
+	case RoleMaster:
+		return "master"
+	case RoleSlave:
+		return "slave"
+	}
+	return fmt.Sprintf("role%d", d.Role)
+}
+
+// Node 使用给定的用户、密码和连接设置生成DN的连接信息
+func (d DNInfo) Node(user string, password string, conn config.DBConn) connect.Node {
+	return connect.Node{
+		Name:     fmt.Sprintf("DN %s:%d", d.Host, d.Port),
+		Host:     d.Host,
+		Port:     d.Port,
+		User:     user,
+		Password: password,
+		Conn:     conn,
+	}
+}
+
+// ListClusters 查询MDS管理的全部集群
+func ListClusters(ctx context.Context, mds *sql.DB) ([]Cluster, error) {
+	rows, err := mds.QueryContext(ctx, "select cluster_id,cluster_name,issingle from mds.cluster_info where cluster_id <> '1024' order by cluster_id")
 	if err != nil {
-		fmt.Println("GetClusterID Query Error:", err)
+		return nil, fmt.Errorf("查询集群失败: %w", err)
 	}
 	defer rows.Close()
+	clusters := []Cluster{}
 	for rows.Next() {
-		var ClusterID string
-		var ClusterName string
+		var c Cluster
 		var single int
-		err := rows.Scan(&ClusterID, &ClusterName, &single)
-		if err != nil {
-			fmt.Println("GetClusterID Scan Error:", err)
+		if err := rows.Scan(&c.ClusterID, &c.ClusterName, &single); err != nil {
+			return nil, fmt.Errorf("读取集群失败: %w", err)
 		}
-		ClusterList = append(ClusterList, Cluster{ClusterName: ClusterName, ClusterID: ClusterID, Issingle: (single == 1)})
+		c.Issingle = single == 1
+		clusters = append(clusters, c)
 	}
-	return ClusterList
+	return clusters, rows.Err()
+}
 
+// ListDNs 查询集群的全部DN，按DN组和角色排列
+func ListDNs(ctx context.Context, mds *sql.DB, clusterid string) ([]DNInfo, error) {
+	rows, err := mds.QueryContext(ctx, "select group_id, db_id, db_ip, db_port, db_role from mds.db_info where cluster_id=? order by group_id, db_role, db_id", clusterid)
+	if err != nil {
+		return nil, fmt.Errorf("查询集群 %s 的DN失败: %w", clusterid, err)
+	}
+	defer rows.Close()
+	dns := []DNInfo{}
+	for rows.Next() {
+		d := DNInfo{ClusterID: clusterid}
+		if err := rows.Scan(&d.GroupID, &d.DBID, &d.Host, &d.Port, &d.Role); err != nil {
+			return nil, fmt.Errorf("读取集群 %s 的DN失败: %w", clusterid, err)
+		}
+		dns = append(dns, d)
+	}
+	return dns, rows.Err()
 }
-func GetMasterDN(mds *sql.DB, clusterid string, user string, password string) []string {
-	sqlstr := "select db_ip, db_port from mds.db_info where cluster_id=? and db_role=0"
-	DSNList := []string{}
-	rows, err := mds.Query(sqlstr, clusterid)
+
+// ListSchemas 查询集群的业务库
+func ListSchemas(ctx context.Context, mds *sql.DB, clusterid string) ([]string, error) {
+	rows, err := mds.QueryContext(ctx, "select database_name from mds.dictionary_info where type=1 and cluster_id=? and database_name not in ('_gdb_sysdb','heartbeat_info','processlist','sys') order by database_name", clusterid)
 	if err != nil {
-		fmt.Println("GetDNinfo Query Error:", err)
-		return DSNList
+		return nil, fmt.Errorf("查询集群 %s 的Schema失败: %w", clusterid, err)
 	}
 	defer rows.Close()
+	schemas := []string{}
 	for rows.Next() {
-		var dbip string
-		var dbport int
-		err := rows.Scan(&dbip, &dbport)
-		if err != nil {
-			fmt.Println("GetDNinfo Scan Error:", err)
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("读取集群 %s 的Schema失败: %w", clusterid, err)
 		}
-		dsn := user + ":" + password + "@tcp(" +
-			dbip + ":" + fmt.Sprintf("%d", dbport) + ")/" +
-			"" + "?loadbalance=false&blacklist=-1"
-		DSNList = append(DSNList, dsn)
+		schemas = append(schemas, name)
 	}
-	return DSNList
+	return schemas, rows.Err()
+}
 
+func GetClusterInfo(mds *sql.DB) []Cluster {
+	ClusterList, err := ListClusters(context.Background(), mds)
+	if err != nil {
+		fmt.Println("GetClusterID Query Error:", err)
+	}
+	return ClusterList
+}
+
+// GetMasterDN 返回集群各主DN的连接信息，使用给定的用户、密码和连接设置
+func GetMasterDN(mds *sql.DB, clusterid string, user string, password string, conn config.DBConn) []connect.Node {
+	DSNList := []connect.Node{}
+	dns, err := ListDNs(context.Background(), mds, clusterid)
+	if err != nil {
+		fmt.Println("GetDNinfo Query Error:", err)
+		return DSNList
+	}
+	for _, d := range dns {
+		if d.Role == RoleMaster {
+			DSNList = append(DSNList, d.Node(user, password, conn))
+		}
+	}
+	return DSNList
 }
+
 func GetSchema(mds *sql.DB, id string) []string {
 	// id 是cluster_id
-	sqlstr := "select database_name from mds.dictionary_info where type=1 and cluster_id=? and database_name not in ('_gdb_sysdb','heartbeat_info','processlist','sys')"
-	SchemaList := []string{}
-	rows, err := mds.Query(sqlstr, id)
+	SchemaList, err := ListSchemas(context.Background(), mds, id)
 	if err != nil {
 		fmt.Println("GetSchema Query Error:", err)
-		return SchemaList
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var schemaname string
-		err := rows.Scan(&schemaname)
-		if err != nil {
-			fmt.Println(err)
-		}
-		SchemaList = append(SchemaList, schemaname)
 	}
 	return SchemaList
 }
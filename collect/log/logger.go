@@ -1,116 +1,239 @@
 package log
 
 import (
+	"GoldenDB/redact"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 )
 
+// Level 日志等级
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelWarn:  "WARN",
+	LevelError: "ERROR",
+}
+
+func (lv Level) String() string {
+	return levelNames[lv]
+}
+
+// ParseLevel 解析配置中的日志等级，不区分大小写，空字符串视为INFO
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return LevelDebug, nil
+	case "", "INFO":
+		return LevelInfo, nil
+	case "WARN", "WARNING":
+		return LevelWarn, nil
+	case "ERROR":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("未知日志等级: %s", s)
+}
+
+// 日志输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json" // 每行一个JSON对象
+)
+
+// Options 日志选项
+type Options struct {
+	Level  string // DEBUG | INFO | WARN | ERROR
+	Format string // text | json
+	Stdout bool   // 同时输出到标准输出
+
+	MaxSize    int64 // 单个日志文件最大字节数，超过后切分，0表示不按大小切分
+	Daily      bool  // 每天切分一次
+	MaxBackups int   // 保留的切分文件数量，0表示不限制
+	MaxAge     int   // 切分文件保留天数，0表示不限制
+	Compress   bool  // 切分后的文件用gzip压缩
+}
+
+// field 结构化日志字段
+type field struct {
+	key   string
+	value interface{}
+}
+
 // Logger 日志结构体
 type Logger struct {
-	file     *os.File
+	out      *output
 	filePath string
 	module   string
+	level    Level
+	json     bool
+	fields   []field
 }
 
-// NewLogger 创建日志实例
+// NewLogger 创建日志实例，记录INFO及以上等级的文本日志
 func NewLogger(module string, filePath string) (*Logger, error) {
-	// 确保目录存在
-	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, fmt.Errorf("创建日志目录失败: %w", err)
-	}
+	return New(module, filePath, Options{})
+}
 
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// New 按选项创建日志实例
+func New(module string, filePath string, opts Options) (*Logger, error) {
+	level, err := ParseLevel(opts.Level)
 	if err != nil {
-		return nil, fmt.Errorf("打开日志文件失败: %w", err)
+		return nil, err
+	}
+	if opts.Format != "" && opts.Format != FormatText && opts.Format != FormatJSON {
+		return nil, fmt.Errorf("未知日志格式: %s", opts.Format)
 	}
 
+	out, err := openOutput(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	go out.flushLoop()
 	return &Logger{
-		file:     file,
+		out:      out,
 		filePath: filePath,
 		module:   module,
+		level:    level,
+		json:     opts.Format == FormatJSON,
 	}, nil
 }
 
+// With 返回附带字段的日志实例，参数为键值对，如 With("mds", name, "alarm_id", id)。
+// 常用字段: mds, alarm_id, code, sink；返回的实例与原实例共享输出
+func (l *Logger) With(keyValues ...interface{}) *Logger {
+	child := *l
+	child.fields = append(make([]field, 0, len(l.fields)+len(keyValues)/2), l.fields...)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		child.fields = append(child.fields, field{key: fmt.Sprint(keyValues[i]), value: redactValue(keyValues[i+1])})
+	}
+	return &child
+}
+
+// redactValue 遮盖字符串和错误类型字段中的敏感内容，数字等其它类型原样保留
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redact.String(v)
+	case error:
+		return redact.Error(v)
+	case fmt.Stringer:
+		return redact.String(v.String())
+	}
+	return value
+}
+
+// Enabled 该等级的日志是否会被记录，用于避免构造不会输出的日志内容
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
 // log 写入日志
-func (l *Logger) log(level string, format string, args ...interface{}) {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	message := fmt.Sprintf(format, args...)
-	logLine := fmt.Sprintf("[%s] [%s] [%s] %s\n", timestamp, level, l.module, message)
-	l.file.WriteString(logLine)
-	l.file.Sync()
+func (l *Logger) log(level Level, format string, args ...interface{}) {
+	if level < l.level {
+		return
+	}
+	now := time.Now()
+	// 写入前统一遮盖密码、token等敏感内容，调用方无需逐处处理
+	message := redact.String(fmt.Sprintf(format, args...))
+
+	var line []byte
+	if l.json {
+		line = l.jsonLine(now, level, message)
+	} else {
+		line = l.textLine(now, level, message)
+	}
+	l.out.write(line, level >= LevelError)
+}
+
+func (l *Logger) textLine(now time.Time, level Level, message string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] [%s] [%s] %s", now.Format("2006-01-02 15:04:05"), level, l.module, message)
+	for _, f := range l.fields {
+		fmt.Fprintf(&b, " %s=%v", f.key, f.value)
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+func (l *Logger) jsonLine(now time.Time, level Level, message string) []byte {
+	// 按固定顺序输出字段，便于阅读和按行比对
+	var b bytes.Buffer
+	b.WriteByte('{')
+	writeJSONField(&b, "time", now.Format(time.RFC3339Nano), true)
+	writeJSONField(&b, "level", level.String(), false)
+	writeJSONField(&b, "module", l.module, false)
+	writeJSONField(&b, "msg", message, false)
+	for _, f := range l.fields {
+		writeJSONField(&b, f.key, f.value, false)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func writeJSONField(b *bytes.Buffer, key string, value interface{}, first bool) {
+	if !first {
+		b.WriteByte(',')
+	}
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	b.Write(k)
+	b.WriteByte(':')
+	b.Write(v)
 }
 
 // InfoInfo
 func (l *Logger) Info(format string, args ...interface{}) {
-	l.log("INFO", format, args...)
+	l.log(LevelInfo, format, args...)
 }
 
 // Error 错误日志
 func (l *Logger) Error(format string, args ...interface{}) {
-	l.log("ERROR", format, args...)
+	l.log(LevelError, format, args...)
 }
 
 // Warn 警告日志
 func (l *Logger) Warn(format string, args ...interface{}) {
-	l.log("WARN", format, args...)
+	l.log(LevelWarn, format, args...)
 }
 
 // Debug 调试日志
 func (l *Logger) Debug(format string, args ...interface{}) {
-	l.log("DEBUG", format, args...)
+	l.log(LevelDebug, format, args...)
 }
 
-// Close 关闭日志文件
-func (l *Logger) Close() error {
-	return l.file.Close()
+// Flush 将缓冲区中的日志写入文件
+func (l *Logger) Flush() error {
+	return l.out.flush()
 }
 
-// CleanOldLogs 清理指定天数之前的日志文件
-func CleanOldLogs(logDir string, days int) error {
-	dir, err := os.Open(logDir)
-	if err != nil {
-		return fmt.Errorf("打开日志目录失败: %w", err)
-	}
-	defer dir.Close()
-
-	files, err := dir.Readdirnames(-1)
-	if err != nil {
-		return fmt.Errorf("读取日志目录失败: %w", err)
-	}
-
-	cutoff := time.Now().AddDate(0, 0, -days)
-	deletedCount := 0
-
-	for _, filename := range files {
-		// 只处理 .log 文件
-		if !strings.HasSuffix(filename, ".log") {
-			continue
-		}
-
-		filePath := filepath.Join(logDir, filename)
-		info, err := os.Stat(filePath)
-		if err != nil {
-			continue
-		}
-
-		// 检查文件修改时间
-		if info.ModTime().Before(cutoff) {
-			if err := os.Remove(filePath); err != nil {
-				continue
-			}
-			deletedCount++
-		}
-	}
+// Reopen 重新打开日志文件，供外部logrotate移走文件后使用
+func (l *Logger) Reopen() error {
+	return l.out.reopen()
+}
 
-	if deletedCount > 0 {
-		fmt.Printf("已清理 %d 个过期日志文件\n", deletedCount)
-	}
+// Clean 按保留数量和天数删除切分出的旧日志文件，不会删除正在写入的文件
+func (l *Logger) Clean() error {
+	return l.out.clean()
+}
 
-	return nil
+// Close 写入缓冲区中的日志并关闭日志文件，With派生的实例共享同一文件
+func (l *Logger) Close() error {
+	return l.out.close()
 }
 
 // GetLogFileSize 获取日志文件大小（字节）
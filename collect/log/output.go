@@ -0,0 +1,314 @@
+package log
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// flushInterval 缓冲区定期写入文件的间隔，ERROR日志立即写入
+const flushInterval = time.Second
+
+// backupTimeLayout 切分文件名中的时间，如 app.log 切分为 app-20240501-150405.log
+const backupTimeLayout = "20060102-150405"
+
+// output 多个Logger共享的带缓冲写入器，可并发使用，按大小和日期切分日志文件
+type output struct {
+	mu     sync.Mutex
+	path   string
+	opts   Options
+	file   *os.File
+	buf    *bufio.Writer
+	size   int64  // 当前文件大小（含缓冲区中未写入的部分）
+	day    string // 当前文件对应的日期
+	stdout bool
+	done   chan struct{}
+
+	cleanMu sync.Mutex // 压缩和清理旧文件在后台进行，避免并发执行
+}
+
+func openOutput(path string, opts Options) (*output, error) {
+	// 确保目录存在
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	o := &output{path: path, opts: opts, stdout: opts.Stdout, done: make(chan struct{})}
+	if err := o.open(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// open 打开（必要时创建）日志文件，调用方需持有锁或尚未共享o
+func (o *output) open() error {
+	file, err := os.OpenFile(o.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	o.file = file
+	o.buf = bufio.NewWriterSize(file, 64*1024)
+	o.size = info.Size()
+	// 已有内容的文件按最后修改日期计算，程序跨天重启后也会先切分前一天的日志
+	o.day = time.Now().Format("20060102")
+	if info.Size() > 0 {
+		o.day = info.ModTime().Format("20060102")
+	}
+	return nil
+}
+
+func (o *output) write(line []byte, flush bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.buf == nil {
+		return
+	}
+	if o.shouldRotate(int64(len(line))) {
+		if err := o.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "切分日志失败: %v\n", err)
+		}
+		if o.buf == nil {
+			return
+		}
+	}
+	o.buf.Write(line)
+	o.size += int64(len(line))
+	if o.stdout {
+		os.Stdout.Write(line)
+	}
+	if flush {
+		o.buf.Flush()
+	}
+}
+
+func (o *output) shouldRotate(n int64) bool {
+	if o.opts.MaxSize > 0 && o.size > 0 && o.size+n > o.opts.MaxSize {
+		return true
+	}
+	return o.opts.Daily && o.day != time.Now().Format("20060102")
+}
+
+// rotate 将当前文件重命名为带时间的切分文件并打开新文件，压缩和清理在后台进行
+func (o *output) rotate() error {
+	o.buf.Flush()
+	o.file.Close()
+	o.buf = nil
+
+	backup := o.backupName(time.Now())
+	renameErr := os.Rename(o.path, backup)
+	if err := o.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("重命名日志文件失败: %w", renameErr)
+	}
+	go o.afterRotate(backup)
+	return nil
+}
+
+// backupName 切分文件名，同一秒内多次切分时追加序号
+func (o *output) backupName(t time.Time) string {
+	dir, prefix, ext := o.nameParts()
+	name := filepath.Join(dir, prefix+t.Format(backupTimeLayout)+ext)
+	for i := 1; ; i++ {
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		name = filepath.Join(dir, fmt.Sprintf("%s%s.%d%s", prefix, t.Format(backupTimeLayout), i, ext))
+	}
+}
+
+// nameParts 返回目录、切分文件名前缀（如 "app-"）和扩展名（如 ".log"）
+func (o *output) nameParts() (string, string, string) {
+	base := filepath.Base(o.path)
+	ext := filepath.Ext(base)
+	return filepath.Dir(o.path), strings.TrimSuffix(base, ext) + "-", ext
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func (o *output) afterRotate(backup string) {
+	if o.opts.Compress {
+		if err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "压缩日志失败: %v\n", err)
+		}
+	}
+	if err := o.clean(); err != nil {
+		fmt.Fprintf(os.Stderr, "清理日志失败: %v\n", err)
+	}
+}
+
+// compressFile 将name压缩为name.gz并删除原文件
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// backup 切分出的旧日志文件
+type backup struct {
+	path string
+	time time.Time
+	seq  int // 同一秒内的切分序号
+}
+
+// backups 返回当前日志文件切分出的所有旧文件，按时间从新到旧排列
+func (o *output) backups() ([]backup, error) {
+	dir, prefix, ext := o.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+	var list []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		switch {
+		case strings.HasSuffix(stamp, ext+".gz"):
+			stamp = strings.TrimSuffix(stamp, ext+".gz")
+		case strings.HasSuffix(stamp, ext):
+			stamp = strings.TrimSuffix(stamp, ext)
+		default:
+			continue
+		}
+		// 同一秒内多次切分的文件带有序号
+		seq := 0
+		if i := strings.IndexByte(stamp, '.'); i >= 0 {
+			n, err := strconv.Atoi(stamp[i+1:])
+			if err != nil {
+				continue
+			}
+			stamp, seq = stamp[:i], n
+		}
+		t, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		list = append(list, backup{path: filepath.Join(dir, name), time: t, seq: seq})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].time.Equal(list[j].time) {
+			return list[i].time.After(list[j].time)
+		}
+		return list[i].seq > list[j].seq
+	})
+	return list, nil
+}
+
+// clean 按 MaxBackups 和 MaxAge 删除旧的切分文件
+func (o *output) clean() error {
+	if o.opts.MaxBackups <= 0 && o.opts.MaxAge <= 0 {
+		return nil
+	}
+	o.cleanMu.Lock()
+	defer o.cleanMu.Unlock()
+
+	list, err := o.backups()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().AddDate(0, 0, -o.opts.MaxAge)
+	for i, b := range list {
+		if (o.opts.MaxBackups > 0 && i >= o.opts.MaxBackups) || (o.opts.MaxAge > 0 && b.time.Before(cutoff)) {
+			os.Remove(b.path)
+		}
+	}
+	return nil
+}
+
+func (o *output) flush() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.buf == nil {
+		return nil
+	}
+	return o.buf.Flush()
+}
+
+// reopen 关闭并重新打开日志文件，外部logrotate重命名文件后调用
+func (o *output) reopen() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.buf == nil {
+		return nil
+	}
+	o.buf.Flush()
+	o.file.Close()
+	o.buf = nil
+	return o.open()
+}
+
+// flushLoop 定期将缓冲区写入文件
+func (o *output) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-ticker.C:
+			o.flush()
+		}
+	}
+}
+
+func (o *output) close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	select {
+	case <-o.done:
+		return nil
+	default:
+	}
+	close(o.done)
+	if o.buf == nil {
+		return nil
+	}
+	o.buf.Flush()
+	o.buf = nil
+	o.file.Sync()
+	return o.file.Close()
+}
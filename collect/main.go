@@ -2,58 +2,119 @@ package main
 
 import (
 	"GoldenDB/alarm"
+	"GoldenDB/api"
 	"GoldenDB/config"
 	"GoldenDB/connect"
+	"GoldenDB/digest"
+	"GoldenDB/ha"
+	"GoldenDB/history"
 	"GoldenDB/log"
+	"GoldenDB/monitor"
+	"GoldenDB/redact"
+	"GoldenDB/service"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
 func main() {
-	args := os.Args
+	args, err := parseGlobalFlags(os.Args)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 	if len(args) < 2 {
-		fmt.Println("用法: -s | -p <明文密码>")
+		fmt.Println(usage)
 		return
 	}
-	if args[1] == "-s" {
-		Start()
-		return
+	switch args[1] {
+	case "-s", "foreground":
+		os.Exit(Start())
+	case "start", "stop", "restart", "status", "unit":
+		os.Exit(runService(args[1], args[2:]))
+	case "check-config":
+		os.Exit(runCheckConfig(args[2:]))
+	case "rotate-key":
+		os.Exit(runRotateKey(args[2:]))
+	case "topology":
+		os.Exit(runTopology(args[2:]))
+	case "replication":
+		os.Exit(runReplication(args[2:]))
+	case "role-audit":
+		os.Exit(runRoleAudit(args[2:]))
+	case "history":
+		runHistory(args[2:])
+	case "report":
+		runReport(args[2:])
+	case "-p":
+		os.Exit(runEncrypt(args[2:]))
+	default:
+		fmt.Println("参数错误: " + usage)
 	}
-	if args[1] == "-p" {
-		if len(args) < 3 {
-			fmt.Println("请输入明文密码: -p <text>")
-			return
-		}
-		encrypt, err := connect.Encrypt(args[2])
-		if err != nil {
-			fmt.Println("加密失败")
-			return
+}
+
+const usage = "用法: [--config <配置文件>] start | stop | restart | status | foreground (-s) | unit [选项] | check-config [-connect] | rotate-key [选项] | topology [选项] | replication [选项] | role-audit [选项] | -p [明文密码] | history [选项] | report [选项]"
+
+// configPath 命令行 --config 指定的配置文件，为空时依次使用环境变量 GDB_ALARM_CONFIG 和 config/amp_api.yaml
+var configPath string
+
+// parseGlobalFlags 取出子命令之前的 --config 参数，返回去掉该参数后的命令行
+func parseGlobalFlags(args []string) ([]string, error) {
+	rest := []string{args[0]}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--config" || arg == "-config":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--config 需要指定配置文件路径")
+			}
+			configPath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--config="):
+			configPath = strings.TrimPrefix(arg, "--config=")
+		default:
+			return append(rest, args[i:]...), nil
 		}
-		fmt.Println(encrypt)
-		return
 	}
-	fmt.Println("参数错误: -s | -p <明文密码>")
-	return
+	return rest, nil
+}
+
+// loadConfig 读取 --config 指定的配置文件
+func loadConfig() (*config.Config, error) {
+	return config.Load(configPath)
 }
 
+// -s 的退出码
+const (
+	exitClean       = 0 // 正常退出：队列已推送完，状态已保存
+	exitStartFailed = 1 // 启动失败
+	exitUnclean     = 2 // 退出时队列未处理完或状态保存失败
+)
+
 // 全局日志实例
 var logger *log.Logger
 
-func initLogger() {
-	// 读取完整配置
-	cfg, err := config.ReadFullConfig("config/amp_api.yaml")
-	if err != nil {
-		fmt.Printf("读取日志配置失败: %v，使用默认配置\n", err)
-		cfg = &config.Config{}
-		cfg.Log.Path = "log/app.log"
-		cfg.Log.KeepDays = 7
-		cfg.Log.CleanInterval = 86400
+func initLogger(cfg *config.Config) {
+	var maxSize int64
+	if cfg.Log.MaxSize > 0 {
+		maxSize = int64(cfg.Log.MaxSize) * 1024 * 1024
 	}
-
 	var errCreate error
-	logger, errCreate = log.NewLogger("main", cfg.Log.Path)
+	logger, errCreate = log.New("main", cfg.Log.Path, log.Options{
+		Level:      cfg.Log.Level,
+		Format:     cfg.Log.Format,
+		Stdout:     cfg.Log.Stdout,
+		MaxSize:    maxSize,
+		Daily:      *cfg.Log.Daily,
+		MaxBackups: cfg.Log.KeepFiles,
+		MaxAge:     cfg.Log.KeepDays,
+		Compress:   *cfg.Log.Compress,
+	})
 	if errCreate != nil {
 		fmt.Printf("初始化日志失败: %v\n", errCreate)
 		logger = nil
@@ -62,110 +123,425 @@ func initLogger() {
 
 	logger.Info("========== 程序启动 ==========")
 	logger.Info("日志文件: %s", cfg.Log.Path)
-	logger.Info("日志保留天数: %d", cfg.Log.KeepDays)
+	logger.Info("日志等级: %s, 切分大小: %dMB, 按天切分: %v, 保留天数: %d, 保留数量: %d", cfg.Log.Level, cfg.Log.MaxSize, *cfg.Log.Daily, cfg.Log.KeepDays, cfg.Log.KeepFiles)
+
+	// 收到SIGUSR1时重新打开日志文件，配合外部logrotate使用
+	watchLogReopen()
 
-	// 启动日志清理定时器
+	// 启动日志清理定时器，只清理切分出的旧文件，不会删除正在写入的文件
 	go func() {
 		cleanTicker := time.NewTicker(time.Duration(cfg.Log.CleanInterval) * time.Second)
 		defer cleanTicker.Stop()
 
 		for range cleanTicker.C {
-			logDir := "log"
-			if err := log.CleanOldLogs(logDir, cfg.Log.KeepDays); err != nil {
-				if logger != nil {
-					logger.Error("清理日志失败: %v", err)
-				}
+			if err := logger.Clean(); err != nil {
+				logger.Error("清理日志失败: %v", err)
 			}
 		}
 	}()
 
 	// 立即执行一次日志清理
-	if err := log.CleanOldLogs("log", cfg.Log.KeepDays); err != nil {
+	if err := logger.Clean(); err != nil {
 		logger.Error("首次清理日志失败: %v", err)
 	}
 }
 
-func Start() {
+// Start 运行监控服务直到收到SIGTERM/SIGINT，返回进程退出码
+func Start() int {
+	// 收到退出信号后停止采集，推送完队列、保存状态并关闭连接后退出
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Printf("读取配置失败: %v\n", err)
+		return exitStartFailed
+	}
+	// 启动前检查配置，避免带着错误的配置运行到一半才失败
+	if problems := cfg.Validate(); len(problems) > 0 {
+		fmt.Println("配置错误，可使用 check-config 命令检查:")
+		for _, p := range problems {
+			fmt.Printf("  %s\n", p)
+		}
+		return exitStartFailed
+	}
+	if err := initKey(cfg); err != nil {
+		fmt.Println(err)
+		return exitStartFailed
+	}
+	filterConfig, err := loadFilter(cfg)
+	if err != nil {
+		fmt.Println(err)
+		return exitStartFailed
+	}
+	// 同一目录只允许运行一个实例
+	pidFile, err := service.AcquirePIDFile(cfg.Service.PIDFile)
+	if err != nil {
+		fmt.Println(err)
+		return exitStartFailed
+	}
+	defer pidFile.Release()
+
 	// 初始化日志
-	initLogger()
+	initLogger(cfg)
+	// 设置alarm包的日志实例
+	alarm.SetLogger(logger)
+	alarm.SetFilterConfig(filterConfig)
 	if logger != nil {
-		logger.Info("开始启动监控服务")
-		alarm.SetLogger(logger) // 设置alarm包的日志实例
+		logger.Info("开始启动监控服务, 配置文件: %v", cfg.Sources)
+		if keyOptions(cfg).Source == connect.KeySourceBuiltin {
+			logger.Warn("正在使用内置密钥，拿到程序即可解密配置中的密码，建议执行 rotate-key 更换")
+		}
 		alarm.LogFilterStatus() // 记录告警过滤配置状态
 	}
 
-	// 设置alarm包的logger
-	alarm.SetLogger(logger)
-
 	// 获取MDS列表
-	mdsList := connect.GetMDS()
+	mdsList, err := connect.GetMDS(cfg)
+	if err != nil {
+		if logger != nil {
+			logger.Error("读取MDS配置失败: %v", err)
+		}
+		fmt.Printf("读取MDS配置失败: %s\n", redact.Error(err))
+		return exitStartFailed
+	}
 	if logger != nil {
 		logger.Info("获取到 %d 个MDS节点", len(mdsList))
 	}
 
-	var wg sync.WaitGroup
-	// 读取配置文件
-	api_address, timePeriod, _ := config.ReadAlarmConfig("config/amp_api.yaml")
+	// 初始化告警历史存储和定时摘要
+	store := initHistory(cfg)
+	scheduler := initDigest(cfg, store)
+
 	if logger != nil {
-		logger.Info("API地址: %s, 监控周期: %d秒", api_address, timePeriod)
+		logger.Info("API地址: %s, 监控周期: %d秒", cfg.Alarm.ApiAddress, cfg.Alarm.Time)
+	}
+	client, err := api.NewClient(cfg.Alarm.ApiAddress, cfg.Alarm.HTTP)
+	if err != nil {
+		if logger != nil {
+			logger.Error("创建告警推送客户端失败: %v", err)
+		}
+		fmt.Printf("创建告警推送客户端失败: %s\n", redact.Error(err))
+		return exitStartFailed
+	}
+	if cfg.Alarm.Batch.Enabled && logger != nil {
+		logger.Info("启用批量推送, 每批最多 %d 条", cfg.Alarm.Batch.MaxSize)
+	}
+
+	// 按部署分组，每组一个采集协程
+	groups := groupMDS(mdsList, cfg.Alarm.GroupMode)
+	if logger != nil {
+		logger.Info("共 %d 个MDS分组, 同组采集方式: %s", len(groups), cfg.Alarm.GroupMode)
+	}
+	defer shutdownResources(store)
+
+	period := time.Duration(cfg.Alarm.Time) * time.Second
+	state := newServiceState(groups, period, time.Duration(cfg.Alarm.Dispatch.QueryTimeout)*time.Second)
+	if cfg.CNMonitor.Enabled {
+		cnList, err := connect.GetCN(cfg)
+		if err != nil {
+			if logger != nil {
+				logger.Error("读取CN配置失败: %v", err)
+			}
+			fmt.Printf("读取CN配置失败: %s\n", redact.Error(err))
+			return exitStartFailed
+		}
+		monitor.SetLogger(logger)
+		state.cn = monitor.NewCNMonitor(cfg.CNMonitor, cnList)
+		if logger != nil {
+			logger.Info("启用CN探测, CN数: %d, 间隔: %d秒", len(cnList), cfg.CNMonitor.Interval)
+		}
+	}
+	if cfg.Replication.Enabled {
+		monitor.SetLogger(logger)
+		state.replication = monitor.NewReplicationMonitor(cfg.Replication, mdsList, cfg.DNConn())
+		if logger != nil {
+			logger.Info("启用复制检查, 间隔: %d秒, 延迟阈值: %d秒", cfg.Replication.Interval, cfg.Replication.MaxLag)
+		}
+	}
+	if cfg.RoleAudit.Enabled {
+		monitor.SetLogger(logger)
+		state.roleAudit = monitor.NewRoleAudit(cfg.RoleAudit, mdsList, cfg.DNConn())
+		if logger != nil {
+			logger.Info("启用DN角色检查, 间隔: %d秒", cfg.RoleAudit.Interval)
+		}
+	}
+
+	// 最近一次采集周期结束时的清理结果，决定退出码
+	var lastErr error
+	var elector *ha.Elector
+	// 主备模式下只有仍持有锁时才保存告警状态，避免覆盖新的主实例已加载的状态
+	leading := func() bool {
+		return elector == nil || elector.IsLeader()
+	}
+	collector := func(ctx context.Context) {
+		lastErr = runCollector(ctx, cfg, client, state, scheduler, leading)
+	}
+	if cfg.HA.Enabled {
+		locker, err := newLocker(cfg, groups)
+		if err != nil {
+			if logger != nil {
+				logger.Error("初始化主备选举失败: %v", err)
+			}
+			fmt.Printf("初始化主备选举失败: %v\n", err)
+			return exitStartFailed
+		}
+		ha.SetLogger(logger)
+		elector = ha.NewElector(locker, time.Duration(cfg.HA.CheckInterval)*time.Second)
+		state.elector = elector
 	}
 
-	// 连接所有的MDS
-	for _, mds := range mdsList {
-		currentMDS := mds
+	// 控制套接字和看门狗在退出清理期间保持可用
+	serviceCtx, cancelService := context.WithCancel(context.Background())
+	defer cancelService()
+	if err := service.ServeControl(serviceCtx, cfg.Service.Socket, state.report); err != nil && logger != nil {
+		logger.Error("%v", err)
+	}
+	go service.RunWatchdog(serviceCtx, state.healthy)
+	service.Notify("READY=1")
+	go func() {
+		<-ctx.Done()
+		service.Notify("STOPPING=1")
+		if logger != nil {
+			logger.Info("收到退出信号，开始停止服务")
+		}
+	}()
+
+	if elector == nil {
+		collector(ctx)
+	} else {
+		// 主备模式：只有选举为主的实例采集和推送告警
+		if logger != nil {
+			logger.Info("启用主备模式, 选主方式: %s, 检查间隔: %d秒", cfg.HA.Mode, cfg.HA.CheckInterval)
+		}
+		elector.Run(ctx, collector)
+	}
+
+	if lastErr != nil {
+		if logger != nil {
+			logger.Error("服务未正常退出: %v", lastErr)
+		}
+		return exitUnclean
+	}
+	if logger != nil {
+		logger.Info("服务已正常退出")
+	}
+	return exitClean
+}
+
+// shutdownResources 关闭数据库连接池、告警历史文件，最后写入并关闭日志
+func shutdownResources(store *history.Store) {
+	if err := connect.CloseAll(); err != nil && logger != nil {
+		logger.Error("关闭数据库连接失败: %v", err)
+	}
+	if store != nil {
+		if err := store.Close(); err != nil && logger != nil {
+			logger.Error("关闭告警历史失败: %v", err)
+		}
+	}
+	if logger != nil {
+		logger.Close()
+	}
+}
+
+// runCollector 采集并推送告警直到ctx结束，结束时在 drain_timeout 内推送完队列并保存告警状态，
+// 未能推送完或保存失败时返回错误。leading返回false（已失去主身份）时不再保存状态
+func runCollector(ctx context.Context, cfg *config.Config, client *api.Client, state *serviceState, scheduler *digest.Scheduler, leading func() bool) error {
+	// 恢复上次（或上一个主实例）保存的已推送告警，避免重复推送和漏推恢复
+	if err := alarm.LoadState(cfg.State.Path); err != nil && logger != nil {
+		logger.Error("加载告警状态失败: %v", err)
+	}
+
+	// 推送阶段：有界队列 + 推送协程池，与各MDS的采集协程解耦
+	dispatcher := alarm.NewDispatcher(client, alarm.DispatchConfig{
+		Workers:   cfg.Alarm.Dispatch.Workers,
+		QueueSize: cfg.Alarm.Dispatch.QueueSize,
+		Batch:     cfg.Alarm.Batch.Enabled,
+		BatchSize: cfg.Alarm.Batch.MaxSize,
+	})
+	dispatcher.Start()
+	state.collectStarted(dispatcher)
+	defer state.collectStopped()
+	if logger != nil {
+		logger.Info("推送协程数: %d, 推送队列容量: %d", cfg.Alarm.Dispatch.Workers, cfg.Alarm.Dispatch.QueueSize)
+	}
+	var wg sync.WaitGroup
+	if scheduler != nil {
 		wg.Add(1)
-		// 并发处理
 		go func() {
 			defer wg.Done()
-			if logger != nil {
-				logger.Info("正在连接 MDS: %s", mds.Name)
-			}
+			scheduler.Run(ctx)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		saveStatePeriodically(ctx, cfg.State.Path, time.Duration(cfg.State.Interval)*time.Second, leading)
+	}()
+	if state.cn != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runProber(ctx, state.cn, dispatcher)
+		}()
+	}
+	if state.replication != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runProber(ctx, state.replication, dispatcher)
+		}()
+	}
+	if state.roleAudit != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runProber(ctx, state.roleAudit, dispatcher)
+		}()
+	}
+	for _, g := range state.groups {
+		wg.Add(1)
+		// 并发处理
+		go func(g *mdsGroup) {
+			defer wg.Done()
+			runGroup(ctx, g, state.period, state.queryTimeout, dispatcher)
+		}(g)
+	}
+	wg.Wait()
 
-			dbConnect := connect.GetDBConnect(currentMDS.DSN)
-			defer dbConnect.Close()
-			if err := dbConnect.Ping(); err != nil {
-				if logger != nil {
-					logger.Error("MDS连接失败: %s, 错误: %v", mds.Name, err)
-				}
+	if logger != nil {
+		logger.Info("采集已停止, 等待推送队列处理完成, 剩余事件: %d", dispatcher.QueueLen())
+	}
+	drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Alarm.Dispatch.DrainTimeout)*time.Second)
+	defer cancel()
+	drainErr := dispatcher.Shutdown(drainCtx)
+	if drainErr != nil && logger != nil {
+		logger.Error("%v", drainErr)
+	}
+	if !leading() {
+		if logger != nil {
+			logger.Warn("已失去主身份，不保存告警状态")
+		}
+		return drainErr
+	}
+	if err := alarm.SaveState(cfg.State.Path); err != nil {
+		if logger != nil {
+			logger.Error("保存告警状态失败: %v", err)
+		}
+		return err
+	}
+	return drainErr
+}
+
+// saveStatePeriodically 定期保存已推送告警状态，供重启或备实例接管时恢复，失去主身份后不再保存
+func saveStatePeriodically(ctx context.Context, path string, interval time.Duration, leading func() bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !leading() {
 				return
 			}
+			if err := alarm.SaveState(path); err != nil && logger != nil {
+				logger.Error("保存告警状态失败: %v", err)
+			}
+		}
+	}
+}
 
-			if logger != nil {
-				logger.Info("MDS连接成功: %s", mds.Name)
+// newLocker 按配置创建主备选举锁。mysql模式在 ha.mds 指定的MDS上加锁，
+// 未指定时在全部MDS的多数上加锁；锁使用单独的连接，不占用采集用的连接池
+func newLocker(cfg *config.Config, groups []*mdsGroup) (ha.Locker, error) {
+	interval := time.Duration(cfg.HA.CheckInterval) * time.Second
+	switch cfg.HA.Mode {
+	case "file":
+		return ha.NewFileLocker(cfg.HA.LockFile), nil
+	case "mysql":
+		var targets []ha.MySQLTarget
+		for _, g := range groups {
+			for _, m := range g.members {
+				if cfg.HA.MDS == "" || m.name == cfg.HA.MDS {
+					targets = append(targets, ha.MySQLTarget{Name: m.name, Node: m.node})
+				}
 			}
+		}
+		if cfg.HA.MDS != "" && len(targets) == 0 {
+			return nil, fmt.Errorf("未找到用于选主的MDS: %s", cfg.HA.MDS)
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("没有可用于选主的MDS")
+		}
+		// 锁会话在3个检查间隔内无活动即被MDS断开，主实例宕机后锁随之释放
+		return ha.NewMySQLLocker(targets, cfg.HA.LockName, 3*interval), nil
+	default:
+		return nil, fmt.Errorf("不支持的选主方式: %s", cfg.HA.Mode)
+	}
+}
+
+// loadFilter 解析配置中的告警过滤规则，未配置时返回nil
+func loadFilter(cfg *config.Config) (*alarm.FilterConfig, error) {
+	if len(cfg.FilterJSON) == 0 {
+		return nil, nil
+	}
+	filterConfig, err := alarm.ParseFilterConfig(cfg.FilterJSON)
+	if err != nil {
+		return nil, err
+	}
+	if problems := alarm.ValidateFilterConfig(filterConfig); len(problems) > 0 {
+		return nil, fmt.Errorf("告警过滤规则错误: %s", strings.Join(problems, "; "))
+	}
+	return filterConfig, nil
+}
 
-			insight := mds.Name //insight平台名称
-			var cache sync.Map  // 定义缓存
-
-			ticker := time.NewTicker(time.Duration(timePeriod) * time.Second) //定时器
-			defer ticker.Stop()
-
-			// 定时查询并告警
-			for {
-				select {
-				case <-ticker.C:
-					// 查询当前所有告警并封装成切片x s
-					Alarms := alarm.GetAlarm(dbConnect)
-					currentAlarms := alarm.GenAlarmList(Alarms, insight, "trigger")
-
-					// 处理告警
-					if err := alarm.ProcessAlarmChanges(currentAlarms, &cache, api_address); err != nil {
-						if logger != nil {
-							logger.Error("处理告警失败: %v", err)
-							return
-						}
-					} else {
-						if logger != nil {
-							logger.Info("处理告警完成, 当前告警数: %d", len(currentAlarms))
-							for _, v := range currentAlarms {
-								logger.Info("当前告警: %+v", v)
-							}
-						}
-					}
+// initHistory 打开告警历史存储并启动过期数据清理，未启用时返回nil
+func initHistory(cfg *config.Config) *history.Store {
+	if !cfg.History.Enabled {
+		return nil
+	}
+	store, err := history.Open(cfg.History.Path, cfg.History.KeepDays)
+	if err != nil {
+		if logger != nil {
+			logger.Error("打开告警历史存储失败: %v", err)
+		}
+		return nil
+	}
+	alarm.SetHistory(store)
+	if logger != nil {
+		logger.Info("告警历史目录: %s, 保留天数: %d", cfg.History.Path, cfg.History.KeepDays)
+	}
+
+	go func() {
+		for {
+			if n, err := store.Clean(); err != nil {
+				if logger != nil {
+					logger.Error("清理告警历史失败: %v", err)
 				}
+			} else if n > 0 && logger != nil {
+				logger.Info("已清理 %d 个过期告警历史文件", n)
 			}
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+	return store
+}
 
-		}()
+// initDigest 按配置创建定时告警摘要，未启用时返回nil；摘要只由主实例发送
+func initDigest(cfg *config.Config, store *history.Store) *digest.Scheduler {
+	if !cfg.Digest.Enabled {
+		return nil
 	}
-	wg.Wait()
+	digest.SetLogger(logger)
+	scheduler, err := digest.NewScheduler(cfg.Digest, store)
+	if err != nil {
+		if logger != nil {
+			logger.Error("告警摘要配置错误: %v", err)
+		}
+		return nil
+	}
+	if logger != nil {
+		logger.Info("告警摘要已启用, 发送方式: %s, 发送时间: %v", cfg.Digest.Type, cfg.Digest.Times)
+	}
+	return scheduler
 }
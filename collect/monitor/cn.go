@@ -0,0 +1,310 @@
+package monitor
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"GoldenDB/redact"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CNResult 一个CN最近一轮的探测结果，无法获取的计数为-1
+type CNResult struct {
+	Name             string   `json:"name"`
+	Addr             string   `json:"addr"`
+	Up               bool     `json:"up"` // 登录且探测查询成功
+	Error            string   `json:"error,omitempty"`
+	LatencyMs        float64  `json:"latencyMs"`
+	ThreadsRunning   int      `json:"threadsRunning"`
+	ThreadsConnected int      `json:"threadsConnected"`
+	MaxConnections   int      `json:"maxConnections"`
+	Backlog          int      `json:"backlog"`
+	Problems         []string `json:"problems,omitempty"` // 本轮异常: down | slow | saturated | backlog
+	CheckedAt        string   `json:"checkedAt"`
+}
+
+// 异常类型
+const (
+	problemDown      = "down"
+	problemSlow      = "slow"
+	problemSaturated = "saturated"
+	problemBacklog   = "backlog"
+)
+
+// CNMonitor 定时探测各CN的登录、探测查询延迟、线程和连接数以及processlist积压，
+// 连续 trigger 轮异常时生成告警，恢复后告警随之消除
+type CNMonitor struct {
+	cfg   config.CNMonitor
+	nodes []connect.CNConnect
+
+	mu      sync.Mutex
+	tracker tracker // 按 CN名称|异常类型 记录连续异常轮数
+	last    []CNResult
+}
+
+// NewCNMonitor 创建CN探测器
+func NewCNMonitor(cfg config.CNMonitor, nodes []connect.CNConnect) *CNMonitor {
+	return &CNMonitor{
+		cfg:     cfg,
+		nodes:   nodes,
+		tracker: newTracker(cfg.Trigger),
+	}
+}
+
+// Insight 告警所属的insight
+func (m *CNMonitor) Insight() string {
+	return m.cfg.Insight
+}
+
+// Interval 探测间隔
+func (m *CNMonitor) Interval() time.Duration {
+	return time.Duration(m.cfg.Interval) * time.Second
+}
+
+// Check 并发探测全部CN，返回当前应处于告警状态的告警
+func (m *CNMonitor) Check(ctx context.Context) []alarm.Alarm {
+	results := make([]CNResult, len(m.nodes))
+	var wg sync.WaitGroup
+	for i, n := range m.nodes {
+		wg.Add(1)
+		go func(i int, n connect.CNConnect) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, time.Duration(m.cfg.Timeout)*time.Second)
+			defer cancel()
+			results[i] = m.probe(probeCtx, n)
+		}(i, n)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = results
+	var alarms []alarm.Alarm
+	for i, r := range results {
+		for _, p := range r.Problems {
+			if fire, since := m.tracker.observe(r.Name+"|"+p, r.CheckedAt); fire {
+				alarms = append(alarms, m.newAlarm(m.nodes[i], r, p, since))
+			}
+		}
+	}
+	m.tracker.finish()
+	return alarms
+}
+
+// Status 最近一轮的探测结果
+func (m *CNMonitor) Status() []CNResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]CNResult(nil), m.last...)
+}
+
+// probe 探测一个CN
+func (m *CNMonitor) probe(ctx context.Context, n connect.CNConnect) CNResult {
+	r := CNResult{
+		Name:             n.Name,
+		Addr:             n.Addr(),
+		ThreadsRunning:   -1,
+		ThreadsConnected: -1,
+		MaxConnections:   -1,
+		Backlog:          -1,
+		CheckedAt:        time.Now().Format("2006-01-02 15:04:05"),
+	}
+	db, err := connect.Connect(ctx, n.Node)
+	if err != nil {
+		r.Error = redact.Error(err)
+		r.Problems = []string{problemDown}
+		if logger != nil {
+			logger.With("cn", n.Name).Warn("CN登录失败: %s, 错误: %s", r.Addr, r.Error)
+		}
+		return r
+	}
+
+	// 登录成功但探测查询失败同样视为不可用
+	start := time.Now()
+	if err := runQuery(ctx, db, m.cfg.Query); err != nil {
+		r.Error = redact.Error(err)
+		r.Problems = []string{problemDown}
+		if logger != nil {
+			logger.With("cn", n.Name).Warn("CN探测查询失败: %s, 错误: %s", r.Addr, r.Error)
+		}
+		return r
+	}
+	r.Up = true
+	latency := time.Since(start)
+	r.LatencyMs = float64(latency.Microseconds()) / 1000
+	if latency > time.Duration(m.cfg.SlowMs)*time.Millisecond {
+		r.Problems = append(r.Problems, problemSlow)
+	}
+
+	// CN不支持的统计项跳过，不视为异常
+	status, err := globalStatus(ctx, db, "Threads_running", "Threads_connected")
+	if err == nil {
+		r.ThreadsRunning = status["Threads_running"]
+		r.ThreadsConnected = status["Threads_connected"]
+	} else if logger != nil {
+		logger.With("cn", n.Name).Debug("CN线程数查询失败: %s, 错误: %v", r.Addr, err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT @@max_connections").Scan(&r.MaxConnections); err != nil {
+		r.MaxConnections = -1
+		if logger != nil {
+			logger.With("cn", n.Name).Debug("CN最大连接数查询失败: %s, 错误: %v", r.Addr, err)
+		}
+	}
+	if m.saturated(r) {
+		r.Problems = append(r.Problems, problemSaturated)
+	}
+
+	if r.Backlog, err = processlistBacklog(ctx, db, m.cfg.BacklogTime); err != nil {
+		r.Backlog = -1
+		if logger != nil {
+			logger.With("cn", n.Name).Debug("CN processlist查询失败: %s, 错误: %v", r.Addr, err)
+		}
+	}
+	if m.cfg.MaxBacklog > 0 && r.Backlog > m.cfg.MaxBacklog {
+		r.Problems = append(r.Problems, problemBacklog)
+	}
+
+	if logger != nil {
+		logger.With("cn", n.Name).Debug("CN探测: %s, 延迟: %.1fms, Threads_running: %d, Threads_connected: %d, max_connections: %d, 积压: %d",
+			r.Addr, r.LatencyMs, r.ThreadsRunning, r.ThreadsConnected, r.MaxConnections, r.Backlog)
+	}
+	return r
+}
+
+func (m *CNMonitor) saturated(r CNResult) bool {
+	if m.cfg.MaxThreadsRunning > 0 && r.ThreadsRunning > m.cfg.MaxThreadsRunning {
+		return true
+	}
+	if m.cfg.MaxConnUsage > 0 && r.ThreadsConnected >= 0 && r.MaxConnections > 0 {
+		return r.ThreadsConnected*100 >= r.MaxConnections*m.cfg.MaxConnUsage
+	}
+	return false
+}
+
+// newAlarm 生成探测告警。告警内容不含每轮变化的测量值，避免每轮都被视为告警更新
+func (m *CNMonitor) newAlarm(n connect.CNConnect, r CNResult, problem string, since string) alarm.Alarm {
+	var code, level int
+	var content string
+	switch problem {
+	case problemDown:
+		code, level = m.cfg.Codes.Down, 2
+		content = fmt.Sprintf("CN %s(%s) 无法登录或探测查询失败", n.Name, r.Addr)
+	case problemSlow:
+		code, level = m.cfg.Codes.Slow, 3
+		content = fmt.Sprintf("CN %s(%s) 探测查询往返延迟超过 %dms", n.Name, r.Addr, m.cfg.SlowMs)
+	case problemSaturated:
+		code, level = m.cfg.Codes.Saturated, 3
+		content = fmt.Sprintf("CN %s(%s) 线程或连接数饱和", n.Name, r.Addr)
+	case problemBacklog:
+		code, level = m.cfg.Codes.Backlog, 3
+		content = fmt.Sprintf("CN %s(%s) processlist中执行超过 %d 秒的会话超过 %d 个", n.Name, r.Addr, m.cfg.BacklogTime, m.cfg.MaxBacklog)
+	}
+	return alarm.Alarm{
+		Alarmid:     SyntheticID(n.Name, code),
+		Alarmsource: "monitor",
+		Code:        code,
+		Almlevel:    level,
+		Content:     content,
+		Createtime:  since,
+		Updatetime:  since,
+		Reserve4: alarm.Reserve4{
+			DstInfo:        r.Addr,
+			DstType:        "CN",
+			DstClusterName: n.Name,
+		},
+	}
+}
+
+// runQuery 执行探测查询并读完结果
+func runQuery(ctx context.Context, db *sql.DB, query string) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// globalStatus 查询全局状态变量
+func globalStatus(ctx context.Context, db *sql.DB, names ...string) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, "SHOW GLOBAL STATUS WHERE Variable_name IN ('"+strings.Join(names, "','")+"')")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	status := make(map[string]int)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			status[name] = n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if _, ok := status[name]; !ok {
+			return nil, fmt.Errorf("不支持状态变量 %s", name)
+		}
+	}
+	return status, nil
+}
+
+// processlistBacklog 统计 SHOW PROCESSLIST 中非空闲且执行时间不少于minTime秒的会话数
+func processlistBacklog(ctx context.Context, db *sql.DB, minTime int) (int, error) {
+	rows, err := db.QueryContext(ctx, "SHOW PROCESSLIST")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	// 不同版本的列数不同，按列名取 Command 和 Time
+	commandIdx, timeIdx := -1, -1
+	for i, c := range columns {
+		switch strings.ToLower(c) {
+		case "command":
+			commandIdx = i
+		case "time":
+			timeIdx = i
+		}
+	}
+	if commandIdx < 0 || timeIdx < 0 {
+		return 0, fmt.Errorf("processlist缺少Command或Time列")
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	backlog := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, err
+		}
+		switch string(values[commandIdx]) {
+		case "Sleep", "Daemon", "Binlog Dump", "Binlog Dump GTID":
+			continue
+		}
+		if t, err := strconv.Atoi(string(values[timeIdx])); err == nil && t >= minTime {
+			backlog++
+		}
+	}
+	return backlog, rows.Err()
+}
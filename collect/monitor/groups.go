@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"GoldenDB/topology"
+	"context"
+	"sync"
+)
+
+// dnGroup 从MDS获取的一个DN组，以及登录组内DN所用的MDS
+type dnGroup struct {
+	deployment string
+	cluster    topology.Cluster
+	group      topology.Group
+	mds        connect.MDSDemo
+}
+
+// masters 按MDS登记的角色拆分主库和其它DN
+func (g dnGroup) masters() (masters, others []topology.DN) {
+	for _, dn := range g.group.DNs {
+		if dn.Role == "master" {
+			masters = append(masters, dn)
+		} else {
+			others = append(others, dn)
+		}
+	}
+	return masters, others
+}
+
+// discoveryFailure 无法获取DN信息的部署或集群，集群为空时表示整个部署
+type discoveryFailure struct {
+	deployment string
+	clusterID  string
+	cluster    string
+	reason     string
+}
+
+// discoverGroups 从各部署的MDS获取全部DN组
+func discoverGroups(ctx context.Context, mdsList []connect.MDSDemo) ([]dnGroup, []discoveryFailure) {
+	credentials := make(map[string]connect.MDSDemo)
+	for _, mds := range mdsList {
+		credentials[mds.Name] = mds
+	}
+	var groups []dnGroup
+	var failures []discoveryFailure
+	for _, d := range topology.Discover(ctx, mdsList, topology.Options{}).Deployments {
+		if d.Error != "" {
+			failures = append(failures, discoveryFailure{deployment: d.Name, reason: d.Error})
+			continue
+		}
+		for _, c := range d.Clusters {
+			if c.Error != "" {
+				failures = append(failures, discoveryFailure{deployment: d.Name, clusterID: c.ID, cluster: c.Name, reason: c.Error})
+				continue
+			}
+			for _, g := range c.Groups {
+				groups = append(groups, dnGroup{deployment: d.Name, cluster: c, group: g, mds: credentials[d.Source]})
+			}
+		}
+	}
+	return groups, failures
+}
+
+// eachGroup 以最多concurrency个并发对各DN组执行fn
+func eachGroup(groups []dnGroup, concurrency int, fn func(i int, g dnGroup)) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, g := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, g dnGroup) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i, g)
+		}(i, g)
+	}
+	wg.Wait()
+}
+
+// dnNode 使用MDS的用户和密码登录DN
+func dnNode(dn topology.DN, mds connect.MDSDemo, conn config.DBConn) connect.Node {
+	return connect.Node{
+		Name:     "DN " + dn.Addr,
+		Host:     dn.Host,
+		Port:     dn.Port,
+		User:     mds.User,
+		Password: mds.Password,
+		Conn:     conn,
+	}
+}
@@ -0,0 +1,112 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gtidSet 解析后的GTID集合，键为 server_uuid（带标签时为 server_uuid:tag），值为按起点排序且不重叠的区间
+type gtidSet map[string][]gtidRange
+
+// gtidRange 闭区间 [start, end]
+type gtidRange struct {
+	start, end int64
+}
+
+// parseGTIDSet 解析 gtid_executed 格式的GTID集合，如
+// "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:7,4F22..:1-3"，也支持8.4的 uuid:tag:1-5 形式
+func parseGTIDSet(s string) (gtidSet, error) {
+	set := gtidSet{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("无效的GTID: %s", item)
+		}
+		sid := strings.ToLower(strings.TrimSpace(parts[0]))
+		key := sid
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if p == "" || p[0] < '0' || p[0] > '9' {
+				// 标签，作用于其后的区间
+				key = sid + ":" + strings.ToLower(p)
+				continue
+			}
+			r, err := parseGTIDRange(p)
+			if err != nil {
+				return nil, fmt.Errorf("无效的GTID区间 %s: %w", item, err)
+			}
+			set[key] = append(set[key], r)
+		}
+	}
+	for key, ranges := range set {
+		set[key] = mergeRanges(ranges)
+	}
+	return set, nil
+}
+
+func parseGTIDRange(s string) (gtidRange, error) {
+	start, end, found := strings.Cut(s, "-")
+	a, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return gtidRange{}, err
+	}
+	b := a
+	if found {
+		if b, err = strconv.ParseInt(end, 10, 64); err != nil {
+			return gtidRange{}, err
+		}
+	}
+	if b < a {
+		return gtidRange{}, fmt.Errorf("区间终点小于起点")
+	}
+	return gtidRange{a, b}, nil
+}
+
+// mergeRanges 排序并合并重叠或相邻的区间
+func mergeRanges(ranges []gtidRange) []gtidRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.start <= merged[n-1].end+1 {
+			if r.end > merged[n-1].end {
+				merged[n-1].end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// countMissing 返回在s中而不在other中的事务数
+func (s gtidSet) countMissing(other gtidSet) int64 {
+	var n int64
+	for key, ranges := range s {
+		theirs := other[key]
+		j := 0
+		for _, r := range ranges {
+			start := r.start
+			for j < len(theirs) && theirs[j].end < start {
+				j++
+			}
+			for k := j; k < len(theirs) && theirs[k].start <= r.end && start <= r.end; k++ {
+				if theirs[k].start > start {
+					n += theirs[k].start - start
+				}
+				if theirs[k].end+1 > start {
+					start = theirs[k].end + 1
+				}
+			}
+			if start <= r.end {
+				n += r.end - start + 1
+			}
+		}
+	}
+	return n
+}
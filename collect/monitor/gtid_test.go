@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	uuidA = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	uuidB = "4f22ab58-82db-22f2-af44-d91bb0530673"
+)
+
+func TestParseGTIDSet(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want gtidSet
+	}{
+		{"空集合", "", gtidSet{}},
+		{"单个事务和区间", uuidA + ":1-5:7", gtidSet{uuidA: {{1, 5}, {7, 7}}}},
+		{"多个server_uuid及换行", uuidA + ":1-3,\n" + uuidB + ":1-2", gtidSet{uuidA: {{1, 3}}, uuidB: {{1, 2}}}},
+		{"大写uuid", "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-3", gtidSet{uuidA: {{1, 3}}}},
+		{"重叠区间", uuidA + ":1-5:3-8", gtidSet{uuidA: {{1, 8}}}},
+		{"相邻区间", uuidA + ":1-5:6-9", gtidSet{uuidA: {{1, 9}}}},
+		{"乱序区间", uuidA + ":10-12:1-3", gtidSet{uuidA: {{1, 3}, {10, 12}}}},
+		{"标签", uuidA + ":job:1-5", gtidSet{uuidA + ":job": {{1, 5}}}},
+		{"标签作用于其后的区间", uuidA + ":1-3:Job:5-6", gtidSet{uuidA: {{1, 3}}, uuidA + ":job": {{5, 6}}}},
+		{"标签与无标签分开合并", uuidA + ":1-3," + uuidA + ":job:1-2," + uuidA + ":4", gtidSet{uuidA: {{1, 4}}, uuidA + ":job": {{1, 2}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGTIDSet(tt.in)
+			if err != nil {
+				t.Fatalf("parseGTIDSet(%q) 返回错误: %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGTIDSet(%q) = %v, 期望 %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseGTIDSetInvalid(t *testing.T) {
+	for _, in := range []string{
+		uuidA,
+		uuidA + ":5-3",
+		uuidA + ":1-x",
+	} {
+		if _, err := parseGTIDSet(in); err == nil {
+			t.Errorf("parseGTIDSet(%q) 应返回错误", in)
+		}
+	}
+}
+
+func TestCountMissing(t *testing.T) {
+	tests := []struct {
+		name    string
+		master  string
+		replica string
+		missing int64 // 主库有而备库没有
+		errant  int64 // 备库有而主库没有
+	}{
+		{"相同", uuidA + ":1-100", uuidA + ":1-100", 0, 0},
+		{"备库落后", uuidA + ":1-100", uuidA + ":1-90", 10, 0},
+		{"备库为空", uuidA + ":1-5", "", 5, 0},
+		{"中间缺一个事务", uuidA + ":1-10", uuidA + ":1-4:6-10", 1, 0},
+		{"多处缺一个事务", uuidA + ":1-10", uuidA + ":2-4:6-9", 3, 0},
+		{"备库区间跨越主库的空洞", uuidA + ":1-3:7-9", uuidA + ":1-9", 0, 3},
+		{"备库多出的事务", uuidA + ":1-10", uuidA + ":1-11", 0, 1},
+		{"备库多出其他server_uuid", uuidA + ":1-10", uuidA + ":1-10," + uuidB + ":1-2", 0, 2},
+		{"重叠与相邻区间合并后比较", uuidA + ":1-5:3-8:9-10", uuidA + ":1-4:4-10", 0, 0},
+		{"标签单独计数", uuidA + ":1-5," + uuidA + ":job:1-3", uuidA + ":1-5", 3, 0},
+		{"标签不同不视为同一事务", uuidA + ":job:1-3", uuidA + ":1-3", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			master, err := parseGTIDSet(tt.master)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replica, err := parseGTIDSet(tt.replica)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := master.countMissing(replica); got != tt.missing {
+				t.Errorf("缺少事务数 = %d, 期望 %d", got, tt.missing)
+			}
+			if got := replica.countMissing(master); got != tt.errant {
+				t.Errorf("多余事务数 = %d, 期望 %d", got, tt.errant)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package monitor 主动探测GoldenDB各组件的健康状况，异常时生成与MDS告警格式相同的告警，
+// 由采集流程统一过滤、去重和推送
+package monitor
+
+import (
+	"GoldenDB/log"
+	"hash/fnv"
+	"strconv"
+)
+
+var logger *log.Logger
+
+func SetLogger(l *log.Logger) {
+	logger = l
+}
+
+// SyntheticID 生成探测告警的告警ID。同一对象的同一类告警ID固定，
+// 保证告警指纹在各轮探测和重启之间不变；取负数以区别于MDS中的告警ID
+func SyntheticID(object string, code int) int {
+	h := fnv.New32a()
+	h.Write([]byte(object))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(code)))
+	return -int(h.Sum32()>>1) - 1
+}
+
+// tracker 记录各异常的连续出现轮数。同一异常连续出现trigger轮后才告警，
+// 一轮未出现即清除，恢复时告警随之消除
+type tracker struct {
+	trigger int
+	streak  map[string]int    // 对象|异常类型 -> 连续异常轮数
+	since   map[string]string // 对象|异常类型 -> 首次发现时间
+	seen    map[string]bool   // 本轮出现的异常
+}
+
+func newTracker(trigger int) tracker {
+	return tracker{trigger: trigger, streak: make(map[string]int), since: make(map[string]string)}
+}
+
+// observe 记录本轮出现的一个异常，返回是否已达到告警条件和首次发现时间
+func (t *tracker) observe(key string, now string) (bool, string) {
+	if t.seen == nil {
+		t.seen = make(map[string]bool)
+	}
+	t.seen[key] = true
+	t.streak[key]++
+	if _, ok := t.since[key]; !ok {
+		t.since[key] = now
+	}
+	return t.streak[key] >= t.trigger, t.since[key]
+}
+
+// finish 结束一轮，清除本轮未出现的异常
+func (t *tracker) finish() {
+	for key := range t.streak {
+		if !t.seen[key] {
+			delete(t.streak, key)
+			delete(t.since, key)
+		}
+	}
+	t.seen = nil
+}
@@ -0,0 +1,374 @@
+package monitor
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"GoldenDB/redact"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplicaResult 一个备库复制通道最近一轮的检查结果，无法获取的数值为-1。
+// 部署或集群的DN信息无法获取时，Addr为空，Error为原因
+type ReplicaResult struct {
+	Deployment  string   `json:"deployment"`
+	ClusterID   string   `json:"clusterId,omitempty"`
+	Cluster     string   `json:"cluster,omitempty"`
+	Group       string   `json:"group,omitempty"`
+	Addr        string   `json:"addr,omitempty"`
+	Master      string   `json:"master,omitempty"` // MDS中登记的组内主库
+	Channel     string   `json:"channel,omitempty"`
+	Source      string   `json:"source,omitempty"` // 复制状态中的实际复制源
+	IORunning   string   `json:"ioRunning,omitempty"`
+	SQLRunning  string   `json:"sqlRunning,omitempty"`
+	LagSeconds  int      `json:"lagSeconds"`
+	LastError   string   `json:"lastError,omitempty"`
+	GTIDMissing int64    `json:"gtidMissing"` // 主库已执行而备库未执行的事务数
+	GTIDErrant  int64    `json:"gtidErrant"`  // 备库已执行而主库没有的事务数
+	Error       string   `json:"error,omitempty"`
+	Problems    []string `json:"problems,omitempty"` // 本轮异常: io | sql | lag | gtid | error
+	CheckedAt   string   `json:"checkedAt"`
+}
+
+// 复制异常类型
+const (
+	problemIO    = "io"
+	problemSQL   = "sql"
+	problemLag   = "lag"
+	problemGTID  = "gtid"
+	problemCheck = "error"
+)
+
+// ReplicationMonitor 定时从MDS获取各DN组，检查备库的复制线程、延迟、错误以及与组内主库的GTID差异，
+// 连续 trigger 轮异常时生成告警，恢复后告警随之消除
+type ReplicationMonitor struct {
+	cfg    config.Replication
+	mds    []connect.MDSDemo
+	dnConn config.DBConn
+
+	mu      sync.Mutex
+	tracker tracker // 按 检查对象|异常类型 记录连续异常轮数
+	last    []ReplicaResult
+}
+
+// NewReplicationMonitor 创建复制检查器，dnConn为DN的连接设置
+func NewReplicationMonitor(cfg config.Replication, mds []connect.MDSDemo, dnConn config.DBConn) *ReplicationMonitor {
+	return &ReplicationMonitor{
+		cfg:     cfg,
+		mds:     mds,
+		dnConn:  dnConn,
+		tracker: newTracker(cfg.Trigger),
+	}
+}
+
+// Insight 告警所属的insight
+func (m *ReplicationMonitor) Insight() string {
+	return m.cfg.Insight
+}
+
+// Interval 检查间隔
+func (m *ReplicationMonitor) Interval() time.Duration {
+	return time.Duration(m.cfg.Interval) * time.Second
+}
+
+// Check 检查全部DN组，返回当前应处于告警状态的告警
+func (m *ReplicationMonitor) Check(ctx context.Context) []alarm.Alarm {
+	results := m.Collect(ctx)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = results
+	var alarms []alarm.Alarm
+	for _, r := range results {
+		for _, p := range r.Problems {
+			if fire, since := m.tracker.observe(r.object()+"|"+p, r.CheckedAt); fire {
+				alarms = append(alarms, m.newAlarm(r, p, since))
+			}
+		}
+	}
+	m.tracker.finish()
+	return alarms
+}
+
+// Status 最近一轮的检查结果
+func (m *ReplicationMonitor) Status() []ReplicaResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ReplicaResult(nil), m.last...)
+}
+
+// Collect 执行一轮检查并返回各备库的结果，整轮不超过配置的timeout
+func (m *ReplicationMonitor) Collect(ctx context.Context) []ReplicaResult {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(m.cfg.Timeout)*time.Second)
+	defer cancel()
+	now := time.Now().Format("2006-01-02 15:04:05")
+	groups, failures := discoverGroups(ctx, m.mds)
+	var results []ReplicaResult
+	for _, f := range failures {
+		results = append(results, unchecked(ReplicaResult{Deployment: f.deployment, ClusterID: f.clusterID, Cluster: f.cluster, CheckedAt: now}, f.reason))
+	}
+	groupResults := make([][]ReplicaResult, len(groups))
+	eachGroup(groups, m.cfg.Concurrency, func(i int, g dnGroup) {
+		base := ReplicaResult{Deployment: g.deployment, ClusterID: g.cluster.ID, Cluster: g.cluster.Name, Group: g.group.ID, CheckedAt: now}
+		groupResults[i] = m.checkGroup(ctx, base, g)
+	})
+	for _, r := range groupResults {
+		results = append(results, r...)
+	}
+	return results
+}
+
+// checkGroup 检查一个DN组内的全部备库。每个备库先读取备库的复制状态再读取主库的GTID：
+// 读取间隔内主库新提交的事务只会算作备库缺少的事务，由 max_gtid_gap 容忍，
+// 而不会因为备库先于读取应用了主库的新事务被误判为备库多出的事务
+func (m *ReplicationMonitor) checkGroup(ctx context.Context, base ReplicaResult, g dnGroup) []ReplicaResult {
+	masters, slaves := g.masters()
+	if len(slaves) == 0 {
+		return nil
+	}
+
+	var masterGTID func() gtidSet
+	switch len(masters) {
+	case 1:
+		base.Master = masters[0].Addr
+		node := dnNode(masters[0], g.mds, m.dnConn)
+		masterGTID = func() gtidSet {
+			set, err := m.masterGTID(ctx, node)
+			if err != nil {
+				if logger != nil {
+					logger.With("cluster", base.Cluster, "group", base.Group).Warn("读取主库GTID失败: %s, 错误: %s", base.Master, redact.Error(err))
+				}
+				return nil
+			}
+			return set
+		}
+	default:
+		// 无主或多主时无法确定比较对象，只检查复制线程和延迟
+		if logger != nil {
+			logger.With("cluster", base.Cluster, "group", base.Group).Warn("DN组 %s 有 %d 个主库，跳过GTID比较", base.Group, len(masters))
+		}
+	}
+
+	var results []ReplicaResult
+	for _, dn := range slaves {
+		r := base
+		r.Addr = dn.Addr
+		results = append(results, m.checkReplica(ctx, r, dnNode(dn, g.mds, m.dnConn), masterGTID)...)
+	}
+	return results
+}
+
+func (m *ReplicationMonitor) masterGTID(ctx context.Context, n connect.Node) (gtidSet, error) {
+	db, err := connect.Connect(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+	var executed string
+	if err := db.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_executed").Scan(&executed); err != nil {
+		return nil, err
+	}
+	return parseGTIDSet(executed)
+}
+
+// checkReplica 检查一个备库，每个复制通道一条结果。masterGTID 在读取备库的复制状态之后调用，
+// 为nil或返回nil时不比较GTID
+func (m *ReplicationMonitor) checkReplica(ctx context.Context, base ReplicaResult, n connect.Node, masterGTID func() gtidSet) []ReplicaResult {
+	base.LagSeconds, base.GTIDMissing, base.GTIDErrant = -1, -1, -1
+	failed := func(err error) []ReplicaResult {
+		base = unchecked(base, redact.Error(err))
+		if logger != nil {
+			logger.With("cluster", base.Cluster, "group", base.Group).Warn("复制状态检查失败: %s, 错误: %s", base.Addr, base.Error)
+		}
+		return []ReplicaResult{base}
+	}
+	db, err := connect.Connect(ctx, n)
+	if err != nil {
+		return failed(err)
+	}
+	channels, err := replicaStatus(ctx, db)
+	if err != nil {
+		return failed(err)
+	}
+	if len(channels) == 0 {
+		return failed(fmt.Errorf("未配置复制"))
+	}
+	var masterSet gtidSet
+	if masterGTID != nil {
+		masterSet = masterGTID()
+	}
+
+	var results []ReplicaResult
+	for _, row := range channels {
+		r := base
+		r.Channel = row.get("channel_name")
+		r.IORunning = row.get("replica_io_running", "slave_io_running")
+		r.SQLRunning = row.get("replica_sql_running", "slave_sql_running")
+		if host := row.get("source_host", "master_host"); host != "" {
+			r.Source = net.JoinHostPort(host, row.get("source_port", "master_port"))
+		}
+		if lag, err := strconv.Atoi(row.get("seconds_behind_source", "seconds_behind_master")); err == nil {
+			r.LagSeconds = lag
+		}
+		if e := row.get("last_io_error"); e != "" {
+			r.LastError = "IO: " + redact.String(e)
+		} else if e := row.get("last_sql_error"); e != "" {
+			r.LastError = "SQL: " + redact.String(e)
+		}
+		if masterSet != nil && row.has("executed_gtid_set") {
+			if set, err := parseGTIDSet(row.get("executed_gtid_set")); err == nil {
+				r.GTIDMissing = masterSet.countMissing(set)
+				r.GTIDErrant = set.countMissing(masterSet)
+			} else if logger != nil {
+				logger.Debug("解析备库GTID失败: %s, 错误: %v", r.Addr, err)
+			}
+		}
+
+		if r.IORunning != "Yes" {
+			r.Problems = append(r.Problems, problemIO)
+		}
+		if r.SQLRunning != "Yes" {
+			r.Problems = append(r.Problems, problemSQL)
+		}
+		if r.LagSeconds > m.cfg.MaxLag {
+			r.Problems = append(r.Problems, problemLag)
+		}
+		if r.GTIDMissing > m.cfg.MaxGTIDGap || r.GTIDErrant > 0 {
+			r.Problems = append(r.Problems, problemGTID)
+		}
+		if logger != nil {
+			logger.With("cluster", r.Cluster, "group", r.Group).Debug("复制状态: %s, IO: %s, SQL: %s, 延迟: %d, 缺少事务: %d, 多余事务: %d",
+				r.Addr, r.IORunning, r.SQLRunning, r.LagSeconds, r.GTIDMissing, r.GTIDErrant)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// unchecked 无法检查时的结果
+func unchecked(r ReplicaResult, reason string) ReplicaResult {
+	r.LagSeconds, r.GTIDMissing, r.GTIDErrant = -1, -1, -1
+	r.Error = reason
+	r.Problems = []string{problemCheck}
+	return r
+}
+
+// statusRow 复制状态的一行，键为小写列名，NULL值不出现在map中
+type statusRow map[string]string
+
+// get 返回第一个存在的列的值，用于兼容 REPLICA/SLAVE 两套列名
+func (r statusRow) get(names ...string) string {
+	for _, name := range names {
+		if v, ok := r[name]; ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func (r statusRow) has(name string) bool {
+	_, ok := r[name]
+	return ok
+}
+
+// replicaStatus 执行 SHOW REPLICA STATUS，不支持时（8.0.22之前）改用 SHOW SLAVE STATUS
+func replicaStatus(ctx context.Context, db *sql.DB) ([]statusRow, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		if rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS"); err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var result []statusRow
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := statusRow{}
+		for i, c := range columns {
+			if values[i].Valid {
+				row[strings.ToLower(c)] = values[i].String
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// object 告警对象：备库的复制通道，或无法获取DN信息的部署、集群
+func (r ReplicaResult) object() string {
+	return strings.Join([]string{r.Deployment, r.ClusterID, r.Addr, r.Channel}, "|")
+}
+
+// newAlarm 生成复制告警。告警内容不含延迟、事务数等每轮变化的值，避免每轮都被视为告警更新
+func (m *ReplicationMonitor) newAlarm(r ReplicaResult, problem string, since string) alarm.Alarm {
+	where := fmt.Sprintf("部署 %s 集群 %s DN组 %s 备库 %s", r.Deployment, r.Cluster, r.Group, r.Addr)
+	if r.Channel != "" {
+		where += " 通道 " + r.Channel
+	}
+	var code, level int
+	var content string
+	switch problem {
+	case problemIO:
+		code, level = m.cfg.Codes.IOStopped, 2
+		content = where + " 复制IO线程未运行"
+	case problemSQL:
+		code, level = m.cfg.Codes.SQLStopped, 2
+		content = where + " 复制SQL线程未运行"
+	case problemLag:
+		code, level = m.cfg.Codes.Lag, 3
+		content = fmt.Sprintf("%s 复制延迟超过 %d 秒", where, m.cfg.MaxLag)
+	case problemGTID:
+		code, level = m.cfg.Codes.GTIDGap, 3
+		content = fmt.Sprintf("%s 与主库 %s 的GTID不一致：缺少超过 %d 个事务或存在主库没有的事务", where, r.Master, m.cfg.MaxGTIDGap)
+	case problemCheck:
+		code, level = m.cfg.Codes.CheckFailed, 3
+		switch {
+		case r.Addr != "":
+			content = where + " 无法检查复制状态"
+		case r.ClusterID != "":
+			content = fmt.Sprintf("部署 %s 集群 %s 无法从MDS获取DN信息", r.Deployment, r.Cluster)
+		default:
+			content = fmt.Sprintf("部署 %s 无法从MDS获取DN信息", r.Deployment)
+		}
+	}
+	dstInfo := r.Addr
+	if dstInfo == "" {
+		dstInfo = r.Deployment
+	}
+	return alarm.Alarm{
+		Alarmid:     SyntheticID(r.object(), code),
+		Alarmsource: "monitor",
+		Code:        code,
+		Almlevel:    level,
+		Content:     content,
+		Createtime:  since,
+		Updatetime:  since,
+		Reserve4: alarm.Reserve4{
+			DstInfo:        dstInfo,
+			DstType:        "DN",
+			DstClusterId:   r.ClusterID,
+			DstClusterName: r.Cluster,
+			DstGroupId:     r.Group,
+		},
+	}
+}
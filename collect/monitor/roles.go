@@ -0,0 +1,394 @@
+package monitor
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/config"
+	"GoldenDB/connect"
+	"GoldenDB/redact"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RoleGroup 一个DN组最近一轮的角色检查结果。部署或集群的DN信息无法获取时，Group为空，Error为原因
+type RoleGroup struct {
+	Deployment string   `json:"deployment"`
+	ClusterID  string   `json:"clusterId,omitempty"`
+	Cluster    string   `json:"cluster,omitempty"`
+	Group      string   `json:"group,omitempty"`
+	Masters    int      `json:"masters"`  // MDS中登记的主库数
+	Writable   int      `json:"writable"` // 实际可写（read_only=OFF）的DN数
+	DNs        []RoleDN `json:"dns,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Problems   []string `json:"problems,omitempty"` // 本轮组级异常: no_master | multi_master | split_brain | error
+	CheckedAt  string   `json:"checkedAt"`
+}
+
+// RoleDN 一个DN在MDS中的角色和实际状态，无法获取的开关为nil
+type RoleDN struct {
+	Addr          string   `json:"addr"`
+	Role          string   `json:"role"` // MDS中登记的角色
+	ReadOnly      *bool    `json:"readOnly,omitempty"`
+	SuperReadOnly *bool    `json:"superReadOnly,omitempty"`
+	Sources       []string `json:"sources,omitempty"` // 复制通道的复制源
+	IORunning     string   `json:"ioRunning,omitempty"`
+	SQLRunning    string   `json:"sqlRunning,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	Notes         []string `json:"notes,omitempty"`    // 异常说明
+	Problems      []string `json:"problems,omitempty"` // 本轮异常: master_readonly | slave_writable | replication | replica_status | error
+
+	statusFailed bool // 只读开关已读取，但复制状态读取失败，不检查复制配置
+}
+
+// 角色异常类型
+const (
+	problemNoMaster       = "no_master"
+	problemMultiMaster    = "multi_master"
+	problemSplitBrain     = "split_brain"
+	problemMasterReadOnly = "master_readonly"
+	problemSlaveWritable  = "slave_writable"
+	problemReplication    = "replication"
+	problemReplicaStatus  = "replica_status"
+)
+
+// writable DN是否可写
+func (d RoleDN) writable() bool {
+	return d.ReadOnly != nil && !*d.ReadOnly
+}
+
+func (d *RoleDN) add(problem string, note string, args ...interface{}) {
+	d.Problems = append(d.Problems, problem)
+	d.Notes = append(d.Notes, fmt.Sprintf(note, args...))
+}
+
+// RoleAudit 定时比较MDS中登记的DN角色与DN实际的只读设置和复制配置，
+// 连续 trigger 轮异常时生成告警，恢复后告警随之消除
+type RoleAudit struct {
+	cfg    config.RoleAudit
+	mds    []connect.MDSDemo
+	dnConn config.DBConn
+
+	mu      sync.Mutex
+	tracker tracker // 按 检查对象|异常类型 记录连续异常轮数
+	last    []RoleGroup
+}
+
+// NewRoleAudit 创建角色检查器，dnConn为DN的连接设置
+func NewRoleAudit(cfg config.RoleAudit, mds []connect.MDSDemo, dnConn config.DBConn) *RoleAudit {
+	return &RoleAudit{
+		cfg:     cfg,
+		mds:     mds,
+		dnConn:  dnConn,
+		tracker: newTracker(cfg.Trigger),
+	}
+}
+
+// Insight 告警所属的insight
+func (a *RoleAudit) Insight() string {
+	return a.cfg.Insight
+}
+
+// Interval 检查间隔
+func (a *RoleAudit) Interval() time.Duration {
+	return time.Duration(a.cfg.Interval) * time.Second
+}
+
+// Check 检查全部DN组，返回当前应处于告警状态的告警
+func (a *RoleAudit) Check(ctx context.Context) []alarm.Alarm {
+	results := a.Collect(ctx)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.last = results
+	var alarms []alarm.Alarm
+	// 同一DN可能有多条同类异常（如多个复制源都不符），每轮每个键只计一次
+	seen := map[string]bool{}
+	observe := func(key string, g RoleGroup, dn *RoleDN, p string) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if fire, since := a.tracker.observe(key, g.CheckedAt); fire {
+			alarms = append(alarms, a.newAlarm(g, dn, p, since))
+		}
+	}
+	for _, g := range results {
+		for _, p := range g.Problems {
+			observe(g.object()+"|"+p, g, nil, p)
+		}
+		for i := range g.DNs {
+			dn := &g.DNs[i]
+			for _, p := range dn.Problems {
+				observe(g.object()+"|"+dn.Addr+"|"+p, g, dn, p)
+			}
+		}
+	}
+	a.tracker.finish()
+	return alarms
+}
+
+// Status 最近一轮的检查结果
+func (a *RoleAudit) Status() []RoleGroup {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]RoleGroup(nil), a.last...)
+}
+
+// Collect 执行一轮检查并返回各DN组的结果，整轮不超过配置的timeout
+func (a *RoleAudit) Collect(ctx context.Context) []RoleGroup {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(a.cfg.Timeout)*time.Second)
+	defer cancel()
+	now := time.Now().Format("2006-01-02 15:04:05")
+	groups, failures := discoverGroups(ctx, a.mds)
+	var results []RoleGroup
+	for _, f := range failures {
+		results = append(results, RoleGroup{Deployment: f.deployment, ClusterID: f.clusterID, Cluster: f.cluster,
+			Masters: -1, Writable: -1, Error: f.reason, Problems: []string{problemCheck}, CheckedAt: now})
+	}
+	groupResults := make([]RoleGroup, len(groups))
+	eachGroup(groups, a.cfg.Concurrency, func(i int, g dnGroup) {
+		groupResults[i] = a.checkGroup(ctx, g)
+		groupResults[i].CheckedAt = now
+	})
+	return append(results, groupResults...)
+}
+
+// checkGroup 检查一个DN组
+func (a *RoleAudit) checkGroup(ctx context.Context, g dnGroup) RoleGroup {
+	r := RoleGroup{Deployment: g.deployment, ClusterID: g.cluster.ID, Cluster: g.cluster.Name, Group: g.group.ID}
+	masters, _ := g.masters()
+	r.Masters = len(masters)
+	switch {
+	case r.Masters == 0:
+		r.Problems = append(r.Problems, problemNoMaster)
+	case r.Masters > 1:
+		r.Problems = append(r.Problems, problemMultiMaster)
+	}
+
+	for _, dn := range g.group.DNs {
+		d := RoleDN{Addr: dn.Addr, Role: dn.Role}
+		a.inspect(ctx, &d, dnNode(dn, g.mds, a.dnConn))
+		r.DNs = append(r.DNs, d)
+	}
+
+	// MDS中只有一个主库时，备库的复制源应是该主库
+	var master string
+	if len(masters) == 1 {
+		master = masters[0].Addr
+	}
+	for i := range r.DNs {
+		d := &r.DNs[i]
+		if d.writable() {
+			r.Writable++
+		}
+		if d.Error != "" {
+			continue
+		}
+		if d.Role == "master" {
+			a.auditMaster(ctx, d, r.DNs)
+		} else {
+			a.auditSlave(ctx, d, master)
+		}
+	}
+	if r.Writable > 1 {
+		r.Problems = append(r.Problems, problemSplitBrain)
+	}
+	if len(r.Problems) > 0 && logger != nil {
+		logger.With("cluster", r.Cluster, "group", r.Group).Warn("DN组角色异常: %s, MDS登记主库数: %d, 可写DN数: %d", strings.Join(r.Problems, ","), r.Masters, r.Writable)
+	}
+	return r
+}
+
+// inspect 读取DN的只读开关和复制配置
+func (a *RoleAudit) inspect(ctx context.Context, d *RoleDN, n connect.Node) {
+	failed := func(err error) {
+		d.Error = redact.Error(err)
+		d.Problems = append(d.Problems, problemCheck)
+		if logger != nil {
+			logger.Warn("DN角色检查失败: %s, 错误: %s", d.Addr, d.Error)
+		}
+	}
+	db, err := connect.Connect(ctx, n)
+	if err != nil {
+		failed(err)
+		return
+	}
+	var readOnly bool
+	if err := db.QueryRowContext(ctx, "SELECT @@GLOBAL.read_only").Scan(&readOnly); err != nil {
+		failed(err)
+		return
+	}
+	d.ReadOnly = &readOnly
+	// super_read_only 在5.7之前不存在
+	var superReadOnly bool
+	if err := db.QueryRowContext(ctx, "SELECT @@GLOBAL.super_read_only").Scan(&superReadOnly); err == nil {
+		d.SuperReadOnly = &superReadOnly
+	} else if logger != nil {
+		logger.Debug("读取super_read_only失败: %s, 错误: %v", d.Addr, err)
+	}
+
+	channels, err := replicaStatus(ctx, db)
+	if err != nil {
+		// 只读开关已读取，仍检查主库只读、备库可写和脑裂，复制状态单独报告
+		d.statusFailed = true
+		d.add(problemReplicaStatus, "读取复制状态失败: %s", redact.Error(err))
+		if logger != nil {
+			logger.Warn("DN复制状态读取失败: %s, 错误: %s", d.Addr, redact.Error(err))
+		}
+		return
+	}
+	var io, sqlThread []string
+	for _, row := range channels {
+		if host := row.get("source_host", "master_host"); host != "" {
+			d.Sources = append(d.Sources, net.JoinHostPort(host, row.get("source_port", "master_port")))
+		}
+		io = append(io, row.get("replica_io_running", "slave_io_running"))
+		sqlThread = append(sqlThread, row.get("replica_sql_running", "slave_sql_running"))
+	}
+	d.IORunning = strings.Join(io, ",")
+	d.SQLRunning = strings.Join(sqlThread, ",")
+}
+
+// auditMaster 主库应可写，且不应从同组的其它DN复制。从组外（如灾备集群）复制不视为异常
+func (a *RoleAudit) auditMaster(ctx context.Context, d *RoleDN, group []RoleDN) {
+	if d.ReadOnly != nil && *d.ReadOnly {
+		name := "read_only"
+		if d.SuperReadOnly != nil && *d.SuperReadOnly {
+			name = "super_read_only"
+		}
+		d.add(problemMasterReadOnly, "MDS登记为主库，但 %s=ON", name)
+	}
+	for _, source := range d.Sources {
+		for _, other := range group {
+			if other.Addr != d.Addr && sameAddr(ctx, source, other.Addr) {
+				d.add(problemReplication, "MDS登记为主库，但正在从同组的 %s 复制", other.Addr)
+			}
+		}
+	}
+}
+
+// auditSlave 备库应只读，并从组内主库复制
+func (a *RoleAudit) auditSlave(ctx context.Context, d *RoleDN, master string) {
+	if d.writable() {
+		d.add(problemSlaveWritable, "MDS登记为备库，但 read_only=OFF")
+	}
+	if d.statusFailed {
+		return
+	}
+	if len(d.Sources) == 0 {
+		d.add(problemReplication, "MDS登记为备库，但未配置复制")
+		return
+	}
+	if master == "" {
+		return
+	}
+	for _, source := range d.Sources {
+		if sameAddr(ctx, source, master) {
+			return
+		}
+	}
+	d.add(problemReplication, "复制源为 %s，而MDS登记的主库是 %s", strings.Join(d.Sources, ","), master)
+}
+
+// sameAddr 判断两个 host:port 是否指向同一节点，主机名不同时按解析出的IP比较
+func sameAddr(ctx context.Context, a, b string) bool {
+	if a == b {
+		return true
+	}
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil || portA != portB {
+		return false
+	}
+	if hostA == hostB {
+		return true
+	}
+	ipsA, errA := net.DefaultResolver.LookupHost(ctx, hostA)
+	ipsB, errB := net.DefaultResolver.LookupHost(ctx, hostB)
+	if errA != nil || errB != nil {
+		return false
+	}
+	for _, x := range ipsA {
+		for _, y := range ipsB {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// object 告警对象：DN组，或无法获取DN信息的部署、集群
+func (g RoleGroup) object() string {
+	return strings.Join([]string{g.Deployment, g.ClusterID, g.Group}, "|")
+}
+
+// newAlarm 生成角色告警，dn为nil时是组级告警。告警内容不含每轮可能变化的值
+func (a *RoleAudit) newAlarm(g RoleGroup, dn *RoleDN, problem string, since string) alarm.Alarm {
+	where := fmt.Sprintf("部署 %s 集群 %s DN组 %s", g.Deployment, g.Cluster, g.Group)
+	object, dstInfo := g.object(), where
+	if dn != nil {
+		where += " DN " + dn.Addr
+		object, dstInfo = object+"|"+dn.Addr, dn.Addr
+	}
+	var code, level int
+	var content string
+	switch problem {
+	case problemNoMaster:
+		code, level = a.cfg.Codes.NoMaster, 2
+		content = where + " 在MDS中没有主库"
+	case problemMultiMaster:
+		code, level = a.cfg.Codes.MultiMaster, 2
+		content = where + " 在MDS中登记了多个主库，可能脑裂"
+	case problemSplitBrain:
+		code, level = a.cfg.Codes.SplitBrain, 1
+		content = where + " 有多个DN可写（read_only=OFF），可能脑裂"
+	case problemMasterReadOnly:
+		code, level = a.cfg.Codes.MasterReadOnly, 2
+		content = where + " 在MDS中为主库，但处于只读状态"
+	case problemSlaveWritable:
+		code, level = a.cfg.Codes.SlaveWritable, 2
+		content = where + " 在MDS中为备库，但可写"
+	case problemReplication:
+		code, level = a.cfg.Codes.Replication, 3
+		content = where + " 的复制配置与MDS中的角色不符"
+	case problemReplicaStatus:
+		code, level = a.cfg.Codes.ReplicaStatus, 3
+		content = where + " 无法读取复制状态"
+	case problemCheck:
+		code, level = a.cfg.Codes.CheckFailed, 3
+		switch {
+		case dn != nil:
+			content = where + " 无法检查角色状态"
+		case g.ClusterID != "":
+			content = fmt.Sprintf("部署 %s 集群 %s 无法从MDS获取DN信息", g.Deployment, g.Cluster)
+		default:
+			content = fmt.Sprintf("部署 %s 无法从MDS获取DN信息", g.Deployment)
+		}
+		if dn == nil {
+			dstInfo = g.Deployment
+		}
+	}
+	return alarm.Alarm{
+		Alarmid:     SyntheticID(object, code),
+		Alarmsource: "monitor",
+		Code:        code,
+		Almlevel:    level,
+		Content:     content,
+		Createtime:  since,
+		Updatetime:  since,
+		Reserve4: alarm.Reserve4{
+			DstInfo:        dstInfo,
+			DstType:        "DN",
+			DstClusterId:   g.ClusterID,
+			DstClusterName: g.Cluster,
+			DstGroupId:     g.Group,
+		},
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package main
+
+// watchLogReopen 当前平台没有SIGUSR1，不支持外部触发重新打开日志
+func watchLogReopen() {}
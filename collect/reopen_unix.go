@@ -0,0 +1,27 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchLogReopen 收到SIGUSR1时重新打开日志文件
+func watchLogReopen() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	go func() {
+		for range ch {
+			if logger == nil {
+				continue
+			}
+			if err := logger.Reopen(); err != nil {
+				logger.Error("重新打开日志文件失败: %v", err)
+				continue
+			}
+			logger.Info("已重新打开日志文件")
+		}
+	}()
+}
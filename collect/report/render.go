@@ -0,0 +1,183 @@
+package report
+
+import (
+	"GoldenDB/alarm"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// SuggestedRules 将建议转换为可直接粘贴到 alarm_filter.json 的规则
+func (r *Report) SuggestedRules() []alarm.Filter {
+	var rules []alarm.Filter
+	for _, s := range r.Suggestions {
+		rules = append(rules, alarm.Filter{
+			Name:    fmt.Sprintf("自动建议: 代码%d短时自动恢复", s.Code),
+			Enabled: true,
+			Filters: []alarm.Rule{{Field: "code", Operator: "equals", Value: s.Code}},
+			Logic:   "OR",
+			Action:  s.Action,
+		})
+	}
+	return rules
+}
+
+func (r *Report) suggestedRulesJSON() string {
+	b, err := json.MarshalIndent(r.SuggestedRules(), "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// WriteMarkdown 输出Markdown格式报告
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	levels := r.Levels()
+
+	fmt.Fprintf(&b, "# 告警统计与噪声报告\n\n")
+	fmt.Fprintf(&b, "- 统计区间: %s ~ %s\n", r.From.Format(timeLayout), r.To.Format(timeLayout))
+	fmt.Fprintf(&b, "- 短时恢复阈值: %s，抖动窗口: %s\n\n", r.Options.ShortClear, r.Options.FlapWindow)
+
+	fmt.Fprintf(&b, "## 按集群和告警代码统计\n\n")
+	fmt.Fprintf(&b, "| insight | 集群 | 代码 | 触发 | 恢复 | 平均恢复时间 | 抖动率 | 噪声主机 |")
+	for _, level := range levels {
+		fmt.Fprintf(&b, " %s(小时) |", levelName(level))
+	}
+	fmt.Fprintf(&b, "\n|---|---|---|---|---|---|---|---|")
+	for range levels {
+		fmt.Fprintf(&b, "---|")
+	}
+	fmt.Fprintln(&b)
+	for _, g := range r.Groups {
+		fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %s | %.1f%% | %s |",
+			g.Insight, g.Cluster, g.Code, g.Triggers, g.Resolves, formatDuration(g.MTTR), g.FlapRate()*100, joinHosts(g.TopHosts))
+		for _, level := range levels {
+			fmt.Fprintf(&b, " %.2f |", g.LevelHours[level])
+		}
+		fmt.Fprintln(&b)
+	}
+
+	fmt.Fprintf(&b, "\n## 过滤/屏蔽建议\n\n")
+	if len(r.Suggestions) == 0 {
+		fmt.Fprintf(&b, "没有满足条件的建议。\n")
+	} else {
+		fmt.Fprintf(&b, "| 代码 | 建议动作 | 原因 |\n|---|---|---|\n")
+		for _, s := range r.Suggestions {
+			fmt.Fprintf(&b, "| %d | %s | %s |\n", s.Code, s.Action, s.Reason)
+		}
+		fmt.Fprintf(&b, "\n可加入 `config/alarm_filter.json` 的规则：\n\n```json\n%s\n```\n", r.suggestedRulesJSON())
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration":  formatDuration,
+	"hosts":     joinHosts,
+	"levelName": levelName,
+	"percent":   func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"hours":     func(m map[int]float64, level int) string { return fmt.Sprintf("%.2f", m[level]) },
+	"time":      func(t time.Time) string { return t.Format(timeLayout) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>告警统计与噪声报告</title>
+<style>
+body { font-family: sans-serif; margin: 24px; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+pre { background: #f7f7f7; padding: 12px; }
+</style>
+</head>
+<body>
+<h1>告警统计与噪声报告</h1>
+<p>统计区间: {{time .Report.From}} ~ {{time .Report.To}}，短时恢复阈值: {{.Report.Options.ShortClear}}，抖动窗口: {{.Report.Options.FlapWindow}}</p>
+<h2>按集群和告警代码统计</h2>
+<table>
+<tr><th>insight</th><th>集群</th><th>代码</th><th>触发</th><th>恢复</th><th>平均恢复时间</th><th>抖动率</th><th>噪声主机</th>{{range .Levels}}<th>{{levelName .}}(小时)</th>{{end}}</tr>
+{{range $g := .Report.Groups}}<tr><td>{{$g.Insight}}</td><td>{{$g.Cluster}}</td><td>{{$g.Code}}</td><td>{{$g.Triggers}}</td><td>{{$g.Resolves}}</td><td>{{duration $g.MTTR}}</td><td>{{percent $g.FlapRate}}</td><td>{{hosts $g.TopHosts}}</td>{{range $.Levels}}<td>{{hours $g.LevelHours .}}</td>{{end}}</tr>
+{{end}}</table>
+<h2>过滤/屏蔽建议</h2>
+{{if .Report.Suggestions}}<table>
+<tr><th>代码</th><th>建议动作</th><th>原因</th></tr>
+{{range .Report.Suggestions}}<tr><td>{{.Code}}</td><td>{{.Action}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>
+<p>可加入 config/alarm_filter.json 的规则：</p>
+<pre>{{.Rules}}</pre>
+{{else}}<p>没有满足条件的建议。</p>{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML 输出HTML格式报告
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, struct {
+		Report *Report
+		Levels []int
+		Rules  string
+	}{r, r.Levels(), r.suggestedRulesJSON()})
+}
+
+// WriteCSV 输出CSV格式报告，每行一个集群/代码分组，建议动作作为最后一列
+func (r *Report) WriteCSV(w io.Writer) error {
+	levels := r.Levels()
+	actions := make(map[int]string)
+	for _, s := range r.Suggestions {
+		actions[s.Code] = s.Action
+	}
+
+	writer := csv.NewWriter(w)
+	header := []string{"insight", "cluster", "code", "triggers", "resolves", "mttr_seconds", "flap_rate", "top_hosts"}
+	for _, level := range levels {
+		header = append(header, fmt.Sprintf("hours_level_%d", level))
+	}
+	header = append(header, "suggestion")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, g := range r.Groups {
+		record := []string{
+			g.Insight,
+			g.Cluster,
+			fmt.Sprintf("%d", g.Code),
+			fmt.Sprintf("%d", g.Triggers),
+			fmt.Sprintf("%d", g.Resolves),
+			fmt.Sprintf("%.0f", g.MTTR.Seconds()),
+			fmt.Sprintf("%.4f", g.FlapRate()),
+			joinHosts(g.TopHosts),
+		}
+		for _, level := range levels {
+			record = append(record, fmt.Sprintf("%.2f", g.LevelHours[level]))
+		}
+		record = append(record, actions[g.Code])
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func levelName(level int) string {
+	if name, ok := alarm.AlarmLevelMap[level]; ok {
+		return name
+	}
+	return fmt.Sprintf("级别%d", level)
+}
+
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,263 @@
+package report
+
+import (
+	"GoldenDB/history"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options 统计参数
+type Options struct {
+	From        time.Time
+	To          time.Time
+	ShortClear  time.Duration // 在此时间内自动恢复视为“短时告警”
+	FlapWindow  time.Duration // 恢复后在此时间内再次触发视为抖动
+	MinTriggers int           // 生成建议所需的最少触发次数
+	TopHosts    int           // 每组展示的噪声主机数量
+}
+
+// GroupStat 按集群和告警代码汇总的统计
+type GroupStat struct {
+	Insight    string
+	Cluster    string
+	Code       int
+	Triggers   int
+	Resolves   int
+	ShortClear int                   // 短时间内自动恢复的次数
+	Flaps      int                   // 抖动次数
+	MTTR       time.Duration         // 平均恢复时间
+	LevelHours map[int]float64       // 各级别累计持续小时数
+	TopHosts   []HostCount           // 触发次数最多的主机
+	totalTTR   time.Duration         // 恢复时间之和
+	hosts      map[string]int        // 主机触发次数
+	levels     map[int]time.Duration // 各级别累计持续时间
+}
+
+// FlapRate 抖动率：抖动次数/触发次数
+func (g *GroupStat) FlapRate() float64 {
+	if g.Triggers == 0 {
+		return 0
+	}
+	return float64(g.Flaps) / float64(g.Triggers)
+}
+
+// HostCount 主机及其触发次数
+type HostCount struct {
+	Host  string
+	Count int
+}
+
+func (h HostCount) String() string {
+	return fmt.Sprintf("%s(%d)", h.Host, h.Count)
+}
+
+// Suggestion 针对某个告警代码的过滤/屏蔽建议
+type Suggestion struct {
+	Code       int
+	Action     string // "filter" 或 "silence"
+	Triggers   int
+	ShortClear int
+	Reason     string
+}
+
+// Report 统计报告
+type Report struct {
+	From        time.Time
+	To          time.Time
+	Options     Options
+	Groups      []*GroupStat
+	Suggestions []Suggestion
+}
+
+// openAlarm 统计过程中仍处于未恢复状态的告警
+type openAlarm struct {
+	group     *GroupStat
+	host      string
+	level     int
+	since     time.Time // 触发时间
+	levelFrom time.Time // 当前级别开始时间
+}
+
+// Build 根据历史事件生成统计报告，events需按时间升序
+func Build(events []history.Event, opts Options) *Report {
+	groups := make(map[string]*GroupStat)
+	open := make(map[string]*openAlarm)
+	lastResolve := make(map[string]time.Time) // insight|code|host -> 最近一次恢复时间
+
+	groupOf := func(ev history.Event) *GroupStat {
+		key := fmt.Sprintf("%s|%s|%d", ev.Insight, ev.Cluster, ev.Code)
+		g, ok := groups[key]
+		if !ok {
+			g = &GroupStat{
+				Insight:    ev.Insight,
+				Cluster:    ev.Cluster,
+				Code:       ev.Code,
+				LevelHours: make(map[int]float64),
+				hosts:      make(map[string]int),
+				levels:     make(map[int]time.Duration),
+			}
+			groups[key] = g
+		}
+		return g
+	}
+
+	for _, ev := range events {
+		alarmKey := ev.Key()
+		flapKey := fmt.Sprintf("%s|%d|%s", ev.Insight, ev.Code, ev.Host)
+
+		switch ev.Action {
+		case history.ActionTrigger:
+			// 推送失败后的重试会重复记录trigger，已处于打开状态的忽略
+			if _, ok := open[alarmKey]; ok {
+				continue
+			}
+			g := groupOf(ev)
+			g.Triggers++
+			g.hosts[ev.Host]++
+			if t, ok := lastResolve[flapKey]; ok && ev.Time.Sub(t) <= opts.FlapWindow {
+				g.Flaps++
+			}
+			open[alarmKey] = &openAlarm{group: g, host: ev.Host, level: ev.Level, since: ev.Time, levelFrom: ev.Time}
+		case history.ActionUpdate:
+			a, ok := open[alarmKey]
+			if !ok || a.level == ev.Level {
+				continue
+			}
+			a.group.levels[a.level] += ev.Time.Sub(a.levelFrom)
+			a.level = ev.Level
+			a.levelFrom = ev.Time
+		case history.ActionResolve:
+			a, ok := open[alarmKey]
+			if !ok {
+				continue
+			}
+			delete(open, alarmKey)
+			ttr := ev.Time.Sub(a.since)
+			a.group.Resolves++
+			a.group.totalTTR += ttr
+			a.group.levels[a.level] += ev.Time.Sub(a.levelFrom)
+			if ttr <= opts.ShortClear {
+				a.group.ShortClear++
+			}
+			lastResolve[flapKey] = ev.Time
+		}
+	}
+
+	// 未恢复的告警持续时间计算到报告结束时间
+	for _, a := range open {
+		a.group.levels[a.level] += opts.To.Sub(a.levelFrom)
+	}
+
+	r := &Report{From: opts.From, To: opts.To, Options: opts}
+	for _, g := range groups {
+		if g.Resolves > 0 {
+			g.MTTR = g.totalTTR / time.Duration(g.Resolves)
+		}
+		for level, d := range g.levels {
+			g.LevelHours[level] = d.Hours()
+		}
+		g.TopHosts = topHosts(g.hosts, opts.TopHosts)
+		r.Groups = append(r.Groups, g)
+	}
+	sort.Slice(r.Groups, func(i, j int) bool {
+		a, b := r.Groups[i], r.Groups[j]
+		if a.Triggers != b.Triggers {
+			return a.Triggers > b.Triggers
+		}
+		if a.Insight != b.Insight {
+			return a.Insight < b.Insight
+		}
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Code < b.Code
+	})
+	r.Suggestions = suggest(r.Groups, opts)
+	return r
+}
+
+func topHosts(hosts map[string]int, n int) []HostCount {
+	var list []HostCount
+	for host, count := range hosts {
+		if host == "" {
+			continue
+		}
+		list = append(list, HostCount{Host: host, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Host < list[j].Host
+	})
+	if n > 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// suggest 对大部分情况下短时间内自动恢复的告警代码给出建议：
+// 九成以上短时自动恢复建议直接过滤，六成以上建议屏蔽（保留历史）
+func suggest(groups []*GroupStat, opts Options) []Suggestion {
+	byCode := make(map[int]*Suggestion)
+	for _, g := range groups {
+		s, ok := byCode[g.Code]
+		if !ok {
+			s = &Suggestion{Code: g.Code}
+			byCode[g.Code] = s
+		}
+		s.Triggers += g.Triggers
+		s.ShortClear += g.ShortClear
+	}
+
+	var result []Suggestion
+	for _, s := range byCode {
+		if s.Triggers < opts.MinTriggers || s.Triggers == 0 {
+			continue
+		}
+		ratio := float64(s.ShortClear) / float64(s.Triggers)
+		switch {
+		case ratio >= 0.9:
+			s.Action = "filter"
+		case ratio >= 0.6:
+			s.Action = "silence"
+		default:
+			continue
+		}
+		s.Reason = fmt.Sprintf("%d 次触发中 %d 次（%.0f%%）在 %s 内自动恢复", s.Triggers, s.ShortClear, ratio*100, opts.ShortClear)
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Triggers != result[j].Triggers {
+			return result[i].Triggers > result[j].Triggers
+		}
+		return result[i].Code < result[j].Code
+	})
+	return result
+}
+
+// Levels 返回报告中出现过的所有告警级别，升序
+func (r *Report) Levels() []int {
+	set := make(map[int]struct{})
+	for _, g := range r.Groups {
+		for level := range g.LevelHours {
+			set[level] = struct{}{}
+		}
+	}
+	var levels []int
+	for level := range set {
+		levels = append(levels, level)
+	}
+	sort.Ints(levels)
+	return levels
+}
+
+func joinHosts(hosts []HostCount) string {
+	parts := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		parts = append(parts, h.String())
+	}
+	return strings.Join(parts, ", ")
+}
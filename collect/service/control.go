@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 控制套接字上的命令
+const cmdStatus = "status"
+
+// ServeControl 在本地unix套接字path上提供状态查询，每个连接发送一行命令，
+// 服务端返回JSON后关闭连接。ctx结束时关闭监听并删除套接字文件
+func ServeControl(ctx context.Context, path string, status func() interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建控制套接字目录失败: %w", err)
+	}
+	// 上次异常退出留下的套接字文件，调用方已通过PID文件锁确认没有其他实例
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("监听控制套接字失败: %w", err)
+	}
+	os.Chmod(path, 0600)
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handleControl(conn, status)
+		}
+	}()
+	return nil
+}
+
+func handleControl(conn net.Conn, status func() interface{}) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return
+	}
+	enc := json.NewEncoder(conn)
+	switch strings.TrimSpace(line) {
+	case cmdStatus:
+		enc.Encode(status())
+	default:
+		enc.Encode(map[string]string{"error": "未知命令: " + strings.TrimSpace(line)})
+	}
+}
+
+// QueryStatus 通过控制套接字查询运行中实例的状态，返回原始JSON
+func QueryStatus(path string, timeout time.Duration) ([]byte, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("连接控制套接字失败: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write([]byte(cmdStatus + "\n")); err != nil {
+		return nil, fmt.Errorf("发送状态查询失败: %w", err)
+	}
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("读取状态失败: %w", err)
+	}
+	return data, nil
+}
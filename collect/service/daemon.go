@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Spawn 以后台方式启动当前程序，args为子进程参数，标准输出和标准错误追加写入consoleLog
+func Spawn(args []string, consoleLog string) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("获取程序路径失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(consoleLog), 0755); err != nil {
+		return 0, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	out, err := os.OpenFile(consoleLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("打开控制台日志失败: %w", err)
+	}
+	defer out.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("启动服务失败: %w", err)
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+	return pid, nil
+}
+
+// Terminate 向pid发送SIGTERM并等待PID文件锁释放，超时返回错误
+func Terminate(pidFile string, pid int, timeout time.Duration) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return fmt.Errorf("查找进程失败: %w", err)
+	}
+	if err := proc.Signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("发送停止信号失败: %w", err)
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		running, err := RunningPID(pidFile)
+		if err != nil {
+			return err
+		}
+		if running == 0 {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("等待服务停止超时 (PID: %d)", pid)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notify 向systemd发送状态通知（如 "READY=1"、"STOPPING=1"），
+// 未由systemd以 Type=notify 启动（没有 NOTIFY_SOCKET）时不做任何事
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// 以@开头的是抽象命名空间地址
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval 返回systemd要求的看门狗间隔（WatchdogSec），未启用时返回0
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// RunWatchdog 按看门狗间隔的一半发送心跳，healthy返回false时不发送，
+// 由systemd在超时后重启服务。未启用看门狗时立即返回
+func RunWatchdog(ctx context.Context, healthy func() bool) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if healthy() {
+				Notify("WATCHDOG=1")
+			}
+		}
+	}
+}
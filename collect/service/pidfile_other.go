@@ -0,0 +1,20 @@
+//go:build !unix
+
+package service
+
+import "fmt"
+
+// PIDFile 当前平台不支持加锁的PID文件
+type PIDFile struct{}
+
+// AcquirePIDFile 当前平台不加锁，直接返回
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	return &PIDFile{}, nil
+}
+
+func (p *PIDFile) Release() {}
+
+// RunningPID 当前平台不支持
+func RunningPID(path string) (int, error) {
+	return 0, fmt.Errorf("当前平台不支持服务命令，请使用 foreground 前台运行")
+}
@@ -0,0 +1,86 @@
+//go:build unix
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// PIDFile 加锁的PID文件，进程持有文件锁期间视为在运行，
+// 进程异常退出时锁由系统释放，不会留下误判为运行中的旧PID
+type PIDFile struct {
+	path string
+	file *os.File
+}
+
+// AcquirePIDFile 锁定PID文件并写入当前进程号，已有实例持有锁时返回错误
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建PID文件目录失败: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开PID文件失败: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			pid, _ := readPID(path)
+			return nil, fmt.Errorf("服务已在运行 (PID: %d)", pid)
+		}
+		return nil, fmt.Errorf("锁定PID文件失败: %w", err)
+	}
+	if err := file.Truncate(0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("写入PID文件失败: %w", err)
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("写入PID文件失败: %w", err)
+	}
+	return &PIDFile{path: path, file: file}, nil
+}
+
+// Release 删除PID文件并释放锁
+func (p *PIDFile) Release() {
+	os.Remove(p.path)
+	syscall.Flock(int(p.file.Fd()), syscall.LOCK_UN)
+	p.file.Close()
+}
+
+// RunningPID 返回持有PID文件锁的进程号，没有实例在运行时返回0
+func RunningPID(path string) (int, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("打开PID文件失败: %w", err)
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err == nil {
+		// 能拿到锁说明没有实例在运行，文件是异常退出留下的
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		return 0, nil
+	} else if err != syscall.EWOULDBLOCK {
+		return 0, fmt.Errorf("检查PID文件失败: %w", err)
+	}
+	return readPID(path)
+}
+
+func readPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("读取PID文件失败: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("PID文件内容无效: %w", err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnitOptions 生成systemd unit文件的参数
+type UnitOptions struct {
+	Exec        string // 可执行文件绝对路径
+	Config      string // 配置文件路径
+	WorkDir     string // 工作目录，配置中日志、数据等相对路径以此为准
+	User        string // 运行用户，为空时使用root
+	WatchdogSec int    // 看门狗超时（秒），0表示不启用
+	StopTimeout int    // 停止超时（秒），需大于推送队列的 drain_timeout
+}
+
+// UnitFile 生成以 Type=notify 运行 foreground 命令的systemd unit文件
+func UnitFile(opts UnitOptions) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=GoldenDB 告警采集推送服务\n")
+	fmt.Fprintf(&b, "After=network-online.target\n")
+	fmt.Fprintf(&b, "Wants=network-online.target\n\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "Type=notify\n")
+	fmt.Fprintf(&b, "NotifyAccess=main\n")
+	if opts.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", opts.User)
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", opts.WorkDir)
+	fmt.Fprintf(&b, "ExecStart=%s --config %s foreground\n", opts.Exec, opts.Config)
+	fmt.Fprintf(&b, "KillSignal=SIGTERM\n")
+	fmt.Fprintf(&b, "TimeoutStopSec=%d\n", opts.StopTimeout)
+	if opts.WatchdogSec > 0 {
+		fmt.Fprintf(&b, "WatchdogSec=%d\n", opts.WatchdogSec)
+	}
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=5\n\n")
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+	return b.String()
+}
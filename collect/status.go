@@ -0,0 +1,119 @@
+package main
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/ha"
+	"GoldenDB/monitor"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// 实例在主备模式中的角色
+const (
+	roleSingle  = "single"  // 未启用主备
+	roleLeader  = "leader"  // 主实例
+	roleStandby = "standby" // 备实例
+)
+
+// serviceState 运行中实例的状态，供 status 命令和systemd看门狗使用
+type serviceState struct {
+	startedAt    time.Time
+	groups       []*mdsGroup
+	period       time.Duration
+	queryTimeout time.Duration
+	elector      *ha.Elector                 // 未启用主备时为nil
+	cn           *monitor.CNMonitor          // 未启用CN探测时为nil
+	replication  *monitor.ReplicationMonitor // 未启用复制检查时为nil
+	roleAudit    *monitor.RoleAudit          // 未启用DN角色检查时为nil
+
+	dispatcher atomic.Pointer[alarm.Dispatcher] // 正在采集推送时非nil
+	collecting atomic.Int64                     // 本次开始采集的时间（UnixNano）
+}
+
+// statusReport status 命令返回的实例状态
+type statusReport struct {
+	PID         int                     `json:"pid"`
+	StartedAt   string                  `json:"startedAt"`
+	Uptime      string                  `json:"uptime"`
+	Role        string                  `json:"role"`
+	Healthy     bool                    `json:"healthy"`
+	Queue       *alarm.DispatchStats    `json:"queue,omitempty"`
+	Groups      []groupStatus           `json:"groups"`
+	CN          []monitor.CNResult      `json:"cn,omitempty"`
+	Replication []monitor.ReplicaResult `json:"replication,omitempty"`
+	Roles       []monitor.RoleGroup     `json:"roles,omitempty"`
+}
+
+func newServiceState(groups []*mdsGroup, period, queryTimeout time.Duration) *serviceState {
+	return &serviceState{startedAt: time.Now(), groups: groups, period: period, queryTimeout: queryTimeout}
+}
+
+// collectStarted 记录开始采集推送
+func (s *serviceState) collectStarted(d *alarm.Dispatcher) {
+	s.collecting.Store(time.Now().UnixNano())
+	s.dispatcher.Store(d)
+}
+
+// collectStopped 记录停止采集推送
+func (s *serviceState) collectStopped() {
+	s.dispatcher.Store(nil)
+}
+
+func (s *serviceState) role() string {
+	if s.elector == nil {
+		return roleSingle
+	}
+	if s.elector.IsLeader() {
+		return roleLeader
+	}
+	return roleStandby
+}
+
+// healthy 正在采集时，每个分组都需在两个周期加一次查询超时内完成过一轮采集；
+// 采集协程卡住时不再发送看门狗心跳，由systemd重启服务
+func (s *serviceState) healthy() bool {
+	if s.dispatcher.Load() == nil {
+		return true
+	}
+	since := time.Unix(0, s.collecting.Load())
+	limit := 2*s.period + s.queryTimeout
+	for _, g := range s.groups {
+		last := g.lastRunTime()
+		if last.Before(since) {
+			last = since
+		}
+		if time.Since(last) > limit {
+			return false
+		}
+	}
+	return true
+}
+
+// report 生成状态报告，由控制套接字调用
+func (s *serviceState) report() interface{} {
+	r := statusReport{
+		PID:       os.Getpid(),
+		StartedAt: s.startedAt.Format("2006-01-02 15:04:05"),
+		Uptime:    time.Since(s.startedAt).Round(time.Second).String(),
+		Role:      s.role(),
+		Healthy:   s.healthy(),
+	}
+	if d := s.dispatcher.Load(); d != nil {
+		stats := d.Stats()
+		r.Queue = &stats
+	}
+	for _, g := range s.groups {
+		r.Groups = append(r.Groups, g.status())
+	}
+	if s.cn != nil {
+		r.CN = s.cn.Status()
+	}
+	if s.replication != nil {
+		r.Replication = s.replication.Status()
+	}
+	if s.roleAudit != nil {
+		r.Roles = s.roleAudit.Status()
+	}
+	return r
+}
@@ -1,17 +1,14 @@
 package test
 
-import "database/sql"
+import (
+	"GoldenDB/connect"
+	"context"
+)
 
-func TestDSN(dsn string) bool {
-	open, err := sql.Open("mysql", dsn)
-	if err != nil {
-		panic(err)
-		return false
-	}
-	err = open.Ping()
-	if err != nil {
-		panic(err)
-		return false
-	}
-	return true
+// TestDSN 测试能否连接节点，使用独立的连接池，测试后关闭；失败时返回的错误中密码已遮盖
+func TestDSN(n connect.Node) error {
+	f := connect.NewFactory()
+	defer f.Close()
+	_, err := f.Connect(context.Background(), n)
+	return err
 }
@@ -0,0 +1,185 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteJSON 输出JSON
+func (t Topology) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// WriteYAML 输出YAML
+func (t Topology) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(t); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// WriteTable 输出表格，每个DN一行，其后列出各集群的Schema
+func (t Topology) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "部署\t集群ID\t集群\t单机\tDN组\tDN\t角色\t地址\t连通")
+	for _, d := range t.Deployments {
+		if d.Error != "" {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\t-\t-\t-\t%s\n", d.Name, d.Error)
+			continue
+		}
+		for _, c := range d.Clusters {
+			if len(c.Groups) == 0 {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t-\t-\t-\t-\t%s\n", d.Name, c.ID, c.Name, c.Issingle, orDash(c.Error))
+				continue
+			}
+			for _, g := range c.Groups {
+				for _, dn := range g.DNs {
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%s\t%s\t%s\t%s\t%s\n",
+						d.Name, c.ID, c.Name, c.Issingle, g.ID, dn.ID, dn.Role, dn.Addr, dn.Reachability.text())
+				}
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "部署\tMDS\t地址\t拓扑来源\t连通")
+	for _, d := range t.Deployments {
+		for _, m := range d.MDS {
+			source := ""
+			if m.Name == d.Source {
+				source = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Name, m.Name, m.Addr, source, m.Reachability.text())
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "部署\t集群\tSchema")
+	for _, d := range t.Deployments {
+		for _, c := range d.Clusters {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Name, c.Name, orDash(strings.Join(c.Schemas, ",")))
+		}
+	}
+	return tw.Flush()
+}
+
+// text 表格中的连通性，未检查时为"-"
+func (r Reachability) text() string {
+	switch {
+	case r.Reachable == nil:
+		return "-"
+	case *r.Reachable:
+		return fmt.Sprintf("正常(%.1fms)", r.LatencyMs)
+	default:
+		return "失败: " + r.CheckErr
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// WriteDOT 输出Graphviz DOT，部署 -> 集群 -> DN组 -> DN，不可达的节点标红
+// 可用 dot -Tsvg topology.dot -o topology.svg 生成图片
+func (t Topology) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [fontname=\"sans-serif\", fontsize=10];\n")
+	for i, d := range t.Deployments {
+		dep := fmt.Sprintf("d%d", i)
+		label := "部署 " + d.Name
+		if d.Error != "" {
+			label += "\\n" + d.Error
+		}
+		fmt.Fprintf(&b, "  %s [shape=box3d, label=%s%s];\n", dep, quote(label), errColor(d.Error != ""))
+		for j, m := range d.MDS {
+			id := fmt.Sprintf("%s_m%d", dep, j)
+			label := fmt.Sprintf("MDS %s\\n%s", m.Name, m.Addr)
+			if m.Name == d.Source {
+				label += "\\n(拓扑来源)"
+			}
+			fmt.Fprintf(&b, "  %s [shape=cylinder, label=%s%s];\n", id, quote(label), reachColor(m.Reachability))
+			fmt.Fprintf(&b, "  %s -> %s [arrowhead=none, style=dashed];\n", id, dep)
+		}
+		for j, c := range d.Clusters {
+			cid := fmt.Sprintf("%s_c%d", dep, j)
+			label := fmt.Sprintf("集群 %s (%s)", c.Name, c.ID)
+			if c.Issingle {
+				label += "\\n单机"
+			}
+			if len(c.Schemas) > 0 {
+				label += "\\nSchema: " + strings.Join(c.Schemas, ", ")
+			}
+			if c.Error != "" {
+				label += "\\n" + c.Error
+			}
+			fmt.Fprintf(&b, "  %s [shape=folder, label=%s%s];\n", cid, quote(label), errColor(c.Error != ""))
+			fmt.Fprintf(&b, "  %s -> %s;\n", dep, cid)
+			for k, g := range c.Groups {
+				gid := fmt.Sprintf("%s_g%d", cid, k)
+				fmt.Fprintf(&b, "  %s [shape=box, style=rounded, label=%s];\n", gid, quote("DN组 "+g.ID))
+				fmt.Fprintf(&b, "  %s -> %s;\n", cid, gid)
+				for l, dn := range g.DNs {
+					nid := fmt.Sprintf("%s_n%d", gid, l)
+					label := fmt.Sprintf("DN %s %s\\n%s", dn.ID, dn.Role, dn.Addr)
+					shape := "ellipse"
+					if dn.Role == "master" {
+						shape = "doubleoctagon"
+					}
+					fmt.Fprintf(&b, "  %s [shape=%s, label=%s%s];\n", nid, shape, quote(label), reachColor(dn.Reachability))
+					fmt.Fprintf(&b, "  %s -> %s;\n", gid, nid)
+				}
+			}
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// quote 生成DOT字符串，保留label中已有的 \n 换行
+func quote(s string) string {
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	return "\"" + s + "\""
+}
+
+func errColor(failed bool) string {
+	if failed {
+		return ", color=red, fontcolor=red"
+	}
+	return ""
+}
+
+func reachColor(r Reachability) string {
+	if r.Reachable == nil {
+		return ""
+	}
+	if *r.Reachable {
+		return ", color=darkgreen"
+	}
+	return ", color=red, fontcolor=red, tooltip=" + strconv.Quote(r.CheckErr)
+}
@@ -0,0 +1,257 @@
+// Package topology 从MDS发现GoldenDB的集群、DN组、DN和Schema，并导出为JSON、YAML、表格或Graphviz DOT
+package topology
+
+import (
+	"GoldenDB/connect"
+	"GoldenDB/info"
+	"GoldenDB/redact"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Topology 全部MDS部署的拓扑
+type Topology struct {
+	GeneratedAt string       `json:"generatedAt" yaml:"generatedAt"`
+	Deployments []Deployment `json:"deployments" yaml:"deployments"`
+}
+
+// Deployment 一个GoldenDB部署（配置中group相同的MDS），拓扑从组内第一个可查询的MDS获取
+type Deployment struct {
+	Name     string     `json:"name" yaml:"name"`
+	Source   string     `json:"source,omitempty" yaml:"source,omitempty"` // 实际查询的MDS
+	Error    string     `json:"error,omitempty" yaml:"error,omitempty"`
+	MDS      []Endpoint `json:"mds" yaml:"mds"`
+	Clusters []Cluster  `json:"clusters" yaml:"clusters"`
+}
+
+// Endpoint 一个MDS节点
+type Endpoint struct {
+	Name         string `json:"name" yaml:"name"`
+	Addr         string `json:"addr" yaml:"addr"`
+	Reachability `yaml:",inline"`
+}
+
+// Cluster 一个集群（租户）
+type Cluster struct {
+	ID       string   `json:"id" yaml:"id"`
+	Name     string   `json:"name" yaml:"name"`
+	Issingle bool     `json:"issingle" yaml:"issingle"`
+	Error    string   `json:"error,omitempty" yaml:"error,omitempty"`
+	Groups   []Group  `json:"groups" yaml:"groups"`
+	Schemas  []string `json:"schemas" yaml:"schemas"`
+}
+
+// Group 一个DN组，组内为同一分片的主备DN
+type Group struct {
+	ID  string `json:"id" yaml:"id"`
+	DNs []DN   `json:"dns" yaml:"dns"`
+}
+
+// DN 一个数据节点
+type DN struct {
+	ID           string `json:"id" yaml:"id"`
+	Role         string `json:"role" yaml:"role"`
+	Host         string `json:"host" yaml:"host"`
+	Port         int    `json:"port" yaml:"port"`
+	Addr         string `json:"addr" yaml:"addr"`
+	Reachability `yaml:",inline"`
+}
+
+// Reachability 连通性检查结果，未检查时Reachable为nil
+type Reachability struct {
+	Reachable *bool   `json:"reachable,omitempty" yaml:"reachable,omitempty"`
+	LatencyMs float64 `json:"latencyMs,omitempty" yaml:"latencyMs,omitempty"`
+	CheckErr  string  `json:"checkError,omitempty" yaml:"checkError,omitempty"`
+}
+
+// Options 发现选项
+type Options struct {
+	Check       bool          // 是否检查各节点端口能否连通
+	Timeout     time.Duration // 单个节点的连通性检查超时
+	Concurrency int           // 连通性检查的并发数，默认16
+}
+
+// Discover 按group将MDS分组并发现各部署的拓扑。组内MDS依次尝试，第一个查询成功的作为拓扑来源
+func Discover(ctx context.Context, mdsList []connect.MDSDemo, opts Options) Topology {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 16
+	}
+	var deployments []*Deployment
+	members := make(map[string][]connect.MDSDemo)
+	index := make(map[string]*Deployment)
+	for _, m := range mdsList {
+		d, ok := index[m.Group]
+		if !ok {
+			d = &Deployment{Name: m.Group, MDS: []Endpoint{}, Clusters: []Cluster{}}
+			index[m.Group] = d
+			deployments = append(deployments, d)
+		}
+		d.MDS = append(d.MDS, Endpoint{Name: m.Name, Addr: m.Addr()})
+		members[m.Group] = append(members[m.Group], m)
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deployments {
+		wg.Add(1)
+		go func(d *Deployment) {
+			defer wg.Done()
+			d.discover(ctx, members[d.Name])
+		}(d)
+	}
+	wg.Wait()
+
+	t := Topology{GeneratedAt: time.Now().Format("2006-01-02 15:04:05"), Deployments: []Deployment{}}
+	for _, d := range deployments {
+		t.Deployments = append(t.Deployments, *d)
+	}
+	if opts.Check {
+		t.checkReachability(ctx, opts)
+	}
+	return t
+}
+
+// discover 依次尝试组内MDS，直到有一个能查询到集群列表
+func (d *Deployment) discover(ctx context.Context, members []connect.MDSDemo) {
+	var errs []string
+	for _, m := range members {
+		clusters, err := discoverMDS(ctx, m)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", m.Name, redact.Error(err)))
+			continue
+		}
+		d.Source = m.Name
+		d.Clusters = clusters
+		return
+	}
+	d.Error = "没有可查询的MDS: " + strings.Join(errs, "; ")
+}
+
+// discoverMDS 从一个MDS查询集群、DN和Schema。单个集群查询失败时记录在该集群的Error中
+func discoverMDS(ctx context.Context, m connect.MDSDemo) ([]Cluster, error) {
+	db, err := connect.Connect(ctx, m.Node)
+	if err != nil {
+		return nil, err
+	}
+	list, err := info.ListClusters(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	clusters := make([]Cluster, 0, len(list))
+	for _, c := range list {
+		cluster := Cluster{ID: c.ClusterID, Name: c.ClusterName, Issingle: c.Issingle, Groups: []Group{}, Schemas: []string{}}
+		dns, err := info.ListDNs(ctx, db, c.ClusterID)
+		if err != nil {
+			cluster.Error = redact.Error(err)
+			clusters = append(clusters, cluster)
+			continue
+		}
+		cluster.Groups = groupDNs(dns)
+		if cluster.Schemas, err = info.ListSchemas(ctx, db, c.ClusterID); err != nil {
+			cluster.Schemas = []string{}
+			cluster.Error = redact.Error(err)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// groupDNs 按group_id归组，保持查询返回的顺序
+func groupDNs(dns []info.DNInfo) []Group {
+	groups := []Group{}
+	index := make(map[string]int)
+	for _, d := range dns {
+		i, ok := index[d.GroupID]
+		if !ok {
+			i = len(groups)
+			index[d.GroupID] = i
+			groups = append(groups, Group{ID: d.GroupID})
+		}
+		groups[i].DNs = append(groups[i].DNs, DN{
+			ID:   d.DBID,
+			Role: d.RoleName(),
+			Host: d.Host,
+			Port: d.Port,
+			Addr: net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		})
+	}
+	return groups
+}
+
+// checkReachability 并发检查全部MDS和DN的端口能否连通
+func (t *Topology) checkReachability(ctx context.Context, opts Options) {
+	var targets []*Reachability
+	var addrs []string
+	for i := range t.Deployments {
+		d := &t.Deployments[i]
+		for j := range d.MDS {
+			targets = append(targets, &d.MDS[j].Reachability)
+			addrs = append(addrs, d.MDS[j].Addr)
+		}
+		for j := range d.Clusters {
+			for k := range d.Clusters[j].Groups {
+				g := &d.Clusters[j].Groups[k]
+				for l := range g.DNs {
+					targets = append(targets, &g.DNs[l].Reachability)
+					addrs = append(addrs, g.DNs[l].Addr)
+				}
+			}
+		}
+	}
+
+	sem := make(chan struct{}, opts.Concurrency)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *Reachability, addr string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			*r = dial(ctx, addr, opts.Timeout)
+		}(targets[i], addrs[i])
+	}
+	wg.Wait()
+}
+
+// dial 建立TCP连接测试端口能否连通，不登录数据库
+func dial(ctx context.Context, addr string, timeout time.Duration) Reachability {
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	ok := err == nil
+	r := Reachability{Reachable: &ok}
+	if err != nil {
+		r.CheckErr = err.Error()
+		return r
+	}
+	conn.Close()
+	r.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	return r
+}
+
+// Unreachable 连通性检查失败的MDS和DN数量
+func (t Topology) Unreachable() int {
+	n := 0
+	failed := func(r Reachability) {
+		if r.Reachable != nil && !*r.Reachable {
+			n++
+		}
+	}
+	for _, d := range t.Deployments {
+		for _, m := range d.MDS {
+			failed(m.Reachability)
+		}
+		for _, c := range d.Clusters {
+			for _, g := range c.Groups {
+				for _, dn := range g.DNs {
+					failed(dn.Reachability)
+				}
+			}
+		}
+	}
+	return n
+}
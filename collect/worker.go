@@ -0,0 +1,286 @@
+package main
+
+import (
+	"GoldenDB/alarm"
+	"GoldenDB/connect"
+	"GoldenDB/redact"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 同组MDS的采集方式
+const (
+	groupModeStandby = "standby"
+	groupModeMerge   = "merge"
+)
+
+// mdsMember 组内的单个MDS
+type mdsMember struct {
+	name string
+	node connect.Node
+	db   *sql.DB
+}
+
+// mdsGroup 同一GoldenDB部署的一组MDS（主备），以组名作为insight名称，
+// 保证同一告警无论从哪个MDS采集到都只推送一次
+type mdsGroup struct {
+	name    string
+	mode    string
+	members []mdsMember
+	active  int // standby模式下当前负责采集的成员
+
+	mu      sync.Mutex // 保护以下采集状态，供 status 命令查询
+	lastRun time.Time  // 最近一轮采集结束时间
+	lastErr string     // 最近一轮采集的错误，成功时为空
+	alarms  int        // 最近一次成功采集的告警数
+}
+
+// groupStatus 分组的采集状态
+type groupStatus struct {
+	Name      string `json:"name"`
+	Mode      string `json:"mode"`
+	Members   int    `json:"members"`
+	Active    string `json:"active,omitempty"` // standby模式下当前采集的MDS
+	LastRun   string `json:"lastRun,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+	Alarms    int    `json:"alarms"`
+}
+
+// record 记录一轮采集的结果
+func (g *mdsGroup) record(alarms int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lastRun = time.Now()
+	if err != nil {
+		g.lastErr = redact.Error(err)
+		return
+	}
+	g.lastErr = ""
+	g.alarms = alarms
+}
+
+// status 返回分组的采集状态
+func (g *mdsGroup) status() groupStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := groupStatus{Name: g.name, Mode: g.mode, Members: len(g.members), LastError: g.lastErr, Alarms: g.alarms}
+	if g.mode != groupModeMerge && len(g.members) > 0 {
+		st.Active = g.members[g.active].name
+	}
+	if !g.lastRun.IsZero() {
+		st.LastRun = g.lastRun.Format("2006-01-02 15:04:05")
+	}
+	return st
+}
+
+// lastRunTime 最近一轮采集结束时间
+func (g *mdsGroup) lastRunTime() time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lastRun
+}
+
+// groupMDS 按配置顺序将MDS分组，未配置group的MDS自成一组
+func groupMDS(mdsList []connect.MDSDemo, mode string) []*mdsGroup {
+	var groups []*mdsGroup
+	index := make(map[string]*mdsGroup)
+	for _, mds := range mdsList {
+		g, ok := index[mds.Group]
+		if !ok {
+			g = &mdsGroup{name: mds.Group, mode: mode}
+			index[mds.Group] = g
+			groups = append(groups, g)
+		}
+		db, err := connect.Open(mds.Node)
+		if err != nil {
+			if logger != nil {
+				logger.Error("MDS连接失败: %s, 错误: %v", mds.Name, err)
+			}
+			continue
+		}
+		g.members = append(g.members, mdsMember{name: mds.Name, node: mds.Node, db: db})
+	}
+	return groups
+}
+
+// ping 检查组内各MDS的连通性，仅记录日志，连接失败的成员在采集时自动跳过
+func (g *mdsGroup) ping(ctx context.Context, timeout time.Duration) {
+	for _, m := range g.members {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := m.db.PingContext(pingCtx)
+		cancel()
+		if logger == nil {
+			continue
+		}
+		if err != nil {
+			logger.With("mds", m.name).Error("MDS连接失败: %s/%s, 错误: %v", g.name, m.name, err)
+			continue
+		}
+		logger.Info("MDS连接成功: %s/%s", g.name, m.name)
+	}
+}
+
+// collect 采集本组当前告警，ctx结束时正在进行的查询随之取消
+func (g *mdsGroup) collect(ctx context.Context, timeout time.Duration) ([]alarm.Alarm, error) {
+	if len(g.members) == 0 {
+		return nil, fmt.Errorf("组 %s 没有可用的MDS", g.name)
+	}
+	if g.mode == groupModeMerge {
+		return g.collectMerge(ctx, timeout)
+	}
+	return g.collectStandby(ctx, timeout)
+}
+
+// collectStandby 由当前活动成员采集，失败时依次切换到下一个成员
+func (g *mdsGroup) collectStandby(ctx context.Context, timeout time.Duration) ([]alarm.Alarm, error) {
+	var lastErr error
+	for i := 0; i < len(g.members); i++ {
+		idx := (g.active + i) % len(g.members)
+		m := g.members[idx]
+		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		alarms, err := alarm.QueryAlarms(queryCtx, m.db)
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			lastErr = err
+			if logger != nil {
+				logger.With("mds", m.name).Error("MDS采集失败: %s/%s, 错误: %v", g.name, m.name, err)
+			}
+			continue
+		}
+		if idx != g.active {
+			if logger != nil {
+				logger.Warn("组 %s 切换采集MDS: %s -> %s", g.name, g.members[g.active].name, m.name)
+			}
+			g.mu.Lock()
+			g.active = idx
+			g.mu.Unlock()
+		}
+		return alarms, nil
+	}
+	return nil, lastErr
+}
+
+// collectMerge 所有成员并发采集，按告警ID合并；全部失败时返回错误
+func (g *mdsGroup) collectMerge(ctx context.Context, timeout time.Duration) ([]alarm.Alarm, error) {
+	results := make([][]alarm.Alarm, len(g.members))
+	errs := make([]error, len(g.members))
+	var wg sync.WaitGroup
+	for i, m := range g.members {
+		wg.Add(1)
+		go func(i int, m mdsMember) {
+			defer wg.Done()
+			queryCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			results[i], errs[i] = alarm.QueryAlarms(queryCtx, m.db)
+		}(i, m)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	var ok [][]alarm.Alarm
+	var lastErr error
+	for i, err := range errs {
+		if err != nil {
+			lastErr = err
+			if logger != nil {
+				logger.With("mds", g.members[i].name).Error("MDS采集失败: %s/%s, 错误: %v", g.name, g.members[i].name, err)
+			}
+			continue
+		}
+		ok = append(ok, results[i])
+	}
+	if len(ok) == 0 {
+		return nil, lastErr
+	}
+	return alarm.MergeAlarms(ok...), nil
+}
+
+// runGroup 定时采集一组MDS的告警并提交到推送队列，ctx结束时返回
+func runGroup(ctx context.Context, g *mdsGroup, period time.Duration, queryTimeout time.Duration, dispatcher *alarm.Dispatcher) {
+	insight := g.name  //insight平台名称
+	var cache sync.Map // 定义缓存
+	restored := alarm.RegisterCache(insight, &cache)
+	if logger != nil {
+		logger.Info("开始监控: %s, 成员数: %d, 模式: %s, 恢复已推送告警: %d", insight, len(g.members), g.mode, restored)
+	}
+	g.ping(ctx, queryTimeout)
+
+	ticker := time.NewTicker(period) //定时器
+	defer ticker.Stop()
+
+	// 定时查询并告警
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 查询当前所有告警，单次查询有超时，避免MDS查询挂起阻塞本协程
+		raw, err := g.collect(ctx, queryTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+		g.record(len(raw), err)
+		if err != nil {
+			if logger != nil {
+				logger.Error("采集告警失败, 跳过本轮: %s, 错误: %v", insight, err)
+			}
+			continue
+		}
+		Alarms := alarm.ProcessCollected(insight, raw)
+		currentAlarms := alarm.GenAlarmList(Alarms, insight, "trigger")
+
+		// 变化的告警放入推送队列，不等待推送完成
+		queued, dropped := dispatcher.Submit(currentAlarms, &cache)
+		if logger != nil {
+			logger.Info("处理告警完成: %s, 当前告警数: %d, 入队: %d, 丢弃: %d, 队列长度: %d", insight, len(currentAlarms), queued, dropped, dispatcher.QueueLen())
+			for _, v := range currentAlarms {
+				logger.With("mds", insight, "alarm_id", v.AlarmId, "code", v.Code).Debug("当前告警: %+v", v)
+			}
+		}
+	}
+}
+
+// prober 主动探测，每轮返回当前应处于告警状态的告警
+type prober interface {
+	Insight() string
+	Interval() time.Duration
+	Check(ctx context.Context) []alarm.Alarm
+}
+
+// runProber 定时执行探测，探测产生的告警与MDS告警一样经过过滤和去重后提交到推送队列，ctx结束时返回
+func runProber(ctx context.Context, p prober, dispatcher *alarm.Dispatcher) {
+	insight := p.Insight()
+	var cache sync.Map
+	restored := alarm.RegisterCache(insight, &cache)
+	if logger != nil {
+		logger.Info("开始探测: %s, 间隔: %s, 恢复已推送告警: %d", insight, p.Interval(), restored)
+	}
+
+	ticker := time.NewTicker(p.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		raw := p.Check(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		currentAlarms := alarm.GenAlarmList(alarm.ProcessCollected(insight, raw), insight, "trigger")
+		queued, dropped := dispatcher.Submit(currentAlarms, &cache)
+		if logger != nil {
+			logger.Info("探测完成: %s, 当前告警数: %d, 入队: %d, 丢弃: %d", insight, len(currentAlarms), queued, dropped)
+		}
+	}
+}